@@ -135,7 +135,7 @@ func (m *advancedSettingsModel) toggleValue() {
 		}
 
 	case fieldColorMode:
-		modes := []string{"auto", "srgb", "wide", "edid", "hdr", "hdredid"}
+		modes := []string{"auto", "srgb", "dcip3", "dp3", "adobe", "wide", "edid", "hdr", "hdredid"}
 		currentIdx := 0
 		for i, mode := range modes {
 			if m.monitor.ColorMode == mode {
@@ -153,7 +153,7 @@ func (m *advancedSettingsModel) toggleValue() {
 		}
 
 	case fieldVRR:
-		m.monitor.VRR = (m.monitor.VRR + 1) % 3
+		m.monitor.VRR = (m.monitor.VRR + 1) % 4
 
 	case fieldTransform:
 		m.monitor.Transform = (m.monitor.Transform + 1) % 8
@@ -345,6 +345,9 @@ func (m advancedSettingsModel) renderColorMode() string {
 	modes := map[string]string{
 		"auto":    "Auto",
 		"srgb":    "sRGB",
+		"dcip3":   "DCI-P3",
+		"dp3":     "Display P3",
+		"adobe":   "Adobe RGB",
 		"wide":    "Wide",
 		"edid":    "EDID",
 		"hdr":     "HDR",
@@ -352,7 +355,7 @@ func (m advancedSettingsModel) renderColorMode() string {
 	}
 
 	var parts []string
-	for _, key := range []string{"auto", "srgb", "wide", "edid", "hdr", "hdredid"} {
+	for _, key := range []string{"auto", "srgb", "dcip3", "dp3", "adobe", "wide", "edid", "hdr", "hdredid"} {
 		if m.monitor.ColorMode == key {
 			parts = append(parts, "● "+modes[key])
 		} else {
@@ -417,11 +420,13 @@ func (m advancedSettingsModel) renderSDRSaturation() string {
 func (m advancedSettingsModel) renderVRR() string {
 	switch m.monitor.VRR {
 	case 1:
-		return "○ Off  ● On  ○ Fullscreen"
+		return "○ Off  ● On  ○ Fullscreen  ○ Video/Game"
 	case 2:
-		return "○ Off  ○ On  ● Fullscreen"
+		return "○ Off  ○ On  ● Fullscreen  ○ Video/Game"
+	case 3:
+		return "○ Off  ○ On  ○ Fullscreen  ● Video/Game"
 	default:
-		return "● Off  ○ On  ○ Fullscreen"
+		return "● Off  ○ On  ○ Fullscreen  ○ Video/Game"
 	}
 }
 
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// exitNoMatchingProfile is the exit code of `hyprmon --auto` when no saved
+// profile fits the connected monitors, so scripts can tell it apart from a
+// real failure (exit code 1).
+const exitNoMatchingProfile = 2
+
+var errNoMatchingProfile = errors.New("no saved profile matches the connected monitors")
+
+// profileMatchKind ranks how a profile's monitor set relates to the set of
+// connected monitors. Higher is better.
+type profileMatchKind int
+
+const (
+	matchNone profileMatchKind = iota
+	// matchSubset: every profile monitor is connected, but some connected
+	// monitors are not in the profile and would be left as they are.
+	matchSubset
+	// matchSuperset: every connected monitor is in the profile, but some
+	// profile monitors are unplugged and will be skipped.
+	matchSuperset
+	// matchExact: the profile describes exactly the connected monitors.
+	matchExact
+)
+
+func (k profileMatchKind) String() string {
+	switch k {
+	case matchExact:
+		return "exact"
+	case matchSuperset:
+		return "superset"
+	case matchSubset:
+		return "subset"
+	default:
+		return "none"
+	}
+}
+
+// How a profile monitor counts when matching the profile against the
+// connected monitors. Monitors without a presence may be missing, but the
+// profile then ranks below ones that have all their monitors.
+const (
+	// monitorRequired: the profile only fits when the monitor is connected
+	monitorRequired = "required"
+	// monitorOptional: the profile fits just as well without the monitor
+	monitorOptional = "optional"
+)
+
+// profileMatch describes how well one profile fits the connected monitors.
+type profileMatch struct {
+	Profile *Profile
+	Kind    profileMatchKind
+	Matched int // profile monitors that are connected
+	Missing int // profile monitors that are not connected, except optional ones
+	Extra   int // connected monitors the profile does not mention
+}
+
+// monitorKey returns the identity used to match monitors across sessions:
+// the HardwareID, or the connector name for legacy entries without one.
+func monitorKey(m Monitor) string {
+	if m.HardwareID != "" {
+		return "hw:" + m.HardwareID
+	}
+	return "name:" + m.Name
+}
+
+// keepMonitorPresence copies the presence of the monitors in saved onto
+// the same monitors in monitors, so saving a layout over a profile keeps
+// its matching rules.
+func keepMonitorPresence(monitors, saved []Monitor) []Monitor {
+	presence := make(map[string]string)
+	for _, m := range saved {
+		if m.Presence != "" {
+			presence[monitorKey(m)] = m.Presence
+		}
+	}
+	if len(presence) == 0 {
+		return monitors
+	}
+	kept := make([]Monitor, len(monitors))
+	copy(kept, monitors)
+	for i := range kept {
+		if p, ok := presence[monitorKey(kept[i])]; ok {
+			kept[i].Presence = p
+		}
+	}
+	return kept
+}
+
+// scoreProfile compares a profile's monitors with the connected ones by
+// hardware identity. Legacy profile monitors without a HardwareID fall back
+// to connector names, like resolveProfileMonitors does. A profile with a
+// required monitor that is not connected doesn't match at all.
+func scoreProfile(profile *Profile, current []Monitor) profileMatch {
+	match := profileMatch{Profile: profile}
+
+	resolved := resolveProfileMonitors(profile.Monitors, current)
+	match.Matched = len(resolved)
+
+	required, optional := 0, 0
+	for _, m := range profile.Monitors {
+		switch m.Presence {
+		case monitorRequired:
+			required++
+		case monitorOptional:
+			optional++
+		}
+	}
+	used := make(map[string]bool)
+	for _, m := range resolved {
+		used[m.Name] = true
+		switch m.Presence {
+		case monitorRequired:
+			required--
+		case monitorOptional:
+			optional--
+		}
+	}
+	if required > 0 {
+		return match
+	}
+	match.Missing = len(profile.Monitors) - len(resolved) - optional
+
+	for _, m := range current {
+		if !used[m.Name] {
+			match.Extra++
+		}
+	}
+
+	switch {
+	case match.Matched == 0:
+		match.Kind = matchNone
+	case match.Missing == 0 && match.Extra == 0:
+		match.Kind = matchExact
+	case match.Extra == 0:
+		match.Kind = matchSuperset
+	case match.Missing == 0:
+		match.Kind = matchSubset
+	default:
+		match.Kind = matchNone
+	}
+	return match
+}
+
+// priority returns the profile's priority when every profile monitor that
+// isn't optional is connected, and 0 otherwise. Monitors without a presence
+// count as required here, so a profile missing some of them can't win on
+// priority alone.
+func (m profileMatch) priority() int {
+	if m.Missing > 0 {
+		return 0
+	}
+	return m.Profile.Priority
+}
+
+// better reports whether a ranks above b: the higher priority wins, then
+// the closer match. Ties keep the earlier profile, so the user's saved
+// profile order decides between equal candidates.
+func (a profileMatch) better(b profileMatch) bool {
+	if a.priority() != b.priority() {
+		return a.priority() > b.priority()
+	}
+	if a.Kind != b.Kind {
+		return a.Kind > b.Kind
+	}
+	if a.Matched != b.Matched {
+		return a.Matched > b.Matched
+	}
+	return a.Missing+a.Extra < b.Missing+b.Extra
+}
+
+// selectAutoProfile returns the best-matching profile for the connected
+// monitors among those whose conditions state meets, or ok=false when none
+// of them overlaps well enough.
+func selectAutoProfile(profiles []*Profile, current []Monitor, state systemState) (profileMatch, bool) {
+	var best profileMatch
+	found := false
+	for _, profile := range profiles {
+		if !profile.Conditions.satisfied(state) {
+			continue
+		}
+		match := scoreProfile(profile, current)
+		if match.Kind == matchNone {
+			continue
+		}
+		if !found || match.better(best) {
+			best = match
+			found = true
+		}
+	}
+	return best, found
+}
+
+// isProfileApplied reports whether the connected monitors already match the
+// profile's settings. Only the monitors the profile mentions are compared,
+// so partial (superset/subset) matches can be recognised as active too.
+func isProfileApplied(profile *Profile, current []Monitor) bool {
+	resolved := resolveProfileMonitors(profile.Monitors, current)
+	if len(resolved) == 0 {
+		return false
+	}
+
+	names := make(map[string]bool)
+	for _, m := range resolved {
+		names[m.Name] = true
+	}
+	var relevant []Monitor
+	for _, m := range current {
+		if names[m.Name] {
+			relevant = append(relevant, m)
+		}
+	}
+	return compareMonitorConfigurations(relevant, resolved)
+}
+
+// findAutoProfile reads the connected monitors and picks the best saved
+// profile for them. Returns errNoMatchingProfile when nothing fits.
+func findAutoProfile() (profileMatch, []Monitor, error) {
+	current, err := readMonitors()
+	if err != nil {
+		return profileMatch{}, nil, fmt.Errorf("failed to read current monitors: %w", err)
+	}
+
+	profiles, err := loadProfilesInOrder()
+	if err != nil {
+		return profileMatch{}, nil, err
+	}
+
+	match, ok := selectAutoProfile(profiles, current, currentSystemState())
+	if !ok {
+		return profileMatch{}, current, errNoMatchingProfile
+	}
+	return match, current, nil
+}
+
+// applyAutoProfile applies the best-matching saved profile and returns its
+// name. policy, confirm and out are passed on to applyProfileWithConfirm.
+func applyAutoProfile(policy conflictPolicy, confirm func() bool, out io.Writer) (string, []monitorDrift, error) {
+	match, _, err := findAutoProfile()
+	if err != nil {
+		return "", nil, err
+	}
+
+	drifts, err := applyProfileWithConfirm(match.Profile.Name, policy, confirm, out)
+	return match.Profile.Name, drifts, err
+}
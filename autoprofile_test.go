@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestScoreProfile(t *testing.T) {
+	laptopPanel := Monitor{Name: "eDP-1", HardwareID: "BOE/0x0BCA"}
+	dell := Monitor{Name: "DP-1", HardwareID: "Dell Inc./U2720Q/ABC"}
+	lg := Monitor{Name: "HDMI-A-1", HardwareID: "LG/27GL850/XYZ"}
+
+	tests := []struct {
+		name     string
+		saved    []Monitor
+		current  []Monitor
+		wantKind profileMatchKind
+	}{
+		{"exact", []Monitor{laptopPanel, dell}, []Monitor{laptopPanel, dell}, matchExact},
+		{"profile has unplugged monitor", []Monitor{laptopPanel, dell, lg}, []Monitor{laptopPanel, dell}, matchSuperset},
+		{"extra monitor connected", []Monitor{laptopPanel}, []Monitor{laptopPanel, dell}, matchSubset},
+		{"overlapping but neither", []Monitor{laptopPanel, lg}, []Monitor{laptopPanel, dell}, matchNone},
+		{"disjoint", []Monitor{lg}, []Monitor{laptopPanel}, matchNone},
+		{"legacy profile without HardwareID", []Monitor{{Name: "eDP-1"}}, []Monitor{{Name: "eDP-1"}}, matchExact},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := scoreProfile(&Profile{Monitors: tt.saved}, tt.current)
+			if got.Kind != tt.wantKind {
+				t.Errorf("scoreProfile().Kind = %v, want %v (%+v)", got.Kind, tt.wantKind, got)
+			}
+		})
+	}
+}
+
+func TestSelectAutoProfile(t *testing.T) {
+	laptopPanel := Monitor{Name: "eDP-1", HardwareID: "BOE/0x0BCA"}
+	dell := Monitor{Name: "DP-1", HardwareID: "Dell Inc./U2720Q/ABC"}
+	lg := Monitor{Name: "HDMI-A-1", HardwareID: "LG/27GL850/XYZ"}
+
+	laptop := &Profile{Name: "laptop", Monitors: []Monitor{laptopPanel}}
+	docked := &Profile{Name: "docked", Monitors: []Monitor{laptopPanel, dell}}
+	triple := &Profile{Name: "triple", Monitors: []Monitor{laptopPanel, dell, lg}}
+	profiles := []*Profile{laptop, triple, docked}
+
+	tests := []struct {
+		name    string
+		current []Monitor
+		want    *Profile
+	}{
+		{"exact match beats superset and subset", []Monitor{laptopPanel, dell}, docked},
+		{"exact match on laptop", []Monitor{laptopPanel}, laptop},
+		{"connector renamed by dock", []Monitor{laptopPanel, {Name: "DP-5", HardwareID: dell.HardwareID}}, docked},
+		{"superset preferred over subset", []Monitor{laptopPanel, lg}, triple},
+		{"nothing overlaps", []Monitor{{Name: "DP-9", HardwareID: "Acme/X/1"}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := selectAutoProfile(profiles, tt.current, systemState{})
+			if tt.want == nil {
+				if ok {
+					t.Fatalf("selectAutoProfile() = %q, want no match", got.Profile.Name)
+				}
+				return
+			}
+			if !ok || got.Profile != tt.want {
+				t.Fatalf("selectAutoProfile() = %+v (ok=%v), want %q", got, ok, tt.want.Name)
+			}
+		})
+	}
+}
+
+func TestSelectAutoProfileKeepsSavedOrderOnTie(t *testing.T) {
+	panel := Monitor{Name: "eDP-1", HardwareID: "BOE/0x0BCA"}
+	first := &Profile{Name: "first", Monitors: []Monitor{panel}}
+	second := &Profile{Name: "second", Monitors: []Monitor{panel}}
+
+	got, ok := selectAutoProfile([]*Profile{first, second}, []Monitor{panel}, systemState{})
+	if !ok || got.Profile != first {
+		t.Fatalf("selectAutoProfile() = %+v, want first profile", got)
+	}
+}
+
+func TestSelectAutoProfileRules(t *testing.T) {
+	panel := Monitor{Name: "eDP-1", HardwareID: "BOE/0x0BCA"}
+	dell := Monitor{Name: "DP-1", HardwareID: "Dell Inc./U2720Q/ABC"}
+	lg := Monitor{Name: "HDMI-A-1", HardwareID: "LG/27GL850/XYZ"}
+	required := func(m Monitor) Monitor { m.Presence = monitorRequired; return m }
+	optional := func(m Monitor) Monitor { m.Presence = monitorOptional; return m }
+
+	desk := &Profile{Name: "desk", Monitors: []Monitor{required(dell), optional(lg), panel}}
+	clamshell := &Profile{Name: "clamshell", Priority: 10, Monitors: []Monitor{required(dell)},
+		Conditions: &ProfileConditions{Lid: lidClosed}}
+	night := &Profile{Name: "night", Priority: 5, Monitors: []Monitor{panel},
+		Conditions: &ProfileConditions{Time: "22:00-06:00"}}
+	laptop := &Profile{Name: "laptop", Monitors: []Monitor{panel}}
+	profiles := []*Profile{laptop, desk, clamshell, night}
+
+	noon := time.Date(2026, 1, 1, 12, 0, 0, 0, time.Local)
+	midnight := time.Date(2026, 1, 1, 0, 30, 0, 0, time.Local)
+	tests := []struct {
+		name    string
+		current []Monitor
+		state   systemState
+		want    *Profile
+	}{
+		{"optional monitor unplugged still exact", []Monitor{panel, dell}, systemState{Lid: lidOpen, Now: noon}, desk},
+		{"required monitor unplugged", []Monitor{panel, lg}, systemState{Lid: lidOpen, Now: noon}, laptop},
+		{"higher priority wins over a closer match", []Monitor{panel, dell}, systemState{Lid: lidClosed, Now: noon}, clamshell},
+		{"time window", []Monitor{panel}, systemState{Lid: lidOpen, Now: midnight}, night},
+		{"outside the time window", []Monitor{panel}, systemState{Lid: lidOpen, Now: noon}, laptop},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := selectAutoProfile(profiles, tt.current, tt.state)
+			if !ok || got.Profile != tt.want {
+				t.Fatalf("selectAutoProfile() = %+v (ok=%v), want %q", got, ok, tt.want.Name)
+			}
+		})
+	}
+
+	// Saving the layout over the profile keeps its rules
+	kept := keepMonitorPresence([]Monitor{{Name: "DP-3", HardwareID: dell.HardwareID}, panel}, desk.Monitors)
+	if kept[0].Presence != monitorRequired || kept[1].Presence != "" {
+		t.Errorf("keepMonitorPresence() = %+v", kept)
+	}
+}
+
+func TestSelectAutoProfileUndockedIgnoresPriority(t *testing.T) {
+	panel := Monitor{Name: "eDP-1", HardwareID: "BOE/0x0BCA"}
+	dell := Monitor{Name: "DP-1", HardwareID: "Dell Inc./U2720Q/ABC"}
+	docked := &Profile{Name: "docked", Priority: 10, Monitors: []Monitor{panel, dell}}
+	laptop := &Profile{Name: "laptop", Monitors: []Monitor{panel}}
+	profiles := []*Profile{docked, laptop}
+
+	// Undocked, the docked profile is missing the Dell, so its priority
+	// doesn't count and the exact laptop match wins
+	got, ok := selectAutoProfile(profiles, []Monitor{panel}, systemState{})
+	if !ok || got.Profile != laptop {
+		t.Fatalf("selectAutoProfile() undocked = %+v (ok=%v), want laptop", got, ok)
+	}
+
+	// Docked, it has all its monitors and wins
+	got, ok = selectAutoProfile(profiles, []Monitor{panel, dell}, systemState{})
+	if !ok || got.Profile != docked {
+		t.Fatalf("selectAutoProfile() docked = %+v (ok=%v), want docked", got, ok)
+	}
+}
+
+func TestIsProfileAppliedIgnoresUnmentionedMonitors(t *testing.T) {
+	profile := &Profile{Monitors: []Monitor{
+		{Name: "eDP-1", HardwareID: "BOE/0x0BCA", PxW: 1920, PxH: 1200, Active: true},
+	}}
+	current := []Monitor{
+		{Name: "eDP-1", HardwareID: "BOE/0x0BCA", PxW: 1920, PxH: 1200, Active: true},
+		{Name: "DP-1", HardwareID: "Dell Inc./U2720Q/ABC", PxW: 2560, PxH: 1440, X: 1920, Active: true},
+	}
+
+	if !isProfileApplied(profile, current) {
+		t.Error("isProfileApplied() = false, want true for matching subset profile")
+	}
+
+	current[0].X = 100
+	if isProfileApplied(profile, current) {
+		t.Error("isProfileApplied() = true after the panel moved, want false")
+	}
+}
+
+func TestApplyAutoProfileWithoutMatch(t *testing.T) {
+	startFakeHyprland(t, func(request string) string {
+		return `[{"name":"DP-1","make":"Dell Inc.","model":"U2720Q","serial":"ABC","width":2560,"height":1440,"refreshRate":60,"scale":1}]`
+	})
+	useTempConfigDir(t)
+
+	if err := saveProfile("other-desk", []Monitor{{Name: "DP-1", HardwareID: "LG/27GL850/XYZ"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := applyAutoProfile(conflictMerge, nil, io.Discard); !errors.Is(err, errNoMatchingProfile) {
+		t.Fatalf("applyAutoProfile(conflictMerge, nil, io.Discard) error = %v, want errNoMatchingProfile", err)
+	}
+}
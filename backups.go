@@ -0,0 +1,314 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// backupsStateDir is the directory under the state dir that holds backups
+// when they are kept out of the config directory.
+const backupsStateDir = "backups"
+
+// backupFile is one <file>.bak.<unix> copy of a config file.
+type backupFile struct {
+	Path     string    // the backup itself
+	Original string    // the config file it is a copy of
+	Time     time.Time // when it was taken
+}
+
+// backupSuffix splits a backup path into the file it backs up and the Unix
+// time in its .bak.<unix> suffix.
+func backupSuffix(path string) (string, int64, bool) {
+	i := strings.LastIndex(path, ".bak.")
+	if i <= 0 {
+		return "", 0, false
+	}
+	unix, err := strconv.ParseInt(path[i+len(".bak."):], 10, 64)
+	if err != nil || unix <= 0 {
+		return "", 0, false
+	}
+	return path[:i], unix, true
+}
+
+// backupDir returns the directory backups of configPath go to.
+func backupDir(configPath string, s *Settings) (string, error) {
+	if getBackupLocation(s) != backupLocationState {
+		return filepath.Dir(configPath), nil
+	}
+	dir := getStateDir()
+	if dir == "" {
+		return "", fmt.Errorf("could not determine state directory")
+	}
+	abs, err := filepath.Abs(configPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", configPath, err)
+	}
+	return filepath.Join(dir, backupsStateDir, filepath.Dir(abs)), nil
+}
+
+// backupConfigFile saves content, the current content of configPath, as a
+// timestamped backup before the file is rewritten. Old backups are only
+// deleted by `hyprmon backups prune`.
+func backupConfigFile(configPath string, content []byte) error {
+	s, err := loadSettings()
+	if err != nil {
+		return err
+	}
+	dir, err := backupDir(configPath, s)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, profileDirMode); err != nil {
+		return fmt.Errorf("failed to ensure backup directory: %w", err)
+	}
+
+	// Several saves in the same second must not overwrite each other
+	base := filepath.Join(dir, filepath.Base(configPath))
+	unix := time.Now().Unix()
+	backupPath := fmt.Sprintf("%s.bak.%d", base, unix)
+	for fileExists(backupPath) {
+		unix++
+		backupPath = fmt.Sprintf("%s.bak.%d", base, unix)
+	}
+	return os.WriteFile(backupPath, content, backupFileMode)
+}
+
+// listBackups returns the backups of the files in the Hyprland config
+// directory and its subdirectories (where sourced files may live), wherever
+// they were written, newest first.
+func listBackups() ([]backupFile, error) {
+	target, err := getConfigTarget()
+	if err != nil {
+		return nil, fmt.Errorf("could not determine config path: %w", err)
+	}
+	configDir, err := filepath.Abs(filepath.Dir(target.Path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve config directory: %w", err)
+	}
+
+	var backups []backupFile
+	// scan finds the backups under root, which mirrors the config directory
+	scan := func(root string) error {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			original, unix, ok := backupSuffix(path)
+			if !ok {
+				return nil
+			}
+			rel, err := filepath.Rel(root, original)
+			if err != nil {
+				return nil
+			}
+			backups = append(backups, backupFile{
+				Path:     path,
+				Original: filepath.Join(configDir, rel),
+				Time:     time.Unix(unix, 0),
+			})
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to list backups: %w", err)
+		}
+		return nil
+	}
+
+	// Next to the config files, and under the state directory
+	if err := scan(configDir); err != nil {
+		return nil, err
+	}
+	if stateDir := getStateDir(); stateDir != "" {
+		if err := scan(filepath.Join(stateDir, backupsStateDir, configDir)); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(backups, func(i, j int) bool {
+		if !backups[i].Time.Equal(backups[j].Time) {
+			return backups[i].Time.After(backups[j].Time)
+		}
+		return backups[i].Path < backups[j].Path
+	})
+	return backups, nil
+}
+
+// findBackup resolves a backup given as its number in `backups list`
+// (1 is the newest) or as a path.
+func findBackup(backups []backupFile, ref string) (backupFile, error) {
+	if n, err := strconv.Atoi(ref); err == nil {
+		if n < 1 || n > len(backups) {
+			return backupFile{}, fmt.Errorf("no backup number %d (there are %d)", n, len(backups))
+		}
+		return backups[n-1], nil
+	}
+	abs, err := filepath.Abs(ref)
+	if err != nil {
+		return backupFile{}, fmt.Errorf("failed to resolve %s: %w", ref, err)
+	}
+	for _, b := range backups {
+		if b.Path == abs || b.Path == ref {
+			return b, nil
+		}
+	}
+	return backupFile{}, fmt.Errorf("%s is not a backup of a Hyprland config file", ref)
+}
+
+// diffBackup returns a unified diff from the backup to the current file,
+// i.e. what has changed since the backup was taken.
+func diffBackup(b backupFile) (string, error) {
+	old, err := os.ReadFile(b.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read backup: %w", err)
+	}
+	current, _, err := readFileIfExists(b.Original)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", b.Original, err)
+	}
+	return unifiedDiff(b.Path, b.Original, string(old), current), nil
+}
+
+// restoreBackup copies a backup over the file it was taken from. The
+// current file is backed up first, so a restore can itself be undone.
+func restoreBackup(b backupFile) error {
+	data, err := os.ReadFile(b.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+
+	current, exists, err := readFileIfExists(b.Original)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", b.Original, err)
+	}
+	if exists {
+		if err := backupConfigFile(b.Original, []byte(current)); err != nil {
+			return fmt.Errorf("failed to create backup: %w", err)
+		}
+	}
+	return replaceConfigFile(b.Original, data)
+}
+
+// pruneBackups deletes backups beyond the newest keep of each file and
+// those older than keepDays, and returns what it deleted. A zero limit is
+// not applied. The newest backup of a file is never removed for its age
+// alone.
+func pruneBackups(keep, keepDays int, now time.Time) ([]backupFile, error) {
+	if keep <= 0 && keepDays <= 0 {
+		return nil, nil
+	}
+	backups, err := listBackups()
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := now.AddDate(0, 0, -keepDays)
+	seen := make(map[string]int)
+	var removed []backupFile
+	var errs []string
+	for _, b := range backups {
+		seen[b.Original]++
+		n := seen[b.Original]
+		tooMany := keep > 0 && n > keep
+		tooOld := keepDays > 0 && n > 1 && b.Time.Before(cutoff)
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := os.Remove(b.Path); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err.Error())
+			continue
+		}
+		removed = append(removed, b)
+	}
+	if len(errs) > 0 {
+		return removed, fmt.Errorf("failed to remove backups: %s", strings.Join(errs, "; "))
+	}
+	return removed, nil
+}
+
+// runBackups implements `hyprmon backups list|diff|restore|prune`.
+func runBackups(args []string, out io.Writer) error {
+	usage := fmt.Errorf("usage: hyprmon backups list | diff <n|path> | restore <n|path> | prune [--keep N] [--keep-days D]")
+	if len(args) == 0 {
+		return usage
+	}
+
+	switch args[0] {
+	case "list":
+		backups, err := listBackups()
+		if err != nil {
+			return err
+		}
+		if len(backups) == 0 {
+			fmt.Fprintln(out, "No backups found")
+			return nil
+		}
+		for i, b := range backups {
+			fmt.Fprintf(out, "%3d  %s  %s\n     %s\n", i+1, b.Time.Format("2006-01-02 15:04:05"), b.Original, b.Path)
+		}
+		return nil
+
+	case "diff", "restore":
+		if len(args) != 2 {
+			return usage
+		}
+		backups, err := listBackups()
+		if err != nil {
+			return err
+		}
+		b, err := findBackup(backups, args[1])
+		if err != nil {
+			return err
+		}
+		if args[0] == "diff" {
+			diff, err := diffBackup(b)
+			if err != nil {
+				return err
+			}
+			if diff == "" {
+				fmt.Fprintf(out, "%s is the same as %s\n", b.Original, b.Path)
+				return nil
+			}
+			fmt.Fprint(out, diff)
+			return nil
+		}
+		if err := restoreBackup(b); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Restored %s from %s\n", b.Original, b.Path)
+		return nil
+
+	case "prune":
+		s, err := loadSettings()
+		if err != nil {
+			return err
+		}
+		flags := flag.NewFlagSet("backups prune", flag.ContinueOnError)
+		flags.SetOutput(io.Discard)
+		keep := flags.Int("keep", s.BackupKeep, "Keep at most this many backups of each file")
+		keepDays := flags.Int("keep-days", s.BackupKeepDays, "Delete backups older than this many days")
+		if err := flags.Parse(args[1:]); err != nil || flags.NArg() > 0 {
+			return usage
+		}
+		if *keep <= 0 && *keepDays <= 0 {
+			return fmt.Errorf("no retention policy: set backup_keep or backup_keep_days in settings.json, or pass --keep/--keep-days")
+		}
+		removed, err := pruneBackups(*keep, *keepDays, time.Now())
+		for _, b := range removed {
+			fmt.Fprintf(out, "Removed %s\n", b.Path)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Removed %d backups\n", len(removed))
+		return nil
+	}
+	return usage
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupBackupTest points hyprmon at a temporary config dir and Hyprland
+// config, and returns the config path.
+func setupBackupTest(t *testing.T, s Settings) string {
+	t.Helper()
+	useTempConfigDir(t)
+	if err := saveSettings(&s); err != nil {
+		t.Fatal(err)
+	}
+
+	configPath := filepath.Join(t.TempDir(), "hyprland.conf")
+	writeTestFile(t, configPath, "current\n")
+	t.Setenv("HYPRLAND_CONFIG", configPath)
+	return configPath
+}
+
+func TestBackupsArePrunedOnlyByCommand(t *testing.T) {
+	configPath := setupBackupTest(t, Settings{BackupKeep: 2})
+	now := time.Now().Unix()
+	for i := 3; i >= 1; i-- {
+		writeTestFile(t, fmt.Sprintf("%s.bak.%d", configPath, now-int64(i)*60), fmt.Sprintf("old %d\n", i))
+	}
+
+	if err := backupConfigFile(configPath, []byte("current\n")); err != nil {
+		t.Fatalf("backupConfigFile() error = %v", err)
+	}
+	if err := backupConfigFile(configPath, []byte("current\n")); err != nil {
+		t.Fatalf("backupConfigFile() error = %v", err)
+	}
+
+	backups, err := listBackups()
+	if err != nil {
+		t.Fatalf("listBackups() error = %v", err)
+	}
+	if len(backups) != 5 {
+		t.Fatalf("listBackups() = %d backups, want all 5 kept by saving: %+v", len(backups), backups)
+	}
+	// Two backups in the same second get distinct names
+	if backups[0].Path == backups[1].Path || backups[0].Original != configPath {
+		t.Errorf("backups = %+v", backups)
+	}
+
+	// prune uses the retention from settings and says what it removed
+	var out bytes.Buffer
+	if err := runBackups([]string{"prune"}, &out); err != nil {
+		t.Fatalf("backups prune error = %v", err)
+	}
+	if !strings.Contains(out.String(), "Removed "+backups[4].Path+"\n") || !strings.HasSuffix(out.String(), "Removed 3 backups\n") {
+		t.Errorf("backups prune output:\n%s", out.String())
+	}
+	if after, _ := listBackups(); len(after) != 2 {
+		t.Errorf("listBackups() after prune = %+v, want the newest 2", after)
+	}
+}
+
+func TestBackupConfigFileInStateDir(t *testing.T) {
+	configPath := setupBackupTest(t, Settings{BackupLocation: backupLocationState})
+
+	if err := backupConfigFile(configPath, []byte("current\n")); err != nil {
+		t.Fatalf("backupConfigFile() error = %v", err)
+	}
+
+	if matches, _ := filepath.Glob(configPath + ".bak.*"); len(matches) != 0 {
+		t.Errorf("backup written next to the config: %v", matches)
+	}
+	backups, err := listBackups()
+	if err != nil {
+		t.Fatalf("listBackups() error = %v", err)
+	}
+	if len(backups) != 1 || backups[0].Original != configPath ||
+		!strings.HasPrefix(backups[0].Path, filepath.Join(getStateDir(), backupsStateDir)) {
+		t.Fatalf("listBackups() = %+v, want one backup of %s in the state dir", backups, configPath)
+	}
+}
+
+func TestPruneBackupsKeepsNewestByAge(t *testing.T) {
+	configPath := setupBackupTest(t, Settings{})
+	now := time.Now()
+	old := now.AddDate(0, 0, -30).Unix()
+	writeTestFile(t, fmt.Sprintf("%s.bak.%d", configPath, old), "older\n")
+	writeTestFile(t, fmt.Sprintf("%s.bak.%d", configPath, old+60), "newest\n")
+	sidecar := filepath.Join(filepath.Dir(configPath), "hyprmon.conf")
+	writeTestFile(t, fmt.Sprintf("%s.bak.%d", sidecar, now.Unix()), "recent\n")
+
+	removed, err := pruneBackups(0, 7, now)
+	if err != nil {
+		t.Fatalf("pruneBackups() error = %v", err)
+	}
+	if len(removed) != 1 || removed[0].Path != fmt.Sprintf("%s.bak.%d", configPath, old) {
+		t.Errorf("removed = %+v, want only the older hyprland.conf backup", removed)
+	}
+}
+
+func TestRunBackupsRestoreBacksUpCurrentFile(t *testing.T) {
+	configPath := setupBackupTest(t, Settings{})
+	backupPath := fmt.Sprintf("%s.bak.%d", configPath, time.Now().Add(-time.Hour).Unix())
+	writeTestFile(t, backupPath, "from backup\n")
+
+	var out bytes.Buffer
+	if err := runBackups([]string{"diff", "1"}, &out); err != nil {
+		t.Fatalf("backups diff error = %v", err)
+	}
+	if !strings.Contains(out.String(), "-from backup\n+current\n") {
+		t.Errorf("backups diff output:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := runBackups([]string{"restore", backupPath}, &out); err != nil {
+		t.Fatalf("backups restore error = %v", err)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil || string(data) != "from backup\n" {
+		t.Fatalf("config after restore = %q, %v", data, err)
+	}
+
+	backups, err := listBackups()
+	if err != nil {
+		t.Fatalf("listBackups() error = %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("listBackups() = %+v, want the restored-over file backed up too", backups)
+	}
+	if previous, _ := os.ReadFile(backups[0].Path); string(previous) != "current\n" {
+		t.Errorf("newest backup = %q, want the content before the restore", previous)
+	}
+
+	if err := runBackups([]string{"restore", "9"}, &out); err == nil {
+		t.Error("restoring a missing backup number succeeded")
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// procRoot and sysRoot are where the lid and power supply state is read
+// from. Tests point them at fixture directories.
+var (
+	procRoot = "/proc"
+	sysRoot  = "/sys"
+)
+
+// Lid and power states a profile can require
+const (
+	lidOpen      = "open"
+	lidClosed    = "closed"
+	powerAC      = "ac"
+	powerBattery = "battery"
+)
+
+// ProfileConditions restrict when a profile is picked by --auto and the
+// daemon. Empty fields don't restrict anything.
+type ProfileConditions struct {
+	// Lid is "open" or "closed"
+	Lid string `json:"lid,omitempty"`
+	// Power is "ac" or "battery"
+	Power string `json:"power,omitempty"`
+	// Time is a local time-of-day window such as "09:00-18:00". The end is
+	// exclusive, and a window may wrap past midnight ("22:00-06:00").
+	Time string `json:"time,omitempty"`
+}
+
+// systemState is what profile conditions are checked against.
+type systemState struct {
+	Lid   string // lidOpen or lidClosed
+	Power string // powerAC or powerBattery
+	Now   time.Time
+}
+
+// readLidState reads the lid switches under root/acpi/button/lid. The lid
+// counts as closed when any switch says so; machines without a lid count
+// as open, since their screens are usable.
+func readLidState(root string) string {
+	paths, _ := filepath.Glob(filepath.Join(root, "acpi", "button", "lid", "*", "state"))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		// "state:      closed"
+		if fields := strings.Fields(string(data)); len(fields) > 0 && fields[len(fields)-1] == lidClosed {
+			return lidClosed
+		}
+	}
+	return lidOpen
+}
+
+// readPowerState reads the power supplies under root/class/power_supply.
+// Batteries are skipped, as their online file (where there is one) does not
+// say whether the machine is plugged in. It is on AC when any adapter is
+// online, or when there are no adapters at all, as on most desktops.
+func readPowerState(root string) string {
+	paths, _ := filepath.Glob(filepath.Join(root, "class", "power_supply", "*", "online"))
+	adapters := 0
+	for _, path := range paths {
+		if kind, err := os.ReadFile(filepath.Join(filepath.Dir(path), "type")); err == nil && strings.TrimSpace(string(kind)) == "Battery" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		adapters++
+		if strings.TrimSpace(string(data)) == "1" {
+			return powerAC
+		}
+	}
+	if adapters == 0 {
+		return powerAC
+	}
+	return powerBattery
+}
+
+// readSystemState reads the lid and power state from the given procfs and
+// sysfs roots.
+func readSystemState(procRoot, sysRoot string, now time.Time) systemState {
+	return systemState{
+		Lid:   readLidState(procRoot),
+		Power: readPowerState(sysRoot),
+		Now:   now,
+	}
+}
+
+// currentSystemState reads the state of this machine.
+func currentSystemState() systemState {
+	return readSystemState(procRoot, sysRoot, time.Now())
+}
+
+// parseTimeWindow parses "HH:MM-HH:MM" into minutes since midnight.
+func parseTimeWindow(window string) (start, end int, err error) {
+	from, to, ok := strings.Cut(window, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid time window %q: want HH:MM-HH:MM", window)
+	}
+	parse := func(s string) (int, error) {
+		t, err := time.Parse("15:04", strings.TrimSpace(s))
+		if err != nil {
+			return 0, fmt.Errorf("invalid time window %q: want HH:MM-HH:MM", window)
+		}
+		return t.Hour()*60 + t.Minute(), nil
+	}
+	if start, err = parse(from); err != nil {
+		return 0, 0, err
+	}
+	if end, err = parse(to); err != nil {
+		return 0, 0, err
+	}
+	if start == end {
+		return 0, 0, fmt.Errorf("invalid time window %q: start and end are the same", window)
+	}
+	return start, end, nil
+}
+
+// validate checks the condition values, so a typo is reported instead of
+// silently never matching.
+func (c *ProfileConditions) validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.Lid != "" && c.Lid != lidOpen && c.Lid != lidClosed {
+		return fmt.Errorf("invalid lid condition %q: want open or closed", c.Lid)
+	}
+	if c.Power != "" && c.Power != powerAC && c.Power != powerBattery {
+		return fmt.Errorf("invalid power condition %q: want ac or battery", c.Power)
+	}
+	if c.Time != "" {
+		if _, _, err := parseTimeWindow(c.Time); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// satisfied reports whether state meets every condition.
+func (c *ProfileConditions) satisfied(state systemState) bool {
+	if c == nil {
+		return true
+	}
+	if c.Lid != "" && c.Lid != state.Lid {
+		return false
+	}
+	if c.Power != "" && c.Power != state.Power {
+		return false
+	}
+	if c.Time != "" {
+		start, end, err := parseTimeWindow(c.Time)
+		if err != nil {
+			return false
+		}
+		now := state.Now.Hour()*60 + state.Now.Minute()
+		if start < end {
+			return now >= start && now < end
+		}
+		return now >= start || now < end
+	}
+	return true
+}
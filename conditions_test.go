@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadSystemState(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     map[string]string
+		wantLid   string
+		wantPower string
+	}{
+		{
+			name: "laptop closed on battery",
+			files: map[string]string{
+				"proc/acpi/button/lid/LID0/state":      "state:      closed\n",
+				"sys/class/power_supply/AC/type":       "Mains\n",
+				"sys/class/power_supply/AC/online":     "0\n",
+				"sys/class/power_supply/BAT0/type":     "Battery\n",
+				"sys/class/power_supply/BAT0/online":   "1\n",
+				"sys/class/power_supply/BAT0/capacity": "80\n",
+			},
+			wantLid:   lidClosed,
+			wantPower: powerBattery,
+		},
+		{
+			name: "laptop open on USB-C power",
+			files: map[string]string{
+				"proc/acpi/button/lid/LID/state":            "state:      open\n",
+				"sys/class/power_supply/AC/type":            "Mains\n",
+				"sys/class/power_supply/AC/online":          "0\n",
+				"sys/class/power_supply/ucsi-source/type":   "USB\n",
+				"sys/class/power_supply/ucsi-source/online": "1\n",
+			},
+			wantLid:   lidOpen,
+			wantPower: powerAC,
+		},
+		{
+			name:      "desktop",
+			files:     map[string]string{},
+			wantLid:   lidOpen,
+			wantPower: powerAC,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for path, content := range tt.files {
+				path = filepath.Join(root, path)
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				writeTestFile(t, path, content)
+			}
+			got := readSystemState(filepath.Join(root, "proc"), filepath.Join(root, "sys"), time.Time{})
+			if got.Lid != tt.wantLid || got.Power != tt.wantPower {
+				t.Errorf("readSystemState() = lid %q, power %q; want %q, %q", got.Lid, got.Power, tt.wantLid, tt.wantPower)
+			}
+		})
+	}
+}
+
+func TestProfileConditionsSatisfied(t *testing.T) {
+	at := func(clock string) time.Time {
+		tm, err := time.Parse("15:04", clock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+
+	tests := []struct {
+		name       string
+		conditions *ProfileConditions
+		state      systemState
+		want       bool
+	}{
+		{"no conditions", nil, systemState{Lid: lidClosed, Power: powerBattery}, true},
+		{"lid closed", &ProfileConditions{Lid: lidClosed}, systemState{Lid: lidClosed}, true},
+		{"lid open", &ProfileConditions{Lid: lidClosed}, systemState{Lid: lidOpen}, false},
+		{"on battery", &ProfileConditions{Power: powerAC}, systemState{Power: powerBattery}, false},
+		{"inside window", &ProfileConditions{Time: "09:00-18:00"}, systemState{Now: at("09:00")}, true},
+		{"end is exclusive", &ProfileConditions{Time: "09:00-18:00"}, systemState{Now: at("18:00")}, false},
+		{"window past midnight, late", &ProfileConditions{Time: "22:00-06:00"}, systemState{Now: at("23:30")}, true},
+		{"window past midnight, early", &ProfileConditions{Time: "22:00-06:00"}, systemState{Now: at("05:59")}, true},
+		{"window past midnight, day", &ProfileConditions{Time: "22:00-06:00"}, systemState{Now: at("12:00")}, false},
+		{"all must hold", &ProfileConditions{Lid: lidOpen, Power: powerAC}, systemState{Lid: lidOpen, Power: powerBattery}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.conditions.satisfied(tt.state); got != tt.want {
+				t.Errorf("satisfied() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, bad := range []ProfileConditions{{Lid: "shut"}, {Power: "mains"}, {Time: "9-5"}, {Time: "10:00-10:00"}} {
+		if err := bad.validate(); err == nil {
+			t.Errorf("validate(%+v) accepted an invalid condition", bad)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// confirmRevertTimeout is how long the TUI waits for the user to confirm a
+// freshly applied layout before rolling it back.
+const confirmRevertTimeout = 15 * time.Second
+
+var errLayoutNotConfirmed = errors.New("new configuration was not confirmed and has been reverted")
+
+// confirmTickMsg drives the countdown. id ties a tick to the confirmation it
+// was started for, so ticks left over from an earlier prompt are ignored.
+type confirmTickMsg struct {
+	id int
+}
+
+func confirmTickCmd(id int) tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return confirmTickMsg{id: id}
+	})
+}
+
+// startConfirmRevert shows the "keep this configuration?" prompt and starts
+// its countdown.
+func (m *model) startConfirmRevert() tea.Cmd {
+	m.ConfirmID++
+	m.ShowConfirmRevert = true
+	m.ConfirmDeadline = time.Now().Add(confirmRevertTimeout)
+	return confirmTickCmd(m.ConfirmID)
+}
+
+// confirmSecondsLeft returns the whole seconds remaining on the countdown.
+func (m model) confirmSecondsLeft() int {
+	left := time.Until(m.ConfirmDeadline)
+	if left <= 0 {
+		return 0
+	}
+	return int((left + time.Second - 1) / time.Second)
+}
+
+func (m model) updateConfirmRevert(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case confirmTickMsg:
+		if msg.id != m.ConfirmID {
+			return m, nil
+		}
+		if m.confirmSecondsLeft() == 0 {
+			m.ShowConfirmRevert = false
+			m.Status = "No confirmation received, reverting..."
+			return m, revertCmd()
+		}
+		return m, confirmTickCmd(m.ConfirmID)
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "y", "Y", "enter":
+			m.ShowConfirmRevert = false
+			m.Status = "Configuration kept"
+			if len(m.ApplyDrift) > 0 {
+				m.Status += " | Hyprland adjusted: " + formatDrift(m.ApplyDrift)
+			}
+			return m, nil
+		case "n", "N", "esc", "z", "Z":
+			m.ShowConfirmRevert = false
+			m.Status = "Reverting..."
+			return m, revertCmd()
+		case "ctrl+c", "q":
+			// Never leave an unconfirmed layout behind on exit.
+			m.ShowConfirmRevert = false
+			return m, tea.Sequence(revertCmd(), tea.Quit)
+		}
+		return m, nil
+
+	case tea.WindowSizeMsg:
+		m.World.TermW = msg.Width
+		m.World.TermH = msg.Height
+		return m, nil
+	}
+
+	return m, nil
+}
+
+func (m model) renderConfirmRevert() string {
+	dialogStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("214")).
+		Padding(1, 3)
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("214"))
+
+	countdownStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("9")).
+		Bold(true)
+
+	helpStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	var content strings.Builder
+	content.WriteString(titleStyle.Render("Keep this configuration?"))
+	content.WriteString("\n\n")
+	content.WriteString(countdownStyle.Render(fmt.Sprintf("Reverting in %ds", m.confirmSecondsLeft())))
+	content.WriteString("\n\n")
+	if len(m.ApplyDrift) > 0 {
+		driftStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+		content.WriteString("Hyprland did not apply everything as requested:\n")
+		for _, d := range m.ApplyDrift {
+			content.WriteString(driftStyle.Render("  " + d.String()))
+			content.WriteString("\n")
+		}
+		content.WriteString("\n")
+	}
+	content.WriteString(helpStyle.Render("Y/Enter: Keep  •  N/Esc: Revert now"))
+
+	width, height := m.World.TermW, m.World.TermH
+	if width <= 0 {
+		width = 80
+	}
+	if height <= 0 {
+		height = 24
+	}
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, dialogStyle.Render(content.String()))
+}
+
+// confirmKeepLayout asks on out whether to keep the new layout and waits up
+// to timeout for a "y"/"yes" line on in. Anything else or running out of
+// time counts as "no". EOF is no answer at all, so the countdown goes on.
+func confirmKeepLayout(in io.Reader, out io.Writer, timeout time.Duration) bool {
+	answers := make(chan string, 1)
+	go func() {
+		line, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil && line == "" {
+			return
+		}
+		answers <- line
+	}()
+
+	deadline := time.Now().Add(timeout)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	prompt := func() {
+		left := time.Until(deadline).Round(time.Second)
+		if left < 0 {
+			left = 0
+		}
+		_, _ = fmt.Fprintf(out, "\rKeep this configuration? [y/N] Reverting in %ds ", int(left/time.Second))
+	}
+	prompt()
+
+	for {
+		select {
+		case answer := <-answers:
+			_, _ = fmt.Fprintln(out)
+			answer = strings.ToLower(strings.TrimSpace(answer))
+			return answer == "y" || answer == "yes"
+		case <-ticker.C:
+			prompt()
+		case <-timer.C:
+			_, _ = fmt.Fprintln(out)
+			return false
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestConfirmKeepLayout(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{"yes", "y\n", true},
+		{"full yes", "YES\n", true},
+		{"no", "n\n", false},
+		{"empty line", "\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			got := confirmKeepLayout(strings.NewReader(tt.input), &out, time.Minute)
+			if got != tt.want {
+				t.Errorf("confirmKeepLayout(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+			if !strings.Contains(out.String(), "Keep this configuration?") {
+				t.Errorf("prompt not written, got %q", out.String())
+			}
+		})
+	}
+}
+
+func TestAskConflictPolicy(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   conflictPolicy
+		wantOK bool
+	}{
+		{"o\n", conflictOverwrite, true},
+		{"Overwrite\n", conflictOverwrite, true},
+		{"m\n", conflictMerge, true},
+		{" merge \n", conflictMerge, true},
+		{"\n", conflictAsk, false},
+		{"a\n", conflictAsk, false},
+		{"", conflictAsk, false},
+	}
+	edited := &configEditedError{Path: "/home/me/.config/hypr/monitors.conf"}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		got, ok := askConflictPolicy(strings.NewReader(tt.input), &out, edited)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("askConflictPolicy(%q) = %v, %v, want %v, %v", tt.input, got, ok, tt.want, tt.wantOK)
+		}
+		if !strings.Contains(out.String(), "monitors.conf was edited") || !strings.Contains(out.String(), "[o]verwrite, [m]erge") {
+			t.Errorf("prompt = %q", out.String())
+		}
+	}
+}
+
+func TestConfirmKeepLayoutTimesOut(t *testing.T) {
+	// A reader that never returns simulates nobody at the keyboard.
+	blocked := make(blockingReader)
+	defer close(blocked)
+
+	start := time.Now()
+	if confirmKeepLayout(blocked, &strings.Builder{}, 50*time.Millisecond) {
+		t.Fatal("confirmKeepLayout() = true on timeout, want false")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("confirmKeepLayout() took %v to time out", elapsed)
+	}
+}
+
+func TestConfirmKeepLayoutWaitsOutEOF(t *testing.T) {
+	start := time.Now()
+	if confirmKeepLayout(strings.NewReader(""), &strings.Builder{}, 100*time.Millisecond) {
+		t.Fatal("confirmKeepLayout() = true on EOF, want false")
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("confirmKeepLayout() gave up after %v on EOF, want the full timeout", elapsed)
+	}
+}
+
+type blockingReader chan struct{}
+
+func (b blockingReader) Read([]byte) (int, error) {
+	<-b
+	return 0, errors.New("closed")
+}
+
+func TestConfirmRevertTick(t *testing.T) {
+	m := model{}
+	m.startConfirmRevert()
+
+	// A tick from an earlier prompt must not revert the current one.
+	next, cmd := m.Update(confirmTickMsg{id: m.ConfirmID - 1})
+	m = next.(model)
+	if !m.ShowConfirmRevert || cmd != nil {
+		t.Fatal("stale tick changed the prompt")
+	}
+
+	// Still counting down: keep ticking.
+	next, cmd = m.Update(confirmTickMsg{id: m.ConfirmID})
+	m = next.(model)
+	if !m.ShowConfirmRevert || cmd == nil {
+		t.Fatal("tick before the deadline should schedule the next tick")
+	}
+
+	// Deadline passed: close the prompt and revert.
+	m.ConfirmDeadline = time.Now().Add(-time.Second)
+	next, cmd = m.Update(confirmTickMsg{id: m.ConfirmID})
+	m = next.(model)
+	if m.ShowConfirmRevert || cmd == nil {
+		t.Fatal("expired countdown should close the prompt and revert")
+	}
+}
+
+func TestConfirmRevertKeep(t *testing.T) {
+	m := model{}
+	m.startConfirmRevert()
+
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(model)
+	if m.ShowConfirmRevert || cmd != nil {
+		t.Fatal("enter should keep the layout without reverting")
+	}
+
+	// The pending tick of the closed prompt is a no-op.
+	next, cmd = m.Update(confirmTickMsg{id: m.ConfirmID})
+	if next.(model).ShowConfirmRevert || cmd != nil {
+		t.Fatal("tick after confirming should be ignored")
+	}
+}
+
+func TestApplyProfileWithConfirmRevertsWhenDeclined(t *testing.T) {
+	shortVerifySettle(t)
+	fake := startFakeHyprland(t, func(request string) string {
+		if request == "j/monitors all" {
+			return `[{"name":"DP-1","make":"Dell Inc.","model":"U2720Q","serial":"ABC","width":2560,"height":1440,"refreshRate":60,"scale":1,"x":0,"y":0}]`
+		}
+		return "ok"
+	})
+	useTempConfigDir(t)
+	configPath := filepath.Join(t.TempDir(), "hyprland.conf")
+	t.Setenv("HYPRLAND_CONFIG", configPath)
+
+	if err := saveProfile("scaled", []Monitor{{
+		Name: "DP-1", HardwareID: "Dell Inc./U2720Q/ABC",
+		PxW: 2560, PxH: 1440, Hz: 60, Scale: 2, Active: true,
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveSettings(&Settings{Hooks: Hooks{
+		PreApply:  []string{"echo stopping bar"},
+		PostApply: []string{`grep -o '"Scale": [0-9]*' "$HYPRMON_LAYOUT"`},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var output bytes.Buffer
+	_, err := applyProfileWithConfirm("scaled", conflictAsk, func() bool { return false }, &output)
+	if !errors.Is(err, errLayoutNotConfirmed) {
+		t.Fatalf("applyProfileWithConfirm() error = %v, want errLayoutNotConfirmed", err)
+	}
+	// post_apply still runs after pre_apply, with the layout that was restored
+	if want := "stopping bar\n\"Scale\": 1\n"; output.String() != want {
+		t.Errorf("hook output = %q, want %q", output.String(), want)
+	}
+
+	var keywords []string
+	for _, request := range fake.Requests() {
+		if request == "reload" {
+			t.Fatal("declined profile must not reload the config")
+		}
+		if strings.HasPrefix(request, "keyword monitor ") {
+			keywords = append(keywords, request)
+		}
+	}
+	if len(keywords) != 2 {
+		t.Fatalf("keyword requests = %q, want apply then revert", keywords)
+	}
+	if !strings.Contains(keywords[0], ",2.00") || !strings.Contains(keywords[1], ",1.00") {
+		t.Errorf("keyword requests = %q, want scale 2 then back to 1", keywords)
+	}
+	if fileExists(configPath) {
+		t.Error("declined profile must not be written to the config file")
+	}
+}
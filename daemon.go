@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// daemonDebounce is how long the event stream has to stay quiet after a
+// hotplug event before the daemon re-evaluates profiles. Docking usually
+// produces a burst of add/remove events within a few hundred milliseconds.
+const daemonDebounce = 1500 * time.Millisecond
+
+// conditionPollInterval is how often the daemon re-checks the lid, power
+// and time-of-day conditions of profiles, which produce no Hyprland events.
+const conditionPollInterval = 10 * time.Second
+
+// hotplugEvents are the socket2 events that can change which monitors are
+// connected (or which rules Hyprland is using for them).
+var hotplugEvents = map[string]bool{
+	"monitoradded":   true,
+	"monitoraddedv2": true,
+	"monitorremoved": true,
+	"configreloaded": true,
+}
+
+// parseHyprEvent splits a socket2 line of the form EVENT>>DATA.
+func parseHyprEvent(line string) (name, data string, ok bool) {
+	name, data, ok = strings.Cut(strings.TrimSpace(line), ">>")
+	if !ok || name == "" {
+		return "", "", false
+	}
+	return name, data, true
+}
+
+// subscribeHyprEvents connects to the Hyprland event socket (.socket2.sock)
+func subscribeHyprEvents() (net.Conn, error) {
+	path, err := hyprSocketPath(hyprEventSocket)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("unix", path, hyprctlTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to hyprland event socket: %w", err)
+	}
+	return conn, nil
+}
+
+// watchHotplugEvents reads socket2 lines from r and calls trigger once per
+// burst of hotplug events, after no further hotplug event has arrived for
+// debounce. trigger runs on the caller's goroutine, so events produced by
+// trigger itself (e.g. configreloaded after applying a profile) start a new
+// burst instead of re-entering it. A receive on wake counts as a hotplug
+// event; wake may be nil. Returns when ctx is done or r is closed.
+func watchHotplugEvents(ctx context.Context, r io.Reader, debounce time.Duration, wake <-chan struct{}, trigger func()) error {
+	events := make(chan string)
+	scanErr := make(chan error, 1)
+
+	go func() {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			name, _, ok := parseHyprEvent(scanner.Text())
+			if !ok || !hotplugEvents[name] {
+				continue
+			}
+			select {
+			case events <- name:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			scanErr <- err
+			return
+		}
+		scanErr <- io.EOF
+	}()
+
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+
+		case <-events:
+			timer.Reset(debounce)
+
+		case <-wake:
+			timer.Reset(debounce)
+
+		case <-timer.C:
+			trigger()
+
+		case err := <-scanErr:
+			timer.Stop()
+			if err == io.EOF {
+				return fmt.Errorf("hyprland event stream closed")
+			}
+			return fmt.Errorf("failed to read hyprland events: %w", err)
+		}
+	}
+}
+
+// satisfiedProfiles lists the saved profiles whose conditions state meets,
+// one name per line, for watchConditions to compare.
+func satisfiedProfiles(state systemState) string {
+	names, _ := listOrderedProfiles()
+	var satisfied []string
+	for _, name := range names {
+		profile, err := loadProfile(name)
+		if err == nil && profile.Conditions.satisfied(state) {
+			satisfied = append(satisfied, name)
+		}
+	}
+	return strings.Join(satisfied, "\n")
+}
+
+// watchConditions sends on wake whenever the lid, power or time of day
+// changes which profiles' conditions hold. Returns when ctx is done.
+func watchConditions(ctx context.Context, interval time.Duration, wake chan<- struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := satisfiedProfiles(currentSystemState())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if now := satisfiedProfiles(currentSystemState()); now != last {
+			last = now
+			select {
+			case wake <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// loadProfilesInOrder loads every saved profile in the user's order,
+// skipping (and logging) profiles that cannot be read.
+func loadProfilesInOrder() ([]*Profile, error) {
+	names, err := listOrderedProfiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list profiles: %w", err)
+	}
+
+	var profiles []*Profile
+	for _, name := range names {
+		profile, err := loadProfile(name)
+		if err == nil {
+			err = profile.Conditions.validate()
+		}
+		if err != nil {
+			log.Printf("skipping profile %q: %v", name, err)
+			continue
+		}
+		if profile.Name == "" {
+			profile.Name = name
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+// hotplugState remembers what the daemon last applied so that the events
+// caused by its own apply (configreloaded, monitoradded for re-enabled
+// outputs) don't make it apply the same profile again. Hyprland may clamp
+// a requested mode, in which case the profile never compares as active.
+type hotplugState struct {
+	lastSet     string
+	lastProfile string
+	lastLid     string
+
+	// The layout and workspaces after the last trigger. Hyprland moves
+	// workspaces off an unplugged monitor before the daemon hears of it,
+	// so this is where the daemon learns where they were.
+	monitors   []Monitor
+	workspaces []hyprWorkspace
+}
+
+// rememberUnplugged records the monitor each workspace was on for the
+// monitors that have gone since the last trigger.
+func (s *hotplugState) rememberUnplugged() error {
+	if s.monitors == nil {
+		return nil
+	}
+	current, err := readMonitors()
+	if err != nil {
+		return err
+	}
+	return rememberWorkspaceHomes(s.workspaces, s.monitors, current)
+}
+
+// followWorkspaces migrates workspaces left on disabled monitors, moves
+// workspaces back to monitors that returned and takes a new snapshot.
+func (s *hotplugState) followWorkspaces() error {
+	current, err := readMonitors()
+	if err != nil {
+		return err
+	}
+	if err := migrateOrphanedWorkspaces(s.monitors, current, s.workspaces); err != nil {
+		return err
+	}
+	workspaces, err := readWorkspaces()
+	if err != nil {
+		return fmt.Errorf("failed to read workspaces: %w", err)
+	}
+	s.monitors, s.workspaces = current, workspaces
+	return nil
+}
+
+// connectedSetKey identifies the set of connected monitors independent of
+// connector names and ordering.
+func connectedSetKey(current []Monitor) string {
+	keys := make([]string, 0, len(current))
+	for _, m := range current {
+		keys = append(keys, monitorKey(m))
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, "|")
+}
+
+// apply picks the best-matching profile for the connected monitors and
+// applies it unless it is already active. With the lid closed the
+// profile's internal panels stay off. Returns the profile name ("" when
+// none matched) and whether anything was applied.
+func (s *hotplugState) apply(lid string) (string, bool, error) {
+	match, current, err := findAutoProfile()
+	if errors.Is(err, errNoMatchingProfile) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	name := match.Profile.Name
+
+	setKey := connectedSetKey(current)
+	if setKey == s.lastSet && name == s.lastProfile && lid == s.lastLid {
+		return name, false, nil
+	}
+	wanted := match.Profile
+	if lid == lidClosed {
+		closed := *wanted
+		closed.Monitors, _ = lidClosedLayout(resolveProfileMonitors(wanted.Monitors, current))
+		wanted = &closed
+	}
+	if isProfileApplied(wanted, current) {
+		s.lastSet, s.lastProfile, s.lastLid = setKey, name, lid
+		return name, false, nil
+	}
+
+	var output bytes.Buffer
+	drifts, err := applyProfileForLid(name, lid, conflictAsk, nil, &output)
+	var edited *configEditedError
+	if errors.As(err, &edited) {
+		// Nobody is there to answer, so keep the hand-written rules for
+		// other monitors
+		log.Printf("profile %q: %v; keeping its rules for other monitors (the old file is backed up)", name, err)
+		drifts, err = applyProfileForLid(name, lid, conflictMerge, nil, &output)
+	}
+	for _, line := range strings.Split(output.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			log.Printf("profile %q hook: %s", name, line)
+		}
+	}
+	if err != nil {
+		return name, false, err
+	}
+	for _, d := range drifts {
+		log.Printf("profile %q: %s", name, d)
+	}
+	s.lastSet, s.lastProfile, s.lastLid = setKey, name, lid
+	return name, true, nil
+}
+
+// runDaemon subscribes to Hyprland's event stream and applies the matching
+// profile whenever monitors are plugged or unplugged.
+func runDaemon(ctx context.Context) error {
+	conn, err := subscribeHyprEvents()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	// Unblock the reader when the daemon is asked to stop.
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+	}()
+
+	state := &hotplugState{}
+	trigger := func() {
+		if err := state.rememberUnplugged(); err != nil {
+			log.Printf("failed to remember workspaces: %v", err)
+		}
+		lid := readLidState(procRoot)
+		name, applied, err := state.apply(lid)
+		switch {
+		case err != nil:
+			log.Printf("failed to apply profile %q: %v", name, err)
+		case name == "":
+			log.Printf("no profile matches the connected monitors")
+		case applied:
+			log.Printf("applied profile %q", name)
+		}
+		if changed, err := followLid(lid); err != nil {
+			log.Printf("failed to follow the lid: %v", err)
+		} else if changed {
+			log.Printf("lid %s: updated the internal panel", lid)
+		}
+		if err := state.followWorkspaces(); err != nil {
+			log.Printf("failed to migrate workspaces: %v", err)
+		}
+	}
+
+	log.Printf("hyprmon daemon watching for monitor changes")
+
+	// Handle whatever is plugged in right now before waiting for events.
+	trigger()
+
+	wake := make(chan struct{})
+	go watchConditions(ctx, conditionPollInterval, wake)
+	go watchLid(ctx, procRoot, lidPollInterval, wake)
+
+	err = watchHotplugEvents(ctx, conn, daemonDebounce, wake, trigger)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseHyprEvent(t *testing.T) {
+	tests := []struct {
+		line     string
+		wantName string
+		wantData string
+		wantOK   bool
+	}{
+		{"monitoradded>>DP-1", "monitoradded", "DP-1", true},
+		{"monitoraddedv2>>2,DP-1,Dell Inc. U2720Q", "monitoraddedv2", "2,DP-1,Dell Inc. U2720Q", true},
+		{"configreloaded>>", "configreloaded", "", true},
+		{"garbage", "", "", false},
+		{">>DP-1", "", "", false},
+	}
+
+	for _, tt := range tests {
+		name, data, ok := parseHyprEvent(tt.line)
+		if name != tt.wantName || data != tt.wantData || ok != tt.wantOK {
+			t.Errorf("parseHyprEvent(%q) = (%q, %q, %v), want (%q, %q, %v)",
+				tt.line, name, data, ok, tt.wantName, tt.wantData, tt.wantOK)
+		}
+	}
+}
+
+func TestWatchHotplugEventsDebouncesBursts(t *testing.T) {
+	fake := startFakeHyprland(t, func(string) string { return "ok" })
+	listener, err := net.Listen("unix", filepath.Join(fake.runtimeDir, "hypr", "test", hyprEventSocket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	server := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			server <- conn
+		}
+	}()
+
+	conn, err := subscribeHyprEvents()
+	if err != nil {
+		t.Fatalf("subscribeHyprEvents() error = %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	events := <-server
+
+	var triggers atomic.Int32
+	done := make(chan error, 1)
+	go func() {
+		done <- watchHotplugEvents(context.Background(), conn, 50*time.Millisecond, nil, func() {
+			triggers.Add(1)
+		})
+	}()
+
+	// A dock burst: several hotplug events plus unrelated noise.
+	_, _ = io.WriteString(events, strings.Join([]string{
+		"monitoradded>>DP-1",
+		"workspace>>2",
+		"monitoraddedv2>>2,DP-1,Dell Inc. U2720Q",
+		"monitorremoved>>eDP-1",
+		"",
+	}, "\n"))
+
+	waitFor(t, func() bool { return triggers.Load() == 1 })
+
+	// Unrelated events alone must not trigger.
+	_, _ = io.WriteString(events, "activewindow>>kitty,~\n")
+	time.Sleep(150 * time.Millisecond)
+	if got := triggers.Load(); got != 1 {
+		t.Fatalf("triggers after non-hotplug event = %d, want 1", got)
+	}
+
+	_, _ = io.WriteString(events, "configreloaded>>\n")
+	waitFor(t, func() bool { return triggers.Load() == 2 })
+
+	_ = events.Close()
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "closed") {
+			t.Fatalf("watchHotplugEvents() error = %v, want stream closed", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("watchHotplugEvents() did not return after the stream closed")
+	}
+}
+
+func TestWatchHotplugEventsStopsOnCancel(t *testing.T) {
+	reader, writer := io.Pipe()
+	defer func() { _ = writer.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- watchHotplugEvents(ctx, reader, time.Hour, nil, func() {})
+	}()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Fatalf("watchHotplugEvents() error = %v, want context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("watchHotplugEvents() did not return after cancel")
+	}
+}
+
+func TestHotplugStateSkipsActiveProfile(t *testing.T) {
+	fake := startFakeHyprland(t, func(request string) string {
+		if request == "j/monitors all" {
+			return `[{"name":"DP-1","make":"Dell Inc.","model":"U2720Q","serial":"ABC","width":2560,"height":1440,"refreshRate":60,"scale":1,"x":0,"y":0}]`
+		}
+		return "ok"
+	})
+	useTempConfigDir(t)
+
+	if err := saveProfile("desk", []Monitor{{
+		Name: "DP-1", HardwareID: "Dell Inc./U2720Q/ABC",
+		PxW: 2560, PxH: 1440, Hz: 60, Scale: 1, Active: true,
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	state := &hotplugState{}
+	name, applied, err := state.apply(lidOpen)
+	if err != nil {
+		t.Fatalf("apply() error = %v", err)
+	}
+	if name != "desk" || applied {
+		t.Fatalf("apply() = (%q, %v), want (\"desk\", false)", name, applied)
+	}
+	for _, request := range fake.Requests() {
+		if strings.HasPrefix(request, "keyword") || request == "reload" {
+			t.Fatalf("already-active profile was re-applied: %q", fake.Requests())
+		}
+	}
+}
+
+func TestHotplugStateKeepsPanelOffWithLidClosed(t *testing.T) {
+	shortVerifySettle(t)
+	fake := startFakeHyprland(t, func(request string) string {
+		switch request {
+		case "j/monitors all", "j/monitors":
+			return `[{"name":"DP-1","make":"Dell Inc.","model":"U2720Q","serial":"ABC","width":2560,"height":1440,"refreshRate":60,"scale":1,"x":0,"y":0},` +
+				`{"name":"eDP-1","make":"BOE","model":"0x0BCA","width":2880,"height":1800,"refreshRate":120,"scale":2,"x":0,"y":0,"disabled":true}]`
+		case "j/workspaces":
+			return `[]`
+		}
+		return "ok"
+	})
+	useTempConfigDir(t)
+	configPath := filepath.Join(t.TempDir(), "hyprland.conf")
+	writeTestFile(t, configPath, "$mod = SUPER\n")
+	t.Setenv("HYPRLAND_CONFIG", configPath)
+
+	if err := saveProfile("docked", []Monitor{
+		{Name: "DP-1", HardwareID: "Dell Inc./U2720Q/ABC", PxW: 2560, PxH: 1440, Hz: 60, Scale: 1.25, Active: true},
+		{Name: "eDP-1", HardwareID: "BOE/0x0BCA", PxW: 2880, PxH: 1800, Hz: 120, Scale: 2, X: 2048, Active: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	state := &hotplugState{}
+	if name, applied, err := state.apply(lidClosed); err != nil || name != "docked" || !applied {
+		t.Fatalf("apply(closed) = (%q, %v, %v), want docked applied", name, applied, err)
+	}
+	for _, request := range fake.Requests() {
+		if strings.HasPrefix(request, "keyword monitor eDP-1,") && request != "keyword monitor eDP-1,disable" {
+			t.Errorf("internal panel turned on with the lid closed: %q", request)
+		}
+	}
+	// The panel is remembered as the profile has it, for opening the lid
+	if panels, _ := loadLidPanels(); len(panels) != 1 || panels[0].Name != "eDP-1" || panels[0].X != 2048 {
+		t.Errorf("lid state = %+v, want the profile's eDP-1", panels)
+	}
+	if data, _ := os.ReadFile(configPath); !strings.Contains(string(data), "monitor=eDP-1,disable") {
+		t.Errorf("config doesn't keep the panel off:\n%s", data)
+	}
+
+	// Opening the lid applies the profile again, with the panel
+	if _, applied, err := state.apply(lidOpen); err != nil || !applied {
+		t.Fatalf("apply(open) = %v, %v, want the profile applied again", applied, err)
+	}
+}
+
+// waitFor polls cond until it holds or a generous deadline passes.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before deadline")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
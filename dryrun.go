@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// fileChange is a file that saving would create or rewrite.
+type fileChange struct {
+	Path   string
+	Exists bool
+	Old    string
+	New    string
+}
+
+// dryRunPlan is everything an apply and/or save would do: the requests
+// sent to Hyprland, in order, and the config files that would change.
+type dryRunPlan struct {
+	Commands []string
+	Changes  []fileChange
+}
+
+// readFileIfExists returns the file's content, or "" and false if it does
+// not exist.
+func readFileIfExists(path string) (string, bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return string(data), true, nil
+}
+
+// plannedApplyCommands returns the IPC requests applyMonitors would send.
+func plannedApplyCommands(monitors []Monitor) ([]string, error) {
+	target, err := getConfigTarget()
+	if err != nil {
+		return nil, fmt.Errorf("could not determine config path: %w", err)
+	}
+
+	if target.Format == configFormatLua {
+		command, err := luaApplyCommand(orderMonitorsForApply(monitors))
+		if err != nil {
+			return nil, err
+		}
+		return []string{command}, nil
+	}
+
+	var commands []string
+	for _, m := range orderMonitorsForApply(monitors) {
+		command, err := hyprlangApplyCommand(m)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build rule for monitor %s: %w", m.Name, err)
+		}
+		commands = append(commands, command)
+	}
+	return commands, nil
+}
+
+// plannedConfigChanges returns what writeConfig would write, without
+// writing anything or creating backups.
+func plannedConfigChanges(monitors []Monitor, workspaces []WorkspaceRule) ([]fileChange, error) {
+	target, err := getConfigTarget()
+	if err != nil {
+		return nil, fmt.Errorf("could not determine config path: %w", err)
+	}
+
+	switch target.Format {
+	case configFormatLua:
+		sidecarPath := luaSidecarPath(target.Path)
+		sidecar, sidecarExists, err := readFileIfExists(sidecarPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read lua monitor config: %w", err)
+		}
+		newSidecar, err := renderLuaMonitorConfig(sidecar, monitors, workspaces)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update lua monitor config: %w", err)
+		}
+		mainConfig, mainExists, err := readFileIfExists(target.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read lua config: %w", err)
+		}
+		return []fileChange{
+			{Path: sidecarPath, Exists: sidecarExists, Old: sidecar, New: newSidecar},
+			{Path: target.Path, Exists: mainExists, Old: mainConfig, New: ensureHyprmonLuaRequire(mainConfig)},
+		}, nil
+	default:
+		s, err := loadSettings()
+		if err != nil {
+			return nil, err
+		}
+		input, err := os.ReadFile(target.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config: %w", err)
+		}
+		if getHyprlangMode(s) == hyprlangModeSidecar {
+			content, err := renderHyprlangSourceConfig(string(input), monitors)
+			if err != nil {
+				return nil, fmt.Errorf("failed to update config: %w", err)
+			}
+			sidecarPath := hyprlangSidecarPath(target.Path)
+			sidecar, sidecarExists, err := readFileIfExists(sidecarPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read hyprmon.conf: %w", err)
+			}
+			return []fileChange{
+				{Path: sidecarPath, Exists: sidecarExists, Old: sidecar, New: generateHyprlangSidecarConfig(monitors, workspaces, getMonitorSyntax(s))},
+				{Path: target.Path, Exists: true, Old: string(input), New: content},
+			}, nil
+		}
+		rulesPath, err := hyprlangRulesFile(target.Path, monitors)
+		if err != nil {
+			return nil, err
+		}
+		if rulesPath != target.Path {
+			if input, err = os.ReadFile(rulesPath); err != nil {
+				return nil, fmt.Errorf("failed to read config: %w", err)
+			}
+		}
+		content, err := renderHyprlangConfig(string(input), monitors, workspaces, getMonitorSyntax(s))
+		if err != nil {
+			return nil, fmt.Errorf("failed to update config: %w", err)
+		}
+		return []fileChange{
+			{Path: rulesPath, Exists: true, Old: string(input), New: content},
+		}, nil
+	}
+}
+
+// planDryRun describes what applying (live) and/or saving (config file
+// plus reload) monitors and workspace rules would do.
+func planDryRun(monitors []Monitor, workspaces []WorkspaceRule, apply, save bool) (dryRunPlan, error) {
+	var plan dryRunPlan
+	if apply {
+		commands, err := plannedApplyCommands(monitors)
+		if err != nil {
+			return dryRunPlan{}, err
+		}
+		plan.Commands = commands
+		if len(workspaces) > 0 {
+			live, err := readWorkspaces()
+			if err != nil {
+				return dryRunPlan{}, fmt.Errorf("failed to read workspaces: %w", err)
+			}
+			plan.Commands = append(plan.Commands, workspaceMoveCommands(workspaces, monitors, live)...)
+		}
+	}
+	if save {
+		changes, err := plannedConfigChanges(monitors, workspaces)
+		if err != nil {
+			return dryRunPlan{}, err
+		}
+		plan.Changes = changes
+		plan.Commands = append(plan.Commands, "reload")
+	}
+	return plan, nil
+}
+
+// planProfile describes what `--profile name` would do.
+func planProfile(name string) (dryRunPlan, error) {
+	profile, err := loadProfile(name)
+	if err != nil {
+		return dryRunPlan{}, fmt.Errorf("failed to load profile %s: %w", name, err)
+	}
+
+	currentMonitors, err := readMonitors()
+	if err != nil {
+		return dryRunPlan{}, fmt.Errorf("failed to read current monitors: %w", err)
+	}
+
+	resolved := resolveProfileMonitors(profile.Monitors, currentMonitors)
+	if len(resolved) == 0 {
+		return dryRunPlan{}, fmt.Errorf("no monitors from profile %q are currently connected", name)
+	}
+	return planDryRun(resolved, profile.Workspaces, true, true)
+}
+
+// planAutoProfile describes what `--auto` would do.
+func planAutoProfile() (dryRunPlan, error) {
+	match, _, err := findAutoProfile()
+	if err != nil {
+		return dryRunPlan{}, err
+	}
+	return planProfile(match.Profile.Name)
+}
+
+// planRevert describes what `--revert` would do.
+func planRevert() (dryRunPlan, error) {
+	monitors, err := rollbackMonitors()
+	if err != nil {
+		return dryRunPlan{}, err
+	}
+	return planDryRun(monitors, activeProfileWorkspaces(monitors), true, true)
+}
+
+func (p dryRunPlan) String() string {
+	var b strings.Builder
+	if len(p.Commands) > 0 {
+		b.WriteString("# Requests that would be sent to Hyprland\n")
+		for _, command := range p.Commands {
+			b.WriteString(command)
+			b.WriteString("\n")
+		}
+	}
+	for _, change := range p.Changes {
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		oldName := change.Path
+		if !change.Exists {
+			oldName = "/dev/null"
+		}
+		diff := unifiedDiff(oldName, change.Path, change.Old, change.New)
+		if diff == "" {
+			fmt.Fprintf(&b, "# %s would not change\n", change.Path)
+			continue
+		}
+		fmt.Fprintf(&b, "# Changes to %s\n", change.Path)
+		b.WriteString(diff)
+	}
+	return b.String()
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{
+			name: "identical",
+			old:  "a\nb\n",
+			new:  "a\nb\n",
+			want: "",
+		},
+		{
+			name: "single replacement with context",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n",
+			new:  "1\n2\n3\n4\nfive\n6\n7\n8\n",
+			want: "--- old\n+++ new\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "new file",
+			old:  "",
+			new:  "x\ny\n",
+			want: "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+x\n+y\n",
+		},
+		{
+			name: "distant changes get separate hunks",
+			old:  "a\n1\n2\n3\n4\n5\n6\n7\nb\n",
+			new:  "A\n1\n2\n3\n4\n5\n6\n7\nB\n",
+			want: "--- old\n+++ new\n@@ -1,4 +1,4 @@\n-a\n+A\n 1\n 2\n 3\n@@ -6,4 +6,4 @@\n 5\n 6\n 7\n-b\n+B\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unifiedDiff("old", "new", tt.old, tt.new)
+			if got != tt.want {
+				t.Errorf("unifiedDiff() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanDryRunHyprlangTouchesNothing(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "hyprland.conf")
+	original := "$mod = SUPER\nmonitor=DP-1,1920x1080@60,0x0,1\n\nbind = $mod, Q, exec, kitty\n"
+	if err := os.WriteFile(configPath, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HYPRLAND_CONFIG", configPath)
+
+	monitors := []Monitor{
+		{Name: "eDP-1", Active: false},
+		{Name: "DP-1", PxW: 2560, PxH: 1440, Hz: 60, Scale: 1, Active: true},
+	}
+	plan, err := planDryRun(monitors, nil, true, true)
+	if err != nil {
+		t.Fatalf("planDryRun() error = %v", err)
+	}
+
+	wantCommands := []string{
+		"keyword monitor DP-1,2560x1440@60.00,0x0,1.00",
+		"keyword monitor eDP-1,disable",
+		"reload",
+	}
+	if strings.Join(plan.Commands, "\n") != strings.Join(wantCommands, "\n") {
+		t.Errorf("Commands =\n%s\nwant\n%s", strings.Join(plan.Commands, "\n"), strings.Join(wantCommands, "\n"))
+	}
+
+	out := plan.String()
+	for _, want := range []string{
+		"--- " + configPath,
+		"-monitor=DP-1,1920x1080@60,0x0,1",
+		"+monitor=DP-1,2560x1440@60.00,0x0,1.00",
+		"+monitor=eDP-1,disable",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("plan output missing %q:\n%s", want, out)
+		}
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != original {
+		t.Error("dry run modified the config file")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("dry run created files: %v", entries)
+	}
+}
+
+func TestPlanDryRunLuaShowsNewSidecar(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "hyprland.lua")
+	t.Setenv("HYPRLAND_CONFIG", configPath)
+
+	plan, err := planDryRun([]Monitor{{Name: "DP-1", PxW: 1920, PxH: 1080, Hz: 60, Scale: 1, Active: true}}, nil, true, false)
+	if err != nil {
+		t.Fatalf("planDryRun() error = %v", err)
+	}
+	if len(plan.Commands) != 1 || !strings.HasPrefix(plan.Commands[0], `eval hl.monitor({ output = "DP-1"`) {
+		t.Errorf("Commands = %q, want a single hl.monitor eval", plan.Commands)
+	}
+	if len(plan.Changes) != 0 {
+		t.Errorf("apply-only plan should not include config changes")
+	}
+
+	plan, err = planDryRun([]Monitor{{Name: "DP-1", PxW: 1920, PxH: 1080, Hz: 60, Scale: 1, Active: true}}, nil, false, true)
+	if err != nil {
+		t.Fatalf("planDryRun() error = %v", err)
+	}
+	out := plan.String()
+	if !strings.Contains(out, "--- /dev/null\n+++ "+filepath.Join(dir, "hyprmon.lua")) {
+		t.Errorf("plan should show hyprmon.lua as a new file:\n%s", out)
+	}
+	if !strings.Contains(out, "+"+hyprmonLuaRequireLine) {
+		t.Errorf("plan should show the require being added:\n%s", out)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("dry run created files: %v", entries)
+	}
+}
+
+func TestDryRunApplyKeyQuitsWithPlan(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "hyprland.conf")
+	if err := os.WriteFile(configPath, []byte("monitor=DP-1,preferred,auto,1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HYPRLAND_CONFIG", configPath)
+
+	m := model{
+		DryRun:   true,
+		Monitors: []Monitor{{Name: "DP-1", PxW: 1920, PxH: 1080, Hz: 60, Scale: 1, Active: true}},
+	}
+	updated, cmd := m.handleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}})
+	if cmd == nil {
+		t.Fatal("dry-run apply should quit the TUI")
+	}
+	got := updated.(model)
+	if !strings.Contains(got.DryRunOutput, "keyword monitor DP-1,1920x1080@60.00,0x0,1.00") {
+		t.Errorf("DryRunOutput = %q", got.DryRunOutput)
+	}
+}
+
+func TestPlanRevertAndAutoApplyNothing(t *testing.T) {
+	fake := startFakeHyprland(t, func(request string) string {
+		if request == "j/monitors all" {
+			return `[{"name":"DP-1","make":"Dell Inc.","model":"U2720Q","serial":"ABC","width":2560,"height":1440,"refreshRate":60,"scale":1,"x":0,"y":0}]`
+		}
+		return "ok"
+	})
+	useTempConfigDir(t)
+	origPrevious := previousMonitors
+	t.Cleanup(func() { previousMonitors = origPrevious })
+	previousMonitors = nil
+	configPath := filepath.Join(t.TempDir(), "hyprland.conf")
+	writeTestFile(t, configPath, "monitor=DP-1,2560x1440@60,0x0,1\n")
+	t.Setenv("HYPRLAND_CONFIG", configPath)
+
+	if _, err := planRevert(); err == nil || !strings.Contains(err.Error(), "no previous state") {
+		t.Errorf("planRevert() without state error = %v", err)
+	}
+
+	previous := []Monitor{{Name: "DP-1", HardwareID: "Dell Inc./U2720Q/ABC", PxW: 2560, PxH: 1440, Hz: 60, Scale: 1.5, Active: true}}
+	if err := saveRollbackState(previous); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveProfile("desk", previous); err != nil {
+		t.Fatal(err)
+	}
+	for name, plan := range map[string]func() (dryRunPlan, error){"revert": planRevert, "auto": planAutoProfile} {
+		p, err := plan()
+		if err != nil {
+			t.Fatalf("plan %s: %v", name, err)
+		}
+		if !strings.Contains(p.String(), "keyword monitor DP-1,2560x1440@60.00,0x0,1.50") {
+			t.Errorf("plan %s =\n%s", name, p)
+		}
+	}
+
+	for _, request := range fake.Requests() {
+		if !strings.HasPrefix(request, "j/") {
+			t.Errorf("dry run sent %q", request)
+		}
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "monitor=DP-1,2560x1440@60,0x0,1\n" {
+		t.Errorf("dry run changed the config:\n%s", data)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"reflect"
+)
+
+// maxHistorySteps bounds the undo stack; the oldest edits are dropped first.
+const maxHistorySteps = 100
+
+// layoutSnapshot is the monitor layout before an edit, plus a description of
+// that edit for the status line ("moved DP-1").
+type layoutSnapshot struct {
+	label    string
+	monitors []Monitor
+}
+
+// layoutHistory holds undo/redo snapshots for the layout editor. pending is
+// an edit that has started but not finished yet, such as a drag or an open
+// settings dialog, so that it ends up as a single step.
+type layoutHistory struct {
+	undo    []layoutSnapshot
+	redo    []layoutSnapshot
+	pending *layoutSnapshot
+}
+
+// cloneMonitors deep-copies monitors for a snapshot. Transient drag state
+// is cleared so restoring a snapshot never leaves a monitor mid-drag.
+func cloneMonitors(monitors []Monitor) []Monitor {
+	if monitors == nil {
+		return nil
+	}
+	clone := make([]Monitor, len(monitors))
+	for i, mon := range monitors {
+		mon.Modes = append([]Mode(nil), mon.Modes...)
+		mon.MirrorTargets = append([]string(nil), mon.MirrorTargets...)
+		mon.Dragging = false
+		mon.DragOffX = 0
+		mon.DragOffY = 0
+		clone[i] = mon
+	}
+	return clone
+}
+
+// selectedName returns the selected monitor's name for edit labels.
+func (m *model) selectedName() string {
+	if m.Selected < 0 || m.Selected >= len(m.Monitors) {
+		return ""
+	}
+	return m.Monitors[m.Selected].Name
+}
+
+// beginEdit snapshots the layout before a change described by label. The
+// change is recorded by commitEdit, and only if it actually changed
+// something.
+func (m *model) beginEdit(label string) {
+	if m.History.pending != nil {
+		m.commitEdit()
+	}
+	m.History.pending = &layoutSnapshot{
+		label:    label,
+		monitors: cloneMonitors(m.Monitors),
+	}
+}
+
+// commitEdit finishes the edit started by beginEdit and pushes it onto the
+// undo stack. A new edit invalidates everything that could be redone.
+func (m *model) commitEdit() {
+	pending := m.History.pending
+	if pending == nil {
+		return
+	}
+	m.History.pending = nil
+
+	if reflect.DeepEqual(pending.monitors, cloneMonitors(m.Monitors)) {
+		return
+	}
+
+	m.History.undo = append(m.History.undo, *pending)
+	if len(m.History.undo) > maxHistorySteps {
+		m.History.undo = m.History.undo[len(m.History.undo)-maxHistorySteps:]
+	}
+	m.History.redo = nil
+}
+
+// undo restores the layout from before the most recent edit.
+func (m *model) undo() {
+	m.commitEdit()
+	if len(m.History.undo) == 0 {
+		m.Status = "Nothing to undo"
+		return
+	}
+
+	last := m.History.undo[len(m.History.undo)-1]
+	m.History.undo = m.History.undo[:len(m.History.undo)-1]
+	m.History.redo = append(m.History.redo, layoutSnapshot{
+		label:    last.label,
+		monitors: cloneMonitors(m.Monitors),
+	})
+	m.restoreSnapshot(last)
+	m.Status = "undo: " + last.label
+}
+
+// redo re-applies the most recently undone edit.
+func (m *model) redo() {
+	m.commitEdit()
+	if len(m.History.redo) == 0 {
+		m.Status = "Nothing to redo"
+		return
+	}
+
+	next := m.History.redo[len(m.History.redo)-1]
+	m.History.redo = m.History.redo[:len(m.History.redo)-1]
+	m.History.undo = append(m.History.undo, layoutSnapshot{
+		label:    next.label,
+		monitors: cloneMonitors(m.Monitors),
+	})
+	m.restoreSnapshot(next)
+	m.Status = "redo: " + next.label
+}
+
+func (m *model) restoreSnapshot(s layoutSnapshot) {
+	m.Monitors = cloneMonitors(s.monitors)
+	if m.Selected >= len(m.Monitors) {
+		m.Selected = len(m.Monitors) - 1
+	}
+	m.Guides = nil
+	m.updateWorld()
+}
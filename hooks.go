@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// exitHookFailed is the exit code of `--profile` and `--auto` when a
+// pre_apply or post_apply hook fails or times out.
+const exitHookFailed = 4
+
+// defaultHookTimeout is how long each hook command may run unless
+// hook_timeout in settings says otherwise.
+const defaultHookTimeout = 10 * time.Second
+
+// Hooks run around a layout change
+const (
+	hookPreApply  = "pre_apply"
+	hookPostApply = "post_apply"
+)
+
+// Hooks are shell commands run before and after hyprmon changes the
+// layout, such as restarting a bar that doesn't follow monitor changes.
+type Hooks struct {
+	PreApply  []string `json:"pre_apply,omitempty"`
+	PostApply []string `json:"post_apply,omitempty"`
+}
+
+// commands returns the commands of one hook.
+func (h Hooks) commands(hook string) []string {
+	if hook == hookPreApply {
+		return h.PreApply
+	}
+	return h.PostApply
+}
+
+// hookError is a hook command that failed or timed out.
+type hookError struct {
+	Hook    string // hookPreApply or hookPostApply
+	Command string
+	Output  string
+	Err     error
+}
+
+func (e *hookError) Error() string {
+	msg := fmt.Sprintf("%s hook %q failed: %v", e.Hook, e.Command, e.Err)
+	if line := lastOutputLine(e.Output); line != "" {
+		msg += ": " + line
+	}
+	return msg
+}
+
+func (e *hookError) Unwrap() error {
+	return e.Err
+}
+
+// lastOutputLine returns the last non-empty line of hook output, which is
+// what fits in the status line.
+func lastOutputLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// getHookTimeout returns how long each hook command may run.
+func getHookTimeout(s *Settings) time.Duration {
+	if s != nil && s.HookTimeout > 0 {
+		return time.Duration(s.HookTimeout) * time.Second
+	}
+	return defaultHookTimeout
+}
+
+// hookEnv returns the variables that describe a change to the hooks, and
+// writes the layout to layoutPath for them.
+func hookEnv(hook, profile string, monitors []Monitor, s *Settings, layoutPath string) ([]string, error) {
+	var enabled []string
+	for _, m := range monitors {
+		if m.Active {
+			enabled = append(enabled, m.Name)
+		}
+	}
+	primary, _ := primaryMonitor(monitors, s)
+
+	data, err := json.MarshalIndent(monitors, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal layout: %w", err)
+	}
+	if err := os.WriteFile(layoutPath, data, profileFileMode); err != nil {
+		return nil, fmt.Errorf("failed to write layout for hooks: %w", err)
+	}
+
+	return []string{
+		"HYPRMON_HOOK=" + hook,
+		"HYPRMON_PROFILE=" + profile,
+		"HYPRMON_MONITORS=" + strings.Join(enabled, ","),
+		"HYPRMON_PRIMARY=" + primary.Name,
+		"HYPRMON_LAYOUT=" + layoutPath,
+	}, nil
+}
+
+// runHooks runs the commands of hook from settings and then from the
+// profile (nil outside of profiles) with sh -c, one at a time, each with
+// the hook timeout. monitors is the layout being applied. The combined
+// output of the commands is written to out; the first command to fail
+// stops the rest and is returned as a *hookError.
+func runHooks(hook string, profile *Profile, monitors []Monitor, out io.Writer) error {
+	s, err := loadSettings()
+	if err != nil {
+		return err
+	}
+	commands := s.Hooks.commands(hook)
+	name := ""
+	if profile != nil {
+		commands = append(append([]string(nil), commands...), profile.Hooks.commands(hook)...)
+		name = profile.Name
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+
+	layout, err := os.CreateTemp("", "hyprmon-layout-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create layout file for hooks: %w", err)
+	}
+	_ = layout.Close()
+	defer func() { _ = os.Remove(layout.Name()) }()
+
+	env, err := hookEnv(hook, name, monitors, s, layout.Name())
+	if err != nil {
+		return err
+	}
+
+	timeout := getHookTimeout(s)
+	for _, command := range commands {
+		var output bytes.Buffer
+		err := runHookCommand(command, env, timeout, &output)
+		_, _ = out.Write(output.Bytes())
+		if err != nil {
+			return &hookError{Hook: hook, Command: command, Output: output.String(), Err: err}
+		}
+	}
+	return nil
+}
+
+// runHookCommand runs one hook command, killing it after timeout.
+func runHookCommand(command string, env []string, timeout time.Duration, output *bytes.Buffer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	// Don't wait for children that keep the output open (such as a bar
+	// started with &) once the command itself has exited
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	if errors.Is(err, exec.ErrWaitDelay) {
+		return nil
+	}
+	return err
+}
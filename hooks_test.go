@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestRunHooks(t *testing.T) {
+	useTempConfigDir(t)
+
+	if err := saveSettings(&Settings{
+		Hooks:          Hooks{PostApply: []string{"echo global $HYPRMON_HOOK"}},
+		PrimaryMonitor: "Dell Inc./U2720Q/ABC",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	profile := &Profile{Name: "docked", Hooks: Hooks{PostApply: []string{
+		`echo "$HYPRMON_PROFILE|$HYPRMON_MONITORS|$HYPRMON_PRIMARY"`,
+		`grep -q '"hardware_id": "Dell Inc./U2720Q/ABC"' "$HYPRMON_LAYOUT" && echo layout ok`,
+	}}}
+	monitors := []Monitor{
+		{Name: "eDP-1", Active: true},
+		{Name: "DP-1", HardwareID: "Dell Inc./U2720Q/ABC", X: 1920, Active: true},
+		{Name: "HDMI-A-1"},
+	}
+
+	var output bytes.Buffer
+	if err := runHooks(hookPostApply, profile, monitors, &output); err != nil {
+		t.Fatalf("runHooks() error = %v", err)
+	}
+	want := "global post_apply\ndocked|eDP-1,DP-1|DP-1\nlayout ok\n"
+	if output.String() != want {
+		t.Errorf("output = %q, want %q", output.String(), want)
+	}
+
+	// No pre_apply hooks anywhere
+	output.Reset()
+	if err := runHooks(hookPreApply, profile, monitors, &output); err != nil || output.Len() != 0 {
+		t.Errorf("runHooks(pre_apply) = %v with output %q, want nothing run", err, output.String())
+	}
+}
+
+func TestRunHooksStopsAtFailure(t *testing.T) {
+	useTempConfigDir(t)
+
+	profile := &Profile{Name: "docked", Hooks: Hooks{PreApply: []string{"echo stopping bar; exit 3", "echo not reached"}}}
+	var output bytes.Buffer
+	err := runHooks(hookPreApply, profile, nil, &output)
+
+	var hookErr *hookError
+	if !errors.As(err, &hookErr) || hookErr.Hook != hookPreApply {
+		t.Fatalf("runHooks() error = %v, want a pre_apply *hookError", err)
+	}
+	if !strings.Contains(err.Error(), "exit status 3") || !strings.HasSuffix(err.Error(), ": stopping bar") {
+		t.Errorf("error = %q, want the exit status and the last output line", err)
+	}
+	if output.String() != "stopping bar\n" {
+		t.Errorf("output = %q, want only the failed command's", output.String())
+	}
+}
+
+func TestRunHookCommandTimeout(t *testing.T) {
+	start := time.Now()
+	err := runHookCommand("sleep 10", nil, 50*time.Millisecond, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("runHookCommand() error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("runHookCommand() took %s", elapsed)
+	}
+}
+
+func TestHookStatus(t *testing.T) {
+	if got := hookStatus("Configuration saved", "restarting waybar\nwaybar started\n", nil); got != "Configuration saved (hook: waybar started)" {
+		t.Errorf("hookStatus() = %q", got)
+	}
+	err := &hookError{Hook: hookPostApply, Command: "pkill waybar", Err: errors.New("exit status 1")}
+	if got := hookStatus("Configuration saved", "", err); got != `Configuration saved, but post_apply hook "pkill waybar" failed: exit status 1` {
+		t.Errorf("hookStatus() = %q", got)
+	}
+	if got := hookStatus("Configuration saved", "", nil); got != "Configuration saved" {
+		t.Errorf("hookStatus() = %q", got)
+	}
+}
+
+func TestProfileMenuReportsPostApplyFailure(t *testing.T) {
+	shortVerifySettle(t)
+	startFakeHyprland(t, func(request string) string {
+		switch request {
+		case "j/monitors all", "j/monitors":
+			return `[{"name":"eDP-1","width":1920,"height":1080,"refreshRate":60,"scale":1,"x":0,"y":0}]`
+		case "j/workspaces":
+			return `[]`
+		}
+		return "ok"
+	})
+	useTempConfigDir(t)
+	configPath := filepath.Join(t.TempDir(), "hyprland.conf")
+	writeTestFile(t, configPath, "$mod = SUPER\n")
+	t.Setenv("HYPRLAND_CONFIG", configPath)
+
+	if err := saveSettings(&Settings{Hooks: Hooks{PostApply: []string{"echo bar gone; exit 1"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveProfile("laptop", []Monitor{{Name: "eDP-1", PxW: 1920, PxH: 1080, Hz: 60, Scale: 1, Active: true}}); err != nil {
+		t.Fatal(err)
+	}
+
+	m := profileMenuModel{profiles: []string{"laptop"}}
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(profileMenuModel)
+	if m.err != nil {
+		t.Fatalf("err = %v, want the apply to count as done", m.err)
+	}
+	if cmd == nil {
+		t.Error("menu should close after applying")
+	}
+	want := `Applied profile: laptop, but post_apply hook "echo bar gone; exit 1" failed: exit status 1: bar gone`
+	if m.applied != want {
+		t.Errorf("applied = %q, want %q", m.applied, want)
+	}
+}
+
+func TestProfileMenuAsksAboutHandEdits(t *testing.T) {
+	shortVerifySettle(t)
+	startFakeHyprland(t, func(request string) string {
+		switch request {
+		case "j/monitors all", "j/monitors":
+			return `[{"name":"eDP-1","width":1920,"height":1080,"refreshRate":60,"scale":1,"x":0,"y":0}]`
+		case "j/workspaces":
+			return `[]`
+		}
+		return "ok"
+	})
+	dir := useTempConfigDir(t)
+	configPath := filepath.Join(t.TempDir(), "hyprland.conf")
+	writeTestFile(t, configPath, "$mod = SUPER\n")
+	t.Setenv("HYPRLAND_CONFIG", configPath)
+	countPath := filepath.Join(dir, "pre_apply_runs")
+	if err := saveSettings(&Settings{Hooks: Hooks{PreApply: []string{"echo run >> " + countPath}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveProfile("laptop", []Monitor{{Name: "eDP-1", PxW: 1920, PxH: 1080, Hz: 60, Scale: 1, Active: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyProfile("laptop"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(configPath)
+	edited := strings.Replace(string(data), ",1.00", ",2.00", 1)
+	writeTestFile(t, configPath, edited)
+	if err := os.Remove(countPath); err != nil {
+		t.Fatal(err)
+	}
+
+	// The menu stays open and asks; any other key aborts without changes
+	m := profileMenuModel{profiles: []string{"laptop"}}
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(profileMenuModel)
+	if cmd != nil || m.conflictProfile != "laptop" || m.conflictPath != configPath {
+		t.Fatalf("after Enter: conflict = %q %q, cmd = %v, err = %v", m.conflictProfile, m.conflictPath, cmd, m.err)
+	}
+	if !strings.Contains(m.View(), "O: overwrite") {
+		t.Errorf("View() doesn't ask about the hand edits:\n%s", m.View())
+	}
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
+	m = next.(profileMenuModel)
+	if m.conflictProfile != "" || m.err == nil || !strings.Contains(m.err.Error(), "aborted") {
+		t.Fatalf("after abort: conflict = %q, err = %v", m.conflictProfile, m.err)
+	}
+	if data, _ := os.ReadFile(configPath); string(data) != edited {
+		t.Errorf("aborted apply changed the config:\n%s", data)
+	}
+
+	// Overwriting applies the profile and closes the menu
+	next, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(profileMenuModel)
+	next, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'o'}})
+	m = next.(profileMenuModel)
+	if m.err != nil || cmd == nil || m.applied != "Applied profile: laptop" {
+		t.Fatalf("after overwrite: applied = %q, err = %v", m.applied, m.err)
+	}
+	if data, _ := os.ReadFile(configPath); strings.Contains(string(data), ",2.00") {
+		t.Errorf("overwrite kept the hand edit:\n%s", data)
+	}
+	if data, _ := os.ReadFile(countPath); string(data) != "run\n" {
+		t.Errorf("pre_apply ran %d times, want once", strings.Count(string(data), "run"))
+	}
+}
+
+func TestSaveRunsPreApplyOnceAcrossConflict(t *testing.T) {
+	startFakeHyprland(t, func(string) string { return "ok" })
+	dir := useTempConfigDir(t)
+	configPath := filepath.Join(t.TempDir(), "hyprland.conf")
+	writeTestFile(t, configPath, "$mod = SUPER\n")
+	t.Setenv("HYPRLAND_CONFIG", configPath)
+	countPath := filepath.Join(dir, "pre_apply_runs")
+	if err := saveSettings(&Settings{Hooks: Hooks{PreApply: []string{"echo run >> " + countPath}}}); err != nil {
+		t.Fatal(err)
+	}
+	monitors := []Monitor{{Name: "DP-1", PxW: 1920, PxH: 1080, Hz: 60, Scale: 1, Active: true}}
+
+	if msg := saveCmd(monitors, nil, conflictAsk)().(saveMsg); !msg.success {
+		t.Fatalf("first save error = %v", msg.err)
+	}
+	data, _ := os.ReadFile(configPath)
+	writeTestFile(t, configPath, strings.Replace(string(data), ",1.00", ",2.00", 1))
+	if err := os.Remove(countPath); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := saveCmd(monitors, nil, conflictAsk)().(saveMsg)
+	var edited *configEditedError
+	if !errors.As(msg.err, &edited) {
+		t.Fatalf("save after editing the block error = %v, want configEditedError", msg.err)
+	}
+	if msg := saveCmd(monitors, nil, conflictOverwrite)().(saveMsg); !msg.success {
+		t.Fatalf("overwrite error = %v", msg.err)
+	}
+	if data, _ := os.ReadFile(countPath); string(data) != "run\n" {
+		t.Errorf("pre_apply ran %d times, want once", strings.Count(string(data), "run"))
+	}
+}
@@ -0,0 +1,765 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// configMonitorRule is a monitor= rule read from a hyprlang config.
+type configMonitorRule struct {
+	// Target is the connector name, a "desc:..." identifier, or "" for the
+	// catch-all rule.
+	Target  string
+	Monitor Monitor
+
+	// Tokens Hyprland resolves at runtime, kept when the rule doesn't give
+	// an explicit value: Mode is "preferred", "highres", "highrr" or
+	// "maxwidth", Position is "auto" or one of its "auto-..." variants.
+	Mode      string
+	Position  string
+	AutoScale bool
+
+	// Where the rule lives and how it was written
+	File string
+	Line int
+	Text string
+
+	// Dropped lists the "key = value" settings whose values hyprmon
+	// couldn't read. They are left at their defaults; the rest of the rule
+	// is kept.
+	Dropped []string
+
+	// Err is set for a rule hyprmon couldn't read. Only Target, when the
+	// rule names one, and the location are filled in; the rest is left
+	// out of the layout.
+	Err error
+}
+
+// hyprlangParser reads a hyprlang config and the files it sources.
+type hyprlangParser struct {
+	vars     map[string]string
+	varNames []string // longest first, so $mod2 wins over $mod
+	visiting map[string]bool
+	rules    []configMonitorRule
+}
+
+// parseHyprlangConfig returns the monitor rules in a hyprlang config, in
+// the order Hyprland reads them. source= includes are followed (relative
+// paths, ~ and globs are resolved like Hyprland does) and $variables are
+// expanded. Rules that can't be read are returned with Err set, so one bad
+// line doesn't hide the rest of the config; an include that can't be read
+// is an error.
+func parseHyprlangConfig(path string) ([]configMonitorRule, error) {
+	p := &hyprlangParser{
+		vars:     make(map[string]string),
+		visiting: make(map[string]bool),
+	}
+	if err := p.parseFile(path); err != nil {
+		return nil, err
+	}
+	return p.rules, nil
+}
+
+func (p *hyprlangParser) parseFile(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	if p.visiting[abs] {
+		return fmt.Errorf("%s sources itself", abs)
+	}
+	p.visiting[abs] = true
+	defer delete(p.visiting, abs)
+
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	// Monitor rules and includes only count outside category blocks,
+	// except for monitorv2 blocks, which are rules themselves
+	depth := 0
+	var block *monitorV2Block
+	for i, raw := range strings.Split(string(data), "\n") {
+		line := strings.TrimSpace(stripHyprlangComment(raw))
+		if line == "" {
+			continue
+		}
+		if line == "}" {
+			if block != nil {
+				rule := block.rule()
+				rule.File = abs
+				p.rules = append(p.rules, rule)
+				block = nil
+			}
+			if depth > 0 {
+				depth--
+			}
+			continue
+		}
+		if strings.HasSuffix(line, "{") {
+			if depth == 0 && isMonitorV2Start(line) {
+				block = &monitorV2Block{line: i + 1}
+			}
+			depth++
+			continue
+		}
+		if block != nil && depth == 1 {
+			if key, value, ok := strings.Cut(line, "="); ok {
+				block.fields = append(block.fields, [2]string{strings.TrimSpace(key), p.expand(strings.TrimSpace(value))})
+			}
+			continue
+		}
+		if depth > 0 {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		switch {
+		case strings.HasPrefix(key, "$"):
+			p.setVar(strings.TrimPrefix(key, "$"), p.expand(value))
+
+		case key == "source":
+			if err := p.parseSource(abs, p.expand(value)); err != nil {
+				return fmt.Errorf("%s:%d: %w", abs, i+1, err)
+			}
+
+		case key == "monitor":
+			rule, ok, err := parseMonitorRule(p.expand(value))
+			if err != nil {
+				target, _, _ := strings.Cut(p.expand(value), ",")
+				rule = configMonitorRule{Target: strings.TrimSpace(target), Err: err}
+			} else if !ok {
+				continue
+			}
+			rule.File = abs
+			rule.Line = i + 1
+			rule.Text = strings.TrimSpace(raw)
+			p.rules = append(p.rules, rule)
+		}
+	}
+	return nil
+}
+
+// parseSource follows a source= include. Paths are relative to the file
+// that includes them.
+func (p *hyprlangParser) parseSource(from, path string) error {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("failed to get home directory: %w", err)
+		}
+		path = filepath.Join(home, rest)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(from), path)
+	}
+
+	matches, err := filepath.Glob(path)
+	if err != nil {
+		return fmt.Errorf("invalid source path %q: %w", path, err)
+	}
+	if len(matches) == 0 {
+		// Not a glob, or a glob without matches; report the missing file
+		matches = []string{path}
+	}
+	for _, match := range matches {
+		if err := p.parseFile(match); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *hyprlangParser) setVar(name, value string) {
+	if _, ok := p.vars[name]; !ok {
+		p.varNames = append(p.varNames, name)
+		sort.SliceStable(p.varNames, func(i, j int) bool {
+			return len(p.varNames[i]) > len(p.varNames[j])
+		})
+	}
+	p.vars[name] = value
+}
+
+// expand replaces the $variables defined so far. Unknown ones are kept.
+func (p *hyprlangParser) expand(s string) string {
+	if !strings.Contains(s, "$") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' {
+			b.WriteByte(s[i])
+			continue
+		}
+		matched := false
+		for _, name := range p.varNames {
+			if strings.HasPrefix(s[i+1:], name) {
+				b.WriteString(p.vars[name])
+				i += len(name)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			b.WriteByte('$')
+		}
+	}
+	return b.String()
+}
+
+// stripHyprlangComment removes a trailing # comment. A doubled ## is an
+// escaped literal #.
+func stripHyprlangComment(line string) string {
+	if !strings.Contains(line, "#") {
+		return line
+	}
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] != '#' {
+			b.WriteByte(line[i])
+			continue
+		}
+		if i+1 < len(line) && line[i+1] == '#' {
+			b.WriteByte('#')
+			i++
+			continue
+		}
+		break
+	}
+	return b.String()
+}
+
+// parseMonitorRule parses the value of a monitor= line. ok is false for
+// rules that don't describe a layout, such as addreserved.
+func parseMonitorRule(value string) (rule configMonitorRule, ok bool, err error) {
+	fields := strings.Split(value, ",")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	if len(fields) < 2 {
+		return rule, false, fmt.Errorf("monitor rule %q has no mode", value)
+	}
+
+	rule.Target = fields[0]
+	if desc, isDesc := strings.CutPrefix(rule.Target, "desc:"); isDesc {
+		rule.Monitor.EDIDName = desc
+		rule.Monitor.UseDescFormat = true
+	} else {
+		rule.Monitor.Name = rule.Target
+	}
+
+	switch fields[1] {
+	case "disable", "disabled":
+		return rule, true, nil
+	case "addreserved":
+		return rule, false, nil
+	}
+	rule.Monitor.Active = true
+
+	switch fields[1] {
+	case "preferred", "highres", "highrr", "maxwidth":
+		rule.Mode = fields[1]
+	default:
+		if modeline, isModeline := strings.CutPrefix(fields[1], "modeline"); isModeline {
+			mode := parseModeline(modeline)
+			if mode == nil {
+				return rule, false, fmt.Errorf("invalid modeline %q", fields[1])
+			}
+			rule.Monitor.PxW, rule.Monitor.PxH, rule.Monitor.Hz = mode.W, mode.H, mode.Hz
+			break
+		}
+		modeStr := fields[1]
+		if !strings.Contains(modeStr, "@") {
+			// Hyprland defaults to 60Hz when no refresh rate is given
+			modeStr += "@60"
+		}
+		mode := parseMode(modeStr)
+		if mode == nil {
+			return rule, false, fmt.Errorf("invalid mode %q", fields[1])
+		}
+		rule.Monitor.PxW, rule.Monitor.PxH, rule.Monitor.Hz = mode.W, mode.H, mode.Hz
+	}
+
+	rule.Position = "auto"
+	if len(fields) > 2 && fields[2] != "auto" {
+		if strings.HasPrefix(fields[2], "auto-") {
+			rule.Position = fields[2]
+		} else {
+			x, y, found := strings.Cut(fields[2], "x")
+			px, errX := strconv.ParseInt(x, 10, 32)
+			py, errY := strconv.ParseInt(y, 10, 32)
+			if !found || errX != nil || errY != nil {
+				return rule, false, fmt.Errorf("invalid position %q", fields[2])
+			}
+			rule.Monitor.X, rule.Monitor.Y = int32(px), int32(py)
+			rule.Position = ""
+		}
+	}
+
+	rule.Monitor.Scale = 1
+	if len(fields) > 3 {
+		if fields[3] == "auto" {
+			rule.AutoScale = true
+		} else {
+			scale, err := strconv.ParseFloat(fields[3], 32)
+			if err != nil || scale <= 0 {
+				return rule, false, fmt.Errorf("invalid scale %q", fields[3])
+			}
+			rule.Monitor.Scale = float32(scale)
+		}
+	}
+
+	if len(fields) > 4 {
+		extra := fields[4:]
+		if len(extra)%2 != 0 {
+			return rule, false, fmt.Errorf("option %q has no value", extra[len(extra)-1])
+		}
+		for i := 0; i < len(extra); i += 2 {
+			if !applyMonitorRuleOption(&rule.Monitor, extra[i], extra[i+1]) {
+				rule.Dropped = append(rule.Dropped, extra[i]+" = "+extra[i+1])
+			}
+		}
+	}
+	return rule, true, nil
+}
+
+// parseModeline reads the resolution and refresh rate from the fields of
+// a custom modeline: the pixel clock in MHz, then the horizontal and
+// vertical timings (display, sync start, sync end, total), then flags.
+func parseModeline(s string) *Mode {
+	fields := strings.Fields(s)
+	if len(fields) < 9 {
+		return nil
+	}
+	var timings [9]float64
+	for i := range timings {
+		v, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil || v <= 0 {
+			return nil
+		}
+		timings[i] = v
+	}
+	clock, width, htotal, height, vtotal := timings[0], timings[1], timings[4], timings[5], timings[8]
+	hz := clock * 1e6 / (htotal * vtotal)
+	return &Mode{W: uint32(width), H: uint32(height), Hz: float32(math.Round(hz*100) / 100)}
+}
+
+// monitorV2Block collects the fields of a monitorv2 { ... } block.
+type monitorV2Block struct {
+	line   int
+	fields [][2]string
+}
+
+// rule converts the block into the rule the equivalent monitor= line
+// would give, with Err set when the block can't be read.
+func (b *monitorV2Block) rule() configMonitorRule {
+	var text []string
+	output := ""
+	for _, field := range b.fields {
+		text = append(text, field[0]+" = "+field[1])
+		if field[0] == "output" {
+			output = field[1]
+		}
+	}
+
+	rule, err := monitorRuleFromFields(b.fields)
+	if err != nil {
+		rule = configMonitorRule{Target: output, Err: fmt.Errorf("monitorv2 block %w", err)}
+	}
+	rule.Line = b.line
+	rule.Text = "monitorv2 { " + strings.Join(text, ", ") + " }"
+	return rule
+}
+
+// monitorRuleFromFields builds a rule from named fields, as used by
+// monitorv2 blocks and Lua hl.monitor tables. Missing fields get the
+// defaults of the positional form, and so do fields whose values can't be
+// read; those are listed in the rule's Dropped. Only a missing output is
+// an error.
+func monitorRuleFromFields(fields [][2]string) (configMonitorRule, error) {
+	defaults := map[string]string{"mode": "preferred", "position": "auto", "scale": "1"}
+	values := map[string]string{"mode": "preferred", "position": "auto", "scale": "1"}
+	var options []string
+	for _, field := range fields {
+		key, value := field[0], field[1]
+		switch key {
+		case "output", "mode", "position", "scale", "disabled":
+			values[key] = value
+		default:
+			options = append(options, key, value)
+		}
+	}
+
+	if values["output"] == "" {
+		return configMonitorRule{}, fmt.Errorf("has no output")
+	}
+
+	// Try mode, position and scale one at a time, so a value that can't be
+	// read only costs its own field
+	var dropped []string
+	for i, key := range []string{"mode", "position", "scale"} {
+		if values[key] == defaults[key] {
+			continue
+		}
+		probe := []string{values["output"], defaults["mode"], defaults["position"], defaults["scale"]}
+		probe[i+1] = values[key]
+		if _, _, err := parseMonitorRule(strings.Join(probe, ",")); err != nil {
+			dropped = append(dropped, key+" = "+values[key])
+			values[key] = defaults[key]
+		}
+	}
+
+	positional := []string{values["output"], values["mode"], values["position"], values["scale"]}
+	if value, set := values["disabled"]; set {
+		disabled, err := strconv.ParseBool(value)
+		if err != nil {
+			dropped = append(dropped, "disabled = "+value)
+		} else if disabled {
+			positional = []string{values["output"], "disable"}
+		}
+	}
+	rule, _, err := parseMonitorRule(strings.Join(positional, ","))
+	if err != nil {
+		return rule, err
+	}
+	if rule.Monitor.Active {
+		for i := 0; i < len(options); i += 2 {
+			if !applyMonitorRuleOption(&rule.Monitor, options[i], options[i+1]) {
+				dropped = append(dropped, options[i]+" = "+options[i+1])
+			}
+		}
+	}
+	rule.Dropped = dropped
+	return rule, nil
+}
+
+// applyMonitorRuleOption sets one key,value pair from the end of a monitor
+// rule. Options hyprmon doesn't manage are ignored. It returns false for a
+// value it doesn't recognise, which leaves the setting at its default.
+func applyMonitorRuleOption(m *Monitor, key, value string) bool {
+	switch key {
+	case "mirror":
+		if !isValidMonitorName(value) {
+			return false
+		}
+		m.IsMirrored = true
+		m.MirrorSource = value
+	case "bitdepth":
+		depth, err := strconv.Atoi(value)
+		if err != nil || (depth != 8 && depth != 10) {
+			return false
+		}
+		m.BitDepth = uint8(depth)
+	case "cm":
+		if !isValidColorMode(value) {
+			return false
+		}
+		m.ColorMode = value
+	case "sdrbrightness", "sdrsaturation":
+		f, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return false
+		}
+		if key == "sdrbrightness" {
+			m.SDRBrightness = float32(f)
+		} else {
+			m.SDRSaturation = float32(f)
+		}
+	case "vrr":
+		vrr, err := strconv.Atoi(value)
+		if err != nil || vrr < 0 || vrr > 3 {
+			return false
+		}
+		m.VRR = vrr
+	case "transform":
+		transform, err := strconv.Atoi(value)
+		if err != nil || transform < 0 || transform > 7 {
+			return false
+		}
+		m.Transform = transform
+	case "sdr_min_luminance":
+		f, err := strconv.ParseFloat(value, 32)
+		if err != nil || f < 0 {
+			return false
+		}
+		m.SDRMinLuminance = float32(f)
+	case "sdr_max_luminance":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return false
+		}
+		m.SDRMaxLuminance = n
+	}
+	return true
+}
+
+// matchesRuleTarget reports whether a rule target applies to a connected
+// monitor. Like Hyprland, desc: matches the start of the description.
+func matchesRuleTarget(target string, m Monitor) bool {
+	if desc, ok := strings.CutPrefix(target, "desc:"); ok {
+		return desc != "" && strings.HasPrefix(m.EDIDName, desc)
+	}
+	return target == m.Name
+}
+
+// monitorsFromRules turns parsed rules into the layout they describe. A
+// later rule for the same target replaces an earlier one, as in Hyprland.
+// Rules are matched against the connected monitors, when there are any,
+// to fill in hardware IDs and resolve preferred modes, auto scale and auto
+// positions. desc: rules that match no connected monitor are returned in
+// skipped, since nothing would identify them in a profile, and so are
+// rules that couldn't be read.
+func monitorsFromRules(rules []configMonitorRule, live []Monitor) (monitors []Monitor, skipped []configMonitorRule) {
+	var effective []configMonitorRule
+	index := make(map[string]int)
+	for _, rule := range rules {
+		if rule.Err != nil {
+			skipped = append(skipped, rule)
+			continue
+		}
+		if rule.Target == "" {
+			continue
+		}
+		if i, ok := index[rule.Target]; ok {
+			effective[i] = rule
+			continue
+		}
+		index[rule.Target] = len(effective)
+		effective = append(effective, rule)
+	}
+
+	var pending []configMonitorRule
+	for _, rule := range effective {
+		m := rule.Monitor
+		matched := false
+		for _, l := range live {
+			if !matchesRuleTarget(rule.Target, l) {
+				continue
+			}
+			m.Name = l.Name
+			m.HardwareID, m.Make, m.Model, m.Serial = l.HardwareID, l.Make, l.Model, l.Serial
+			m.EDIDName, m.Modes = l.EDIDName, l.Modes
+			if rule.Mode != "" {
+				m.PxW, m.PxH, m.Hz = l.PxW, l.PxH, l.Hz
+			}
+			if rule.AutoScale && l.Scale > 0 {
+				m.Scale = l.Scale
+			}
+			matched = true
+			break
+		}
+		if !matched && m.Name == "" {
+			skipped = append(skipped, rule)
+			continue
+		}
+		rule.Monitor = m
+		pending = append(pending, rule)
+	}
+
+	// Place auto-positioned monitors after the explicit ones, next to the
+	// layout so far
+	for _, rule := range pending {
+		if rule.Position == "" || !rule.Monitor.Active {
+			monitors = append(monitors, rule.Monitor)
+		}
+	}
+	for _, rule := range pending {
+		if rule.Position != "" && rule.Monitor.Active {
+			m := rule.Monitor
+			m.X, m.Y = autoPosition(rule.Position, m, monitors)
+			monitors = append(monitors, m)
+		}
+	}
+
+	for i := range monitors {
+		if monitors[i].IsMirrored {
+			for j := range monitors {
+				if monitors[j].Name == monitors[i].MirrorSource {
+					monitors[j].MirrorTargets = append(monitors[j].MirrorTargets, monitors[i].Name)
+				}
+			}
+		}
+	}
+	return monitors, skipped
+}
+
+// autoPosition places a monitor beside the active monitors placed so far:
+// to the right for "auto", or in the direction of an "auto-..." variant.
+func autoPosition(position string, m Monitor, placed []Monitor) (int32, int32) {
+	var minX, minY, maxX, maxY int32
+	first := true
+	for _, p := range placed {
+		if !p.Active || p.IsMirrored {
+			continue
+		}
+		w, h := logicalSize(p)
+		if first {
+			minX, minY, maxX, maxY = p.X, p.Y, p.X+w, p.Y+h
+			first = false
+			continue
+		}
+		if p.X < minX {
+			minX = p.X
+		}
+		if p.Y < minY {
+			minY = p.Y
+		}
+		if p.X+w > maxX {
+			maxX = p.X + w
+		}
+		if p.Y+h > maxY {
+			maxY = p.Y + h
+		}
+	}
+	if first {
+		return 0, 0
+	}
+
+	w, h := logicalSize(m)
+	direction := strings.TrimPrefix(strings.TrimPrefix(position, "auto-center-"), "auto-")
+	switch direction {
+	case "left", "l":
+		return minX - w, minY
+	case "up", "u":
+		return minX, minY - h
+	case "down", "d":
+		return minX, maxY
+	default:
+		return maxX, minY
+	}
+}
+
+// logicalSize is a monitor's size in layout coordinates: scaled, and
+// swapped for 90° and 270° transforms.
+func logicalSize(m Monitor) (int32, int32) {
+	scale := m.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	w := int32(float32(m.PxW) / scale)
+	h := int32(float32(m.PxH) / scale)
+	if m.Transform%2 == 1 {
+		return h, w
+	}
+	return w, h
+}
+
+// readConfigMonitorRules parses the monitor rules of the config hyprmon
+// writes to, hyprlang or Lua.
+func readConfigMonitorRules() ([]configMonitorRule, error) {
+	target, err := getConfigTarget()
+	if err != nil {
+		return nil, fmt.Errorf("could not determine config path: %w", err)
+	}
+	if target.Format == configFormatLua {
+		return parseLuaConfig(target.Path)
+	}
+	return parseHyprlangConfig(target.Path)
+}
+
+// importConfigProfile saves the layout described by the Hyprland config
+// as a profile. Connected monitors are used when Hyprland is reachable.
+func importConfigProfile(name string) ([]Monitor, []configMonitorRule, error) {
+	rules, err := readConfigMonitorRules()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, rule := range rules {
+		if len(rule.Dropped) > 0 {
+			fmt.Fprintf(os.Stderr, "warning: %s:%d: ignored %s\n", rule.File, rule.Line, strings.Join(rule.Dropped, ", "))
+		}
+	}
+
+	live, err := readMonitors()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: could not read connected monitors, importing rules as written: %v\n", err)
+	}
+
+	monitors, skipped := monitorsFromRules(rules, live)
+	if len(monitors) == 0 {
+		return nil, skipped, fmt.Errorf("config has no monitor rules to import")
+	}
+	if err := saveProfile(name, monitors); err != nil {
+		return nil, skipped, fmt.Errorf("failed to save profile %s: %w", name, err)
+	}
+	return monitors, skipped, nil
+}
+
+// hyprlangRulesFile picks the file inline mode writes the managed block
+// to, so rules end up where a dotfiles setup already keeps them (such as a
+// monitors.conf sourced from hyprland.conf) instead of being duplicated in
+// the main config. In order of preference: the file that already holds the
+// block, the file with the most rules for the monitors being written, the
+// file with the most monitor rules, and finally the main config. Ties go to
+// the file Hyprland reads first. hyprmon.conf from sidecar mode is never
+// picked. Rules hyprmon can't read still count towards where the rules
+// are kept. A config sourcing a file that can't be read is an error:
+// writing to the main file anyway could duplicate rules kept in the file
+// that couldn't be read.
+func hyprlangRulesFile(configPath string, monitors []Monitor) (string, error) {
+	rules, err := parseHyprlangConfig(configPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to find the file with monitor rules: %w", err)
+	}
+	mainPath, err := filepath.Abs(configPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", configPath, err)
+	}
+	sidecarPath := hyprlangSidecarPath(mainPath)
+
+	managed := managedMonitorTargets(monitors)
+	files := []string{mainPath}
+	managedRules := make(map[string]int)
+	allRules := make(map[string]int)
+	for _, rule := range rules {
+		if rule.File == sidecarPath {
+			continue
+		}
+		if _, seen := allRules[rule.File]; !seen && rule.File != mainPath {
+			files = append(files, rule.File)
+		}
+		allRules[rule.File]++
+		if managed[rule.Target] {
+			managedRules[rule.File]++
+		}
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		if begin, _, err := findHyprlangBlock(strings.Split(string(data), "\n")); err == nil && begin != -1 {
+			return file, nil
+		}
+	}
+
+	best := func(counts map[string]int) string {
+		choice, most := "", 0
+		for _, file := range files {
+			if counts[file] > most {
+				choice, most = file, counts[file]
+			}
+		}
+		return choice
+	}
+	if file := best(managedRules); file != "" {
+		return file, nil
+	}
+	if file := best(allRules); file != "" {
+		return file, nil
+	}
+	return configPath, nil
+}
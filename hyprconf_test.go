@@ -0,0 +1,439 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseMonitorRule(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  configMonitorRule
+		err   string
+	}{
+		{
+			name:  "Explicit mode, position and scale",
+			value: "DP-1, 2560x1440@143.91, -1280x0, 1.25",
+			want: configMonitorRule{Target: "DP-1", Monitor: Monitor{
+				Name: "DP-1", PxW: 2560, PxH: 1440, Hz: 143.91, X: -1280, Scale: 1.25, Active: true,
+			}},
+		},
+		{
+			name:  "Tokens and options",
+			value: "desc:Dell Inc. U2720Q,preferred,auto-left,auto,bitdepth,10,cm,hdr,vrr,1,transform,3",
+			want: configMonitorRule{
+				Target: "desc:Dell Inc. U2720Q",
+				Monitor: Monitor{
+					EDIDName: "Dell Inc. U2720Q", UseDescFormat: true, Scale: 1, Active: true,
+					BitDepth: 10, ColorMode: "hdr", VRR: 1, Transform: 3,
+				},
+				Mode: "preferred", Position: "auto-left", AutoScale: true,
+			},
+		},
+		{
+			name:  "Mirror without refresh rate",
+			value: "HDMI-A-1,1920x1080,auto,1,mirror,eDP-1",
+			want: configMonitorRule{
+				Target:   "HDMI-A-1",
+				Monitor:  Monitor{Name: "HDMI-A-1", PxW: 1920, PxH: 1080, Hz: 60, Scale: 1, Active: true, IsMirrored: true, MirrorSource: "eDP-1"},
+				Position: "auto",
+			},
+		},
+		{
+			name:  "Disabled",
+			value: "eDP-1,disable",
+			want:  configMonitorRule{Target: "eDP-1", Monitor: Monitor{Name: "eDP-1"}},
+		},
+		{
+			name:  "Values added in newer Hyprland releases",
+			value: "DP-2,3840x2160@120,0x0,1.5,cm,dcip3,vrr,3",
+			want: configMonitorRule{Target: "DP-2", Monitor: Monitor{
+				Name: "DP-2", PxW: 3840, PxH: 2160, Hz: 120, Scale: 1.5, Active: true, ColorMode: "dcip3", VRR: 3,
+			}},
+		},
+		{
+			name:  "Custom modeline",
+			value: "DP-3,modeline 1071.101 3840 3848 3880 3920 2160 2263 2271 2277 +hsync -vsync,0x0,1",
+			want: configMonitorRule{Target: "DP-3", Monitor: Monitor{
+				Name: "DP-3", PxW: 3840, PxH: 2160, Hz: 120, Scale: 1, Active: true,
+			}},
+		},
+		{
+			name:  "Unknown option values keep the defaults",
+			value: "DP-1,1920x1080@60,0x0,1,cm,rec2100,vrr,9,bitdepth,12,transform,3",
+			want: configMonitorRule{Target: "DP-1", Monitor: Monitor{
+				Name: "DP-1", PxW: 1920, PxH: 1080, Hz: 60, Scale: 1, Active: true, Transform: 3,
+			}},
+		},
+		{
+			name:  "Bad position",
+			value: "DP-1,1920x1080@60,left,1",
+			err:   `invalid position "left"`,
+		},
+		{
+			name:  "Option without value",
+			value: "DP-1,1920x1080@60,0x0,1,transform",
+			err:   `option "transform" has no value`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := parseMonitorRule(tt.value)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("parseMonitorRule() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseMonitorRule() error = %v", err)
+			}
+			if got.Target != tt.want.Target || got.Mode != tt.want.Mode || got.Position != tt.want.Position || got.AutoScale != tt.want.AutoScale {
+				t.Errorf("parseMonitorRule() = %+v, want %+v", got, tt.want)
+			}
+			if !monitorsEqualForTest(got.Monitor, tt.want.Monitor) {
+				t.Errorf("parseMonitorRule() monitor = %+v, want %+v", got.Monitor, tt.want.Monitor)
+			}
+		})
+	}
+}
+
+func monitorsEqualForTest(a, b Monitor) bool {
+	a.MirrorTargets, b.MirrorTargets = nil, nil
+	a.Modes, b.Modes = nil, nil
+	return a.Name == b.Name && a.EDIDName == b.EDIDName && a.UseDescFormat == b.UseDescFormat &&
+		a.PxW == b.PxW && a.PxH == b.PxH && a.Hz == b.Hz && a.X == b.X && a.Y == b.Y &&
+		a.Scale == b.Scale && a.Active == b.Active && a.BitDepth == b.BitDepth &&
+		a.ColorMode == b.ColorMode && a.VRR == b.VRR && a.Transform == b.Transform &&
+		a.IsMirrored == b.IsMirrored && a.MirrorSource == b.MirrorSource
+}
+
+func TestParseHyprlangConfigFollowsSourcesAndVariables(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "hyprland.conf")
+	writeTestFile(t, main, strings.Join([]string{
+		"$main = DP-1",
+		"$mainmode = 2560x1440@144 # the good one",
+		"source = ./conf.d/*.conf",
+		"monitor = $main, $mainmode, 0x0, 1",
+		"general {",
+		"    monitor = ignored, inside, a, block",
+		"}",
+	}, "\n"))
+	writeTestFile(t, filepath.Join(dir, "conf.d", "laptop.conf"), "monitor=eDP-1,preferred,auto,1.5\n")
+
+	rules, err := parseHyprlangConfig(main)
+	if err != nil {
+		t.Fatalf("parseHyprlangConfig() error = %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("parseHyprlangConfig() = %d rules, want 2: %+v", len(rules), rules)
+	}
+
+	laptop := rules[0]
+	if laptop.Target != "eDP-1" || laptop.Line != 1 || filepath.Base(laptop.File) != "laptop.conf" {
+		t.Errorf("first rule = %s:%d %s, want laptop.conf:1 eDP-1", laptop.File, laptop.Line, laptop.Target)
+	}
+
+	desk := rules[1]
+	if desk.Target != "DP-1" || desk.Monitor.PxW != 2560 || desk.Monitor.Hz != 144 {
+		t.Errorf("variables not expanded: %+v", desk.Monitor)
+	}
+	if desk.File != main || desk.Line != 4 || desk.Text != "monitor = $main, $mainmode, 0x0, 1" {
+		t.Errorf("second rule location = %s:%d %q", desk.File, desk.Line, desk.Text)
+	}
+}
+
+func TestParseHyprlangConfigReportsErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	loop := filepath.Join(dir, "loop.conf")
+	writeTestFile(t, loop, "source = loop.conf\n")
+	if _, err := parseHyprlangConfig(loop); err == nil || !strings.Contains(err.Error(), "sources itself") {
+		t.Errorf("source loop error = %v", err)
+	}
+
+	missing := filepath.Join(dir, "missing.conf")
+	writeTestFile(t, missing, "source = ./monitors.conf\n")
+	if _, err := parseHyprlangConfig(missing); err == nil || !strings.Contains(err.Error(), "missing.conf:1:") {
+		t.Errorf("missing include error = %v, want file:line", err)
+	}
+}
+
+func TestParseHyprlangConfigKeepsUnreadableRules(t *testing.T) {
+	bad := filepath.Join(t.TempDir(), "bad.conf")
+	writeTestFile(t, bad, "\n\nmonitor=DP-1,huge,0x0,1\nmonitor=HDMI-A-1,1920x1080@60,0x0,1\n")
+
+	rules, err := parseHyprlangConfig(bad)
+	if err != nil {
+		t.Fatalf("parseHyprlangConfig() error = %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("parseHyprlangConfig() = %d rules, want 2: %+v", len(rules), rules)
+	}
+	if rules[0].Err == nil || !strings.Contains(rules[0].Err.Error(), `invalid mode "huge"`) {
+		t.Errorf("bad rule error = %v", rules[0].Err)
+	}
+	if rules[0].Target != "DP-1" || rules[0].File != bad || rules[0].Line != 3 {
+		t.Errorf("bad rule location = %s:%d %s, want bad.conf:3 DP-1", rules[0].File, rules[0].Line, rules[0].Target)
+	}
+	if rules[1].Err != nil || rules[1].Monitor.Name != "HDMI-A-1" {
+		t.Errorf("rule after the bad one = %+v", rules[1])
+	}
+
+	monitors, skipped := monitorsFromRules(rules, nil)
+	if len(monitors) != 1 || monitors[0].Name != "HDMI-A-1" {
+		t.Errorf("monitorsFromRules() = %+v, want only HDMI-A-1", monitors)
+	}
+	if len(skipped) != 1 || skipped[0].Line != 3 {
+		t.Errorf("skipped = %+v, want the bad rule", skipped)
+	}
+}
+
+func TestMonitorsFromRules(t *testing.T) {
+	rules := []configMonitorRule{
+		{Target: "", Monitor: Monitor{Active: true}, Mode: "preferred", Position: "auto"},
+		{Target: "DP-1", Monitor: Monitor{Name: "DP-1", PxW: 1920, PxH: 1080, Hz: 60, Scale: 1, Active: true}},
+		{Target: "desc:Dell U2720Q", Monitor: Monitor{EDIDName: "Dell U2720Q", UseDescFormat: true, Scale: 1, Active: true}, Mode: "preferred", Position: "auto", AutoScale: true},
+		{Target: "desc:Projector", Monitor: Monitor{EDIDName: "Projector", UseDescFormat: true, Active: true}, Mode: "preferred", Position: "auto"},
+		// A later rule for the same output wins
+		{Target: "DP-1", Monitor: Monitor{Name: "DP-1", PxW: 2560, PxH: 1440, Hz: 60, Scale: 2, X: 100, Active: true}},
+	}
+	live := []Monitor{
+		{Name: "DP-1", HardwareID: "Acme/One/1"},
+		{Name: "DP-2", HardwareID: "Dell/U2720Q/2", EDIDName: "Dell U2720Q 2", PxW: 3840, PxH: 2160, Hz: 60, Scale: 1.5},
+	}
+
+	monitors, skipped := monitorsFromRules(rules, live)
+	if len(skipped) != 1 || skipped[0].Target != "desc:Projector" {
+		t.Errorf("skipped = %+v, want the unmatched projector rule", skipped)
+	}
+	if len(monitors) != 2 {
+		t.Fatalf("monitorsFromRules() = %+v, want 2 monitors", monitors)
+	}
+
+	desk := monitors[0]
+	if desk.Name != "DP-1" || desk.PxW != 2560 || desk.HardwareID != "Acme/One/1" {
+		t.Errorf("DP-1 = %+v", desk)
+	}
+
+	dell := monitors[1]
+	if dell.Name != "DP-2" || dell.HardwareID != "Dell/U2720Q/2" || !dell.UseDescFormat {
+		t.Errorf("desc rule not resolved: %+v", dell)
+	}
+	if dell.PxW != 3840 || dell.Scale != 1.5 {
+		t.Errorf("preferred mode/auto scale not taken from the connected monitor: %+v", dell)
+	}
+	// DP-1 is 1280 logical pixels wide at scale 2, starting at x=100
+	if dell.X != 1380 || dell.Y != 0 {
+		t.Errorf("auto position = %d,%d, want 1380,0", dell.X, dell.Y)
+	}
+}
+
+func TestImportConfigProfile(t *testing.T) {
+	useTempConfigDir(t)
+
+	confPath := filepath.Join(t.TempDir(), "hyprland.conf")
+	writeTestFile(t, confPath, "monitor=DP-1,1920x1080@60,0x0,1\nmonitor=HDMI-A-1,1920x1080@60,1920x0,1\n")
+	t.Setenv("HYPRLAND_CONFIG", confPath)
+	startFakeHyprland(t, func(string) string {
+		return `[{"id":0,"name":"DP-1","make":"Acme","model":"One","serial":"1","width":1920,"height":1080}]`
+	})
+
+	monitors, _, err := importConfigProfile("desk")
+	if err != nil {
+		t.Fatalf("importConfigProfile() error = %v", err)
+	}
+	if len(monitors) != 2 {
+		t.Fatalf("importConfigProfile() = %d monitors, want 2", len(monitors))
+	}
+
+	profile, err := loadProfile("desk")
+	if err != nil {
+		t.Fatalf("loadProfile() error = %v", err)
+	}
+	if profile.Monitors[0].HardwareID != "Acme/One/1" || profile.Monitors[1].Name != "HDMI-A-1" {
+		t.Errorf("saved profile monitors = %+v", profile.Monitors)
+	}
+}
+
+func TestParseHyprlangConfigReadsMonitorV2Blocks(t *testing.T) {
+	want := []Monitor{
+		{Name: "DP-1", PxW: 3840, PxH: 2160, Hz: 144, X: 0, Y: 0, Scale: 1.5, Active: true,
+			BitDepth: 10, ColorMode: "hdr", SDRBrightness: 1.2, SDRMinLuminance: 0.005, SDRMaxLuminance: 250},
+		{Name: "HDMI-A-1", PxW: 1920, PxH: 1080, Hz: 60, X: 2560, Y: 0, Scale: 1, Active: true, IsMirrored: true, MirrorSource: "DP-1"},
+		{Name: "eDP-1"},
+	}
+
+	// Round trip through the writer
+	var blocks []string
+	for _, m := range want {
+		blocks = append(blocks, generateMonitorV2Block(m))
+	}
+	path := filepath.Join(t.TempDir(), "hyprland.conf")
+	writeTestFile(t, path, strings.Join(blocks, "\n")+"\n")
+
+	rules, err := parseHyprlangConfig(path)
+	if err != nil {
+		t.Fatalf("parseHyprlangConfig() error = %v", err)
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("parseHyprlangConfig() = %d rules, want %d", len(rules), len(want))
+	}
+	for i, rule := range rules {
+		if !monitorsEqualForTest(rule.Monitor, want[i]) || rule.Monitor.SDRMinLuminance != want[i].SDRMinLuminance || rule.Monitor.SDRMaxLuminance != want[i].SDRMaxLuminance {
+			t.Errorf("rule %d = %+v, want %+v", i, rule.Monitor, want[i])
+		}
+	}
+	if rules[0].Line != 1 || !strings.HasPrefix(rules[0].Text, "monitorv2 { output = DP-1, mode = 3840x2160@144.00") {
+		t.Errorf("first rule location = line %d %q", rules[0].Line, rules[0].Text)
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing.conf")
+	writeTestFile(t, missing, "monitorv2 {\n    mode = preferred\n}\n")
+	rules, err = parseHyprlangConfig(missing)
+	if err != nil {
+		t.Fatalf("parseHyprlangConfig() error = %v", err)
+	}
+	if len(rules) != 1 || rules[0].Err == nil || !strings.Contains(rules[0].Err.Error(), "has no output") || rules[0].Line != 1 {
+		t.Errorf("block without output = %+v", rules)
+	}
+}
+
+func TestHyprlangRulesFile(t *testing.T) {
+	monitors := []Monitor{{Name: "DP-1", Active: true}}
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "sourced file with the rules",
+			files: map[string]string{
+				"hyprland.conf":          "monitor=,preferred,auto,1\nsource = ./conf.d/monitors.conf\n",
+				"conf.d/monitors.conf":   "monitor=DP-1,2560x1440@60,0x0,1\n",
+				"conf.d/workspaces.conf": "workspace=1,monitor:DP-1\n",
+			},
+			want: "conf.d/monitors.conf",
+		},
+		{
+			name: "existing block wins",
+			files: map[string]string{
+				"hyprland.conf": "source = ./monitors.conf\n" + hyprmonBlockBegin + "\n" + hyprmonBlockEnd + "\n",
+				"monitors.conf": "monitor=DP-1,2560x1440@60,0x0,1\n",
+			},
+			want: "hyprland.conf",
+		},
+		{
+			name: "only other monitors",
+			files: map[string]string{
+				"hyprland.conf": "source = ./monitors.conf\n",
+				"monitors.conf": "monitor=HDMI-A-1,preferred,auto,1\n",
+			},
+			want: "monitors.conf",
+		},
+		{
+			name: "no rules",
+			files: map[string]string{
+				"hyprland.conf": "$mod = SUPER\n",
+			},
+			want: "hyprland.conf",
+		},
+		{
+			name: "unreadable rules still count",
+			files: map[string]string{
+				"hyprland.conf": "source = ./monitors.conf\nmonitor=HDMI-A-1,1920x1080@60,0x0,1\n",
+				"monitors.conf": "monitor=DP-1,huge,0x0,1\n",
+			},
+			want: "monitors.conf",
+		},
+		{
+			name: "sidecar include is skipped",
+			files: map[string]string{
+				"hyprland.conf": "source = ./hyprmon.conf\n",
+				"hyprmon.conf":  "monitor=DP-1,2560x1440@60,0x0,1\n",
+			},
+			want: "hyprland.conf",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				writeTestFile(t, filepath.Join(dir, name), content)
+			}
+			got, err := hyprlangRulesFile(filepath.Join(dir, "hyprland.conf"), monitors)
+			if err != nil {
+				t.Fatalf("hyprlangRulesFile() error = %v", err)
+			}
+			if want := filepath.Join(dir, tt.want); got != want {
+				t.Errorf("hyprlangRulesFile() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestWriteConfigUpdatesSourcedMonitorsFile(t *testing.T) {
+	useTempConfigDir(t)
+
+	dir := t.TempDir()
+	main := filepath.Join(dir, "hyprland.conf")
+	mainContent := "source = ./monitors.conf\n$mod = SUPER\n"
+	writeTestFile(t, main, mainContent)
+	monitorsPath := filepath.Join(dir, "monitors.conf")
+	writeTestFile(t, monitorsPath, "# desk\nmonitor=DP-1,1920x1080@60,0x0,1\n")
+	t.Setenv("HYPRLAND_CONFIG", main)
+
+	monitors := []Monitor{{Name: "DP-1", PxW: 2560, PxH: 1440, Hz: 60, Scale: 1, Active: true}}
+	if err := writeConfig(monitors, nil); err != nil {
+		t.Fatalf("writeConfig() error = %v", err)
+	}
+
+	if data, _ := os.ReadFile(main); string(data) != mainContent {
+		t.Errorf("hyprland.conf was changed:\n%s", data)
+	}
+	data, _ := os.ReadFile(monitorsPath)
+	if !strings.Contains(string(data), hyprmonBlockBegin+"\n") || !strings.Contains(string(data), generateMonitorLine(monitors[0])) ||
+		strings.Contains(string(data), "1920x1080") {
+		t.Errorf("monitors.conf =\n%s", data)
+	}
+
+	if path, err := configRulesPath(monitors); err != nil || path != monitorsPath {
+		t.Errorf("configRulesPath() = %s, %v, want %s", path, err, monitorsPath)
+	}
+}
+
+func TestWriteConfigStopsOnUnreadableInclude(t *testing.T) {
+	useTempConfigDir(t)
+
+	main := filepath.Join(t.TempDir(), "hyprland.conf")
+	mainContent := "source = ./monitors.conf\n$mod = SUPER\n"
+	writeTestFile(t, main, mainContent)
+	t.Setenv("HYPRLAND_CONFIG", main)
+	monitors := []Monitor{{Name: "DP-1", PxW: 2560, PxH: 1440, Hz: 60, Scale: 1, Active: true}}
+
+	if err := writeConfig(monitors, nil); err == nil || !strings.Contains(err.Error(), "monitors.conf") {
+		t.Errorf("writeConfig() error = %v, want the missing monitors.conf reported", err)
+	}
+	if data, _ := os.ReadFile(main); string(data) != mainContent {
+		t.Errorf("hyprland.conf was changed:\n%s", data)
+	}
+	if _, err := plannedConfigChanges(monitors, nil); err == nil {
+		t.Error("plannedConfigChanges() planned a write to hyprland.conf")
+	}
+}
@@ -1,19 +1,18 @@
 package main
 
 import (
-	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 )
 
 const (
-	// hyprctlTimeout is the timeout for hyprctl commands
+	// hyprctlTimeout is the timeout for a Hyprland IPC request
 	hyprctlTimeout = 5 * time.Second
 
 	// File permissions
@@ -36,6 +35,27 @@ const (
 
 	hyprmonLuaRequireComment = "-- hyprmon: managed monitor profile include"
 	hyprmonLuaRequireLine    = `require("hyprmon")`
+
+	// Markers around the rules hyprmon owns in hyprmon.lua
+	hyprmonLuaBlockBegin   = "-- BEGIN hyprmon"
+	hyprmonLuaBlockEnd     = "-- END hyprmon"
+	hyprmonLuaBlockComment = "-- Generated by HyprMon. Changes inside this block may be overwritten."
+
+	// Header of hyprmon.lua files written before the managed block
+	hyprmonLuaLegacyHeader = "-- Generated by HyprMon. Manual changes may be overwritten."
+
+	// Markers around the monitor rules hyprmon owns in hyprland.conf
+	hyprmonBlockBegin   = "# BEGIN hyprmon"
+	hyprmonBlockEnd     = "# END hyprmon"
+	hyprmonBlockComment = "# Generated by HyprMon. Changes inside this block may be overwritten."
+
+	// hyprland.conf include for hyprmon.conf in sidecar mode
+	hyprmonSourceComment = "# hyprmon: managed monitor profile include"
+	hyprmonSourceLine    = "source = ./hyprmon.conf"
+
+	// Prefix of monitor lines commented out because hyprmon.conf manages
+	// their monitor
+	hyprmonDisabledPrefix = "# hyprmon: "
 )
 
 type configFormat int
@@ -73,6 +93,9 @@ func isValidColorMode(mode string) bool {
 	validModes := map[string]bool{
 		"auto":    true,
 		"srgb":    true,
+		"dcip3":   true,
+		"dp3":     true,
+		"adobe":   true,
 		"wide":    true,
 		"edid":    true,
 		"hdr":     true,
@@ -128,32 +151,6 @@ func applyMonitorPrefs(monitors []Monitor, s *Settings) {
 	}
 }
 
-// execHyprctl executes a hyprctl command with the given arguments and returns the output
-func execHyprctl(args ...string) ([]byte, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), hyprctlTimeout)
-	defer cancel()
-
-	cmd := exec.CommandContext(ctx, "hyprctl", args...)
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute hyprctl %v: %w", args, err)
-	}
-	return output, nil
-}
-
-// execHyprctlJSON executes a hyprctl command and unmarshals the JSON output into the provided result
-func execHyprctlJSON(result interface{}, args ...string) error {
-	output, err := execHyprctl(args...)
-	if err != nil {
-		return err
-	}
-
-	if err := json.Unmarshal(output, result); err != nil {
-		return fmt.Errorf("failed to parse JSON from hyprctl %v: %w", args, err)
-	}
-	return nil
-}
-
 type hyprMonitor struct {
 	ID              int     `json:"id"`
 	Name            string  `json:"name"`
@@ -194,14 +191,24 @@ type hyprWorkspace struct {
 
 func readMonitors() ([]Monitor, error) {
 	var hyprMonitors []hyprMonitor
-	if err := execHyprctlJSON(&hyprMonitors, "monitors", "all", "-j"); err != nil {
+	if err := hyprRequestJSON(&hyprMonitors, "monitors all"); err != nil {
 		return nil, err
 	}
 
 	monitors := make([]Monitor, 0, len(hyprMonitors))
 
+	// mirrorOf holds the source monitor's ID; map it back to a name
+	namesByID := make(map[string]string, len(hyprMonitors))
+	for _, hm := range hyprMonitors {
+		namesByID[strconv.Itoa(hm.ID)] = hm.Name
+	}
+
 	// First pass: create monitors without mirror relationships
 	for _, hm := range hyprMonitors {
+		if name, ok := namesByID[hm.MirrorOf]; ok {
+			hm.MirrorOf = name
+		}
+
 		modes := make([]Mode, 0, len(hm.AvailableModes))
 		for _, modeStr := range hm.AvailableModes {
 			if mode := parseMode(modeStr); mode != nil {
@@ -277,7 +284,7 @@ func readMonitors() ([]Monitor, error) {
 // getAvailableModes returns the available modes for a specific monitor
 func getAvailableModes(monitorName string) ([]string, error) {
 	var hyprMonitors []hyprMonitor
-	if err := execHyprctlJSON(&hyprMonitors, "monitors", "all", "-j"); err != nil {
+	if err := hyprRequestJSON(&hyprMonitors, "monitors all"); err != nil {
 		return nil, err
 	}
 
@@ -324,99 +331,335 @@ func parseMode(modeStr string) *Mode {
 	}
 }
 
-func applyMonitor(m Monitor) error {
-	target, err := getConfigTarget()
+func applyMonitorHyprlang(m Monitor) error {
+	command, err := hyprlangApplyCommand(m)
 	if err != nil {
-		return fmt.Errorf("could not determine config path: %w", err)
+		return err
 	}
+	return hyprCommand(command)
+}
 
-	switch target.Format {
-	case configFormatLua:
-		return applyMonitorLua(m)
-	default:
-		return applyMonitorHyprlang(m)
+// luaApplyChunk returns the Lua chunk that applies monitors live on a Lua
+// config, one hl.monitor call per line in the order given, so that line N
+// of the chunk belongs to monitors[N-1].
+func luaApplyChunk(monitors []Monitor) (string, error) {
+	rules := make([]string, 0, len(monitors))
+	for _, m := range monitors {
+		// generateLuaMonitorRule comments out unsafe names; refuse them here
+		if !isValidMonitorName(m.Name) {
+			return "", fmt.Errorf("invalid monitor name: %s", m.Name)
+		}
+		if m.Active && m.IsMirrored && m.MirrorSource != "" && !isValidMonitorName(m.MirrorSource) {
+			return "", fmt.Errorf("invalid mirror source name: %s", m.MirrorSource)
+		}
+		rules = append(rules, generateLuaMonitorRule(m))
 	}
+	return strings.Join(rules, "\n"), nil
 }
 
-func applyMonitorLua(m Monitor) error {
-	_, err := execHyprctl("eval", generateLuaMonitorRule(m))
-	return err
+// luaApplyCommand is the IPC request that applies monitors live on a Lua
+// config in a single eval, so Hyprland reconfigures the outputs once.
+func luaApplyCommand(monitors []Monitor) (string, error) {
+	chunk, err := luaApplyChunk(monitors)
+	if err != nil {
+		return "", err
+	}
+	return "eval " + chunk, nil
 }
 
-func applyMonitorHyprlang(m Monitor) error {
-	// Validate monitor name to prevent command injection
+// luaErrorLinePattern finds the chunk line in a Lua error message such as
+// [string "hl.monitor({ ..."]:2: bad argument.
+var luaErrorLinePattern = regexp.MustCompile(`\]:(\d+):`)
+
+// luaErrorMonitor maps an error Hyprland returned for an apply chunk back
+// to the index of the monitor that caused it: by the line number in a Lua
+// error, or else by the one monitor the message names. It returns -1 if the
+// error can't be attributed.
+func luaErrorMonitor(reply string, monitors []Monitor) int {
+	if match := luaErrorLinePattern.FindStringSubmatch(reply); match != nil {
+		if line, err := strconv.Atoi(match[1]); err == nil && line >= 1 && line <= len(monitors) {
+			return line - 1
+		}
+	}
+
+	found := -1
+	for i, m := range monitors {
+		if !strings.Contains(reply, m.Name) && !strings.Contains(reply, resolveMonitorIdentifier(m)) {
+			continue
+		}
+		if found != -1 {
+			return -1
+		}
+		found = i
+	}
+	return found
+}
+
+// hyprlangApplyCommand is the IPC request that applies m live on a
+// hyprlang config.
+func hyprlangApplyCommand(m Monitor) (string, error) {
+	rule, err := monitorKeywordRule(m)
+	if err != nil {
+		return "", err
+	}
+	return "keyword monitor " + rule, nil
+}
+
+// monitorKeywordRule builds the value passed to "keyword monitor" for live
+// application. Unlike generateMonitorLine it always uses the connector name,
+// and it refuses (rather than comments out) anything unsafe to send.
+func monitorKeywordRule(m Monitor) (string, error) {
+	// Validate monitor name to keep the IPC request well-formed
 	if !isValidMonitorName(m.Name) {
-		return fmt.Errorf("invalid monitor name: %s", m.Name)
+		return "", fmt.Errorf("invalid monitor name: %s", m.Name)
 	}
 
 	// Validate color mode if set
 	if !isValidColorMode(m.ColorMode) {
-		return fmt.Errorf("invalid color mode: %s", m.ColorMode)
+		return "", fmt.Errorf("invalid color mode: %s", m.ColorMode)
 	}
 
-	var cmd string
-	if m.Active {
-		if m.IsMirrored && m.MirrorSource != "" {
-			// Validate mirror source name
-			if !isValidMonitorName(m.MirrorSource) {
-				return fmt.Errorf("invalid mirror source name: %s", m.MirrorSource)
-			}
-			// Mirror syntax: monitor=NAME,resolution,position,scale,mirror,SOURCE_MONITOR
-			cmd = fmt.Sprintf("hyprctl keyword monitor \"%s,%dx%d@%.2f,%dx%d,%.2f,mirror,%s\"",
-				m.Name, m.PxW, m.PxH, m.Hz, m.X, m.Y, m.Scale, m.MirrorSource)
-		} else {
-			// Build base command for regular monitor
-			cmd = fmt.Sprintf("hyprctl keyword monitor \"%s,%dx%d@%.2f,%dx%d,%.2f",
-				m.Name, m.PxW, m.PxH, m.Hz, m.X, m.Y, m.Scale)
+	if !m.Active {
+		return fmt.Sprintf("%s,disable", m.Name), nil
+	}
 
-			// Add advanced settings (only for non-mirrored monitors)
-			if m.BitDepth == 10 {
-				cmd += ",bitdepth,10"
-			}
+	if m.IsMirrored && m.MirrorSource != "" {
+		// Validate mirror source name
+		if !isValidMonitorName(m.MirrorSource) {
+			return "", fmt.Errorf("invalid mirror source name: %s", m.MirrorSource)
+		}
+		// Mirror syntax: NAME,resolution,position,scale,mirror,SOURCE_MONITOR
+		return fmt.Sprintf("%s,%dx%d@%.2f,%dx%d,%.2f,mirror,%s",
+			m.Name, m.PxW, m.PxH, m.Hz, m.X, m.Y, m.Scale, m.MirrorSource), nil
+	}
 
-			if m.ColorMode != "" && m.ColorMode != "srgb" {
-				cmd += fmt.Sprintf(",cm,%s", m.ColorMode)
-			}
+	// Build base rule for regular monitor
+	rule := fmt.Sprintf("%s,%dx%d@%.2f,%dx%d,%.2f",
+		m.Name, m.PxW, m.PxH, m.Hz, m.X, m.Y, m.Scale)
 
-			// SDR settings only apply when in HDR mode
-			if m.ColorMode == "hdr" || m.ColorMode == "hdredid" {
-				if m.SDRBrightness != 0 && m.SDRBrightness != 1.0 {
-					cmd += fmt.Sprintf(",sdrbrightness,%.2f", m.SDRBrightness)
-				}
-				if m.SDRSaturation != 0 && m.SDRSaturation != 1.0 {
-					cmd += fmt.Sprintf(",sdrsaturation,%.2f", m.SDRSaturation)
-				}
-			}
+	// Add advanced settings (only for non-mirrored monitors)
+	if m.BitDepth == 10 {
+		rule += ",bitdepth,10"
+	}
 
-			if m.VRR > 0 {
-				cmd += fmt.Sprintf(",vrr,%d", m.VRR)
-			}
+	if m.ColorMode != "" && m.ColorMode != "srgb" {
+		rule += fmt.Sprintf(",cm,%s", m.ColorMode)
+	}
 
-			if m.Transform > 0 {
-				cmd += fmt.Sprintf(",transform,%d", m.Transform)
-			}
+	// SDR settings only apply when in HDR mode
+	if m.ColorMode == "hdr" || m.ColorMode == "hdredid" {
+		if m.SDRBrightness != 0 && m.SDRBrightness != 1.0 {
+			rule += fmt.Sprintf(",sdrbrightness,%.2f", m.SDRBrightness)
+		}
+		if m.SDRSaturation != 0 && m.SDRSaturation != 1.0 {
+			rule += fmt.Sprintf(",sdrsaturation,%.2f", m.SDRSaturation)
+		}
+	}
 
-			cmd += "\""
+	if m.VRR > 0 {
+		rule += fmt.Sprintf(",vrr,%d", m.VRR)
+	}
+
+	if m.Transform > 0 {
+		rule += fmt.Sprintf(",transform,%d", m.Transform)
+	}
+
+	return rule, nil
+}
+
+// applyError is returned by applyMonitors when a monitor could not be
+// applied. The monitors changed before it have been put back to their
+// previous settings, unless RestoreErr says otherwise.
+type applyError struct {
+	Monitor    string   // monitor whose rule Hyprland rejected, if known
+	Err        error    // why it was rejected
+	Restored   []string // monitors changed earlier and restored afterwards
+	RestoreErr error    // set if restoring those monitors failed too
+}
+
+func (e *applyError) Error() string {
+	msg := fmt.Sprintf("monitor %s failed: %v", e.Monitor, e.Err)
+	if e.Monitor == "" {
+		msg = fmt.Sprintf("applying monitors failed: %v", e.Err)
+	}
+	switch {
+	case e.RestoreErr != nil:
+		msg += fmt.Sprintf("; restoring previous settings failed: %v", e.RestoreErr)
+	case len(e.Restored) > 0:
+		msg += fmt.Sprintf("; restored %s to previous settings", strings.Join(e.Restored, ", "))
+	default:
+		msg += "; no monitors were changed"
+	}
+	return msg
+}
+
+func (e *applyError) Unwrap() error {
+	return e.Err
+}
+
+// orderMonitorsForApply returns monitors in an order that is safe to apply
+// one by one: active outputs first, then mirrors after the monitor they
+// mirror, and disabled outputs last, so there is always an enabled output
+// and a mirror's source exists before the mirror is set up. Monitors keep
+// their relative order within each group.
+func orderMonitorsForApply(monitors []Monitor) []Monitor {
+	ordered := make([]Monitor, 0, len(monitors))
+	var mirrors, disabled []Monitor
+	for _, m := range monitors {
+		switch {
+		case !m.Active:
+			disabled = append(disabled, m)
+		case m.IsMirrored && m.MirrorSource != "":
+			mirrors = append(mirrors, m)
+		default:
+			ordered = append(ordered, m)
 		}
-	} else {
-		cmd = fmt.Sprintf("hyprctl keyword monitor \"%s,disable\"", m.Name)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), hyprctlTimeout)
-	defer cancel()
+	// Mirrors can chain (A mirrors B, B mirrors C). Place each one once its
+	// source has been placed or isn't part of this apply at all.
+	inApply := make(map[string]bool, len(monitors))
+	for _, m := range monitors {
+		inApply[m.Name] = true
+	}
+	placed := make(map[string]bool, len(monitors))
+	for _, m := range ordered {
+		placed[m.Name] = true
+	}
+	for len(mirrors) > 0 {
+		var waiting []Monitor
+		for _, m := range mirrors {
+			if placed[m.MirrorSource] || !inApply[m.MirrorSource] {
+				ordered = append(ordered, m)
+				placed[m.Name] = true
+			} else {
+				waiting = append(waiting, m)
+			}
+		}
+		if len(waiting) == len(mirrors) {
+			// A cycle or a disabled source; keep the remaining order as is.
+			ordered = append(ordered, waiting...)
+			break
+		}
+		mirrors = waiting
+	}
 
-	return exec.CommandContext(ctx, "sh", "-c", cmd).Run()
+	return append(ordered, disabled...)
 }
 
+// applyMonitors applies all monitors or none: the live state is captured
+// first, and if any monitor fails the ones already changed are restored.
 func applyMonitors(monitors []Monitor) error {
-	for _, m := range monitors {
-		if err := applyMonitor(m); err != nil {
-			return fmt.Errorf("failed to apply monitor %s: %w", m.Name, err)
+	target, err := getConfigTarget()
+	if err != nil {
+		return fmt.Errorf("could not determine config path: %w", err)
+	}
+
+	live, err := readMonitors()
+	if err != nil {
+		return fmt.Errorf("failed to read current monitors: %w", err)
+	}
+	liveByName := make(map[string]Monitor, len(live))
+	for _, m := range live {
+		liveByName[m.Name] = m
+	}
+
+	if target.Format == configFormatLua {
+		return applyMonitorsLua(orderMonitorsForApply(monitors), liveByName)
+	}
+
+	var changed []string
+	for _, m := range orderMonitorsForApply(monitors) {
+		if err := applyMonitorHyprlang(m); err != nil {
+			applyErr := &applyError{Monitor: m.Name, Err: err}
+			applyErr.Restored, applyErr.RestoreErr = restoreMonitors(changed, liveByName)
+			return applyErr
 		}
+		changed = append(changed, m.Name)
 	}
 	return nil
 }
 
+// restoreMonitors puts the named monitors back to their captured settings,
+// newest change first. It keeps going after a failure so as many monitors
+// as possible end up restored.
+func restoreMonitors(names []string, previous map[string]Monitor) ([]string, error) {
+	var restored []string
+	var errs []error
+	for i := len(names) - 1; i >= 0; i-- {
+		prev, ok := previous[names[i]]
+		if !ok {
+			errs = append(errs, fmt.Errorf("no previous settings for %s", names[i]))
+			continue
+		}
+		if err := applyMonitorHyprlang(prev); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", names[i], err))
+			continue
+		}
+		restored = append(restored, names[i])
+	}
+	return restored, errors.Join(errs...)
+}
+
+// applyMonitorsLua applies monitors, already in apply order, with a single
+// eval. Lua stops at the first failing call, so when Hyprland reports an
+// error the monitors before the offending one are restored, again in one
+// eval. If the error can't be mapped to a monitor, all of them are.
+func applyMonitorsLua(monitors []Monitor, previous map[string]Monitor) error {
+	command, err := luaApplyCommand(monitors)
+	if err != nil {
+		return &applyError{Err: err}
+	}
+
+	response, err := hyprRequest(command)
+	if err == nil {
+		reply := strings.TrimSpace(string(response))
+		if reply == "" || reply == "ok" {
+			return nil
+		}
+		err = errors.New(reply)
+	}
+
+	applyErr := &applyError{Err: err}
+	changed := monitors
+	if failed := luaErrorMonitor(err.Error(), monitors); failed != -1 {
+		applyErr.Monitor = monitors[failed].Name
+		applyErr.Err = fmt.Errorf("hyprland rejected %q: %w", generateLuaMonitorRule(monitors[failed]), err)
+		changed = monitors[:failed]
+	}
+	applyErr.Restored, applyErr.RestoreErr = restoreMonitorsLua(changed, previous)
+	return applyErr
+}
+
+// restoreMonitorsLua puts monitors back to their captured settings with a
+// single eval.
+func restoreMonitorsLua(monitors []Monitor, previous map[string]Monitor) ([]string, error) {
+	var names []string
+	var rules []Monitor
+	var errs []error
+	for _, m := range monitors {
+		prev, ok := previous[m.Name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("no previous settings for %s", m.Name))
+			continue
+		}
+		names = append(names, m.Name)
+		rules = append(rules, prev)
+	}
+	if len(rules) == 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	command, err := luaApplyCommand(orderMonitorsForApply(rules))
+	if err == nil {
+		err = hyprCommand(command)
+	}
+	if err != nil {
+		return nil, errors.Join(append(errs, err)...)
+	}
+	return names, errors.Join(errs...)
+}
+
 func cleanAbsoluteConfigPath(path string) (string, error) {
 	cleanPath := filepath.Clean(path)
 	if !filepath.IsAbs(cleanPath) {
@@ -554,6 +797,71 @@ func generateMonitorLine(m Monitor) string {
 	return monLine
 }
 
+// generateMonitorV2Block returns the monitorv2 { ... } form of a monitor
+// rule. It carries the same settings as generateMonitorLine, plus the HDR
+// luminance overrides the positional form has no room for.
+func generateMonitorV2Block(m Monitor) string {
+	if !isValidMonitorName(m.Name) {
+		return fmt.Sprintf("# Invalid monitor name: %s", m.Name)
+	}
+
+	fields := []string{"output = " + resolveMonitorIdentifier(m)}
+
+	if !m.Active {
+		fields = append(fields, "disabled = true")
+	} else {
+		fields = append(fields,
+			fmt.Sprintf("mode = %dx%d@%.2f", m.PxW, m.PxH, m.Hz),
+			fmt.Sprintf("position = %dx%d", m.X, m.Y),
+			fmt.Sprintf("scale = %.2f", m.Scale),
+		)
+
+		if m.IsMirrored && m.MirrorSource != "" {
+			if !isValidMonitorName(m.MirrorSource) {
+				return fmt.Sprintf("# Invalid mirror source: %s", m.MirrorSource)
+			}
+			fields = append(fields, "mirror = "+m.MirrorSource)
+		} else {
+			if m.BitDepth == 10 {
+				fields = append(fields, "bitdepth = 10")
+			}
+			if m.ColorMode != "" && m.ColorMode != "srgb" && isValidColorMode(m.ColorMode) {
+				fields = append(fields, "cm = "+m.ColorMode)
+			}
+			if m.ColorMode == "hdr" || m.ColorMode == "hdredid" {
+				if m.SDRBrightness != 0 && m.SDRBrightness != 1.0 {
+					fields = append(fields, fmt.Sprintf("sdrbrightness = %.2f", m.SDRBrightness))
+				}
+				if m.SDRSaturation != 0 && m.SDRSaturation != 1.0 {
+					fields = append(fields, fmt.Sprintf("sdrsaturation = %.2f", m.SDRSaturation))
+				}
+				if m.SDRMinLuminance != 0 {
+					fields = append(fields, fmt.Sprintf("sdr_min_luminance = %.3f", m.SDRMinLuminance))
+				}
+				if m.SDRMaxLuminance != 0 {
+					fields = append(fields, fmt.Sprintf("sdr_max_luminance = %d", m.SDRMaxLuminance))
+				}
+			}
+			if m.VRR > 0 {
+				fields = append(fields, fmt.Sprintf("vrr = %d", m.VRR))
+			}
+			if m.Transform > 0 {
+				fields = append(fields, fmt.Sprintf("transform = %d", m.Transform))
+			}
+		}
+	}
+
+	return "monitorv2 {\n    " + strings.Join(fields, "\n    ") + "\n}"
+}
+
+// generateMonitorRule returns a monitor rule in the given syntax.
+func generateMonitorRule(m Monitor, syntax string) string {
+	if syntax == monitorSyntaxV2 {
+		return generateMonitorV2Block(m)
+	}
+	return generateMonitorLine(m)
+}
+
 func luaString(s string) string {
 	return fmt.Sprintf("%q", s)
 }
@@ -609,7 +917,50 @@ func generateLuaMonitorRule(m Monitor) string {
 	return fmt.Sprintf("hl.monitor({ %s })", strings.Join(fields, ", "))
 }
 
-func writeConfig(monitors []Monitor) error {
+// conflictPolicy says what a config writer does when the block hyprmon
+// manages was edited by hand since hyprmon last wrote it.
+type conflictPolicy int
+
+const (
+	// conflictAsk returns a *configEditedError and writes nothing
+	conflictAsk conflictPolicy = iota
+	// conflictOverwrite backs the file up and overwrites the block
+	conflictOverwrite
+	// conflictMerge backs the file up, moves hand-written rules for other
+	// monitors out of the block so they are kept, and rewrites the block
+	conflictMerge
+)
+
+// configEditedError reports that the managed block in a file was edited
+// since hyprmon last wrote it, so saving would discard those edits.
+type configEditedError struct {
+	Path string
+}
+
+func (e *configEditedError) Error() string {
+	if filepath.Base(e.Path) == "hyprmon.conf" {
+		return fmt.Sprintf("%s was edited since HyprMon last saved it; saving replaces the whole file", e.Path)
+	}
+	return fmt.Sprintf("%s was edited since HyprMon last saved it; saving replaces everything between the BEGIN hyprmon and END hyprmon markers", e.Path)
+}
+
+// writeConfig saves monitors, and the workspace rules for them, to the
+// Hyprland config without asking. If the managed block was edited by hand
+// it warns, keeps a backup, and merges: rules for other monitors are kept,
+// the rest of the block is replaced.
+func writeConfig(monitors []Monitor, workspaces []WorkspaceRule) error {
+	err := writeConfigWithPolicy(monitors, workspaces, conflictAsk)
+	var edited *configEditedError
+	if errors.As(err, &edited) {
+		fmt.Fprintf(os.Stderr, "warning: %v; keeping its rules for other monitors (the old file is backed up)\n", err)
+		return writeConfigWithPolicy(monitors, workspaces, conflictMerge)
+	}
+	return err
+}
+
+// writeConfigWithPolicy saves monitors and workspace rules to the Hyprland
+// config, handling hand edits to the managed block according to policy.
+func writeConfigWithPolicy(monitors []Monitor, workspaces []WorkspaceRule, policy conflictPolicy) error {
 	target, err := getConfigTarget()
 	if err != nil {
 		return fmt.Errorf("could not determine config path: %w", err)
@@ -617,98 +968,701 @@ func writeConfig(monitors []Monitor) error {
 
 	switch target.Format {
 	case configFormatLua:
-		return writeLuaConfig(target.Path, monitors)
+		return writeLuaConfig(target.Path, monitors, workspaces, policy)
 	default:
-		return writeHyprlangConfig(target.Path, monitors)
+		s, err := loadSettings()
+		if err != nil {
+			return err
+		}
+		if getHyprlangMode(s) == hyprlangModeSidecar {
+			return writeHyprlangSidecarConfig(target.Path, monitors, workspaces, getMonitorSyntax(s), policy)
+		}
+		rulesPath, err := hyprlangRulesFile(target.Path, monitors)
+		if err != nil {
+			return err
+		}
+		return writeHyprlangConfig(rulesPath, monitors, workspaces, getMonitorSyntax(s), policy)
+	}
+}
+
+// configRulesPath returns the file saving monitors writes their rules to:
+// hyprmon.lua or hyprmon.conf in sidecar setups, otherwise the hyprlang
+// file picked by hyprlangRulesFile.
+func configRulesPath(monitors []Monitor) (string, error) {
+	target, err := getConfigTarget()
+	if err != nil {
+		return "", fmt.Errorf("could not determine config path: %w", err)
+	}
+	if target.Format == configFormatLua {
+		return luaSidecarPath(target.Path), nil
+	}
+	s, err := loadSettings()
+	if err != nil {
+		return "", err
+	}
+	if getHyprlangMode(s) == hyprlangModeSidecar {
+		return hyprlangSidecarPath(target.Path), nil
+	}
+	return hyprlangRulesFile(target.Path, monitors)
+}
+
+// checkConfigEdited returns a *configEditedError when the rules saving
+// monitors would replace were edited since hyprmon last wrote them, so
+// the question can be asked before any hooks run.
+func checkConfigEdited(monitors []Monitor) error {
+	path, err := configRulesPath(monitors)
+	if err != nil {
+		return err
+	}
+	content, exists, err := readFileIfExists(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	if !exists {
+		return nil
+	}
+
+	var block string
+	var ok bool
+	switch {
+	case strings.HasSuffix(path, ".lua"):
+		block, ok = luaManagedBlock(content)
+	case filepath.Base(path) == "hyprmon.conf":
+		// All of hyprmon.conf is written by hyprmon
+		block, ok = content, true
+	default:
+		block, ok = hyprlangManagedBlock(content)
+	}
+	if managedBlockEdited(path, block, ok) {
+		return &configEditedError{Path: path}
 	}
+	return nil
+}
+
+// displayConfigPath shortens a config path for the status line: relative
+// to the Hyprland config directory when it is inside it, otherwise with
+// the home directory written as ~.
+func displayConfigPath(path string) string {
+	if dir, err := getHyprConfigDir(); err == nil {
+		if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil && home != "" {
+		if rest, ok := strings.CutPrefix(path, home+string(filepath.Separator)); ok {
+			return filepath.Join("~", rest)
+		}
+	}
+	return path
 }
 
-func writeHyprlangConfig(configPath string, monitors []Monitor) error {
+func writeHyprlangConfig(configPath string, monitors []Monitor, workspaces []WorkspaceRule, syntax string, policy conflictPolicy) error {
 	if configPath == "" {
 		return fmt.Errorf("could not determine config path")
 	}
 
-	backupPath := fmt.Sprintf("%s.bak.%d", configPath, time.Now().Unix())
+	input, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	// The file is read at save time, so edits made elsewhere while the TUI
+	// was open are kept; only the block needs checking
+	current := string(input)
+	if block, ok := hyprlangManagedBlock(current); managedBlockEdited(configPath, block, ok) {
+		switch policy {
+		case conflictAsk:
+			return &configEditedError{Path: configPath}
+		case conflictMerge:
+			current = mergeHyprlangBlock(current, monitors)
+		}
+	}
+
+	content, err := renderHyprlangConfig(current, monitors, workspaces, syntax)
+	if err != nil {
+		return fmt.Errorf("failed to update config: %w", err)
+	}
+
+	if err := backupConfigFile(configPath, input); err != nil {
+		return fmt.Errorf("failed to create backup: %w", err)
+	}
+
+	if err := replaceConfigFileIfUnchanged(configPath, string(input), []byte(content)); err != nil {
+		return err
+	}
+	if block, ok := hyprlangManagedBlock(content); ok {
+		_ = recordWrittenHash(configPath, contentHash(block)) // best-effort; only used to detect edits
+	}
+	return nil
+}
+
+// replaceConfigFileIfUnchanged is replaceConfigFile for a file that was
+// read as read: if it changed since, the edit is reported as a
+// *configEditedError instead of being lost.
+func replaceConfigFileIfUnchanged(path, read string, data []byte) error {
+	current, _, err := readFileIfExists(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	if contentHash(current) != contentHash(read) {
+		return &configEditedError{Path: path}
+	}
+	return replaceConfigFile(path, data)
+}
+
+// replaceConfigFile atomically replaces a config file: the content goes to
+// a temp file next to the real file (symlinks are resolved, so a dotfiles
+// link keeps pointing at the updated file), which is synced and renamed over
+// it, and the directory is synced so the rename survives a crash. An
+// existing file keeps its permissions.
+func replaceConfigFile(path string, data []byte) error {
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to resolve config path: %w", err)
+		}
+		realPath = path
+	}
+
+	mode := os.FileMode(configFileMode)
+	if info, err := os.Stat(realPath); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	dir, name := filepath.Split(realPath)
+	tmp, err := os.CreateTemp(dir, "."+name+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file for config: %w", err)
+	}
+	tmpPath := tmp.Name()
+	fail := func(format string, err error) error {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf(format, err)
+	}
+
+	if err := tmp.Chmod(mode); err != nil {
+		return fail("failed to set config permissions: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		return fail("failed to write config: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail("failed to sync config: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fail("failed to close config: %w", err)
+	}
+	if err := os.Rename(tmpPath, realPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace config: %w", err)
+	}
+
+	return syncDir(filepath.Dir(realPath))
+}
+
+// syncDir flushes a directory entry change, such as a rename, to disk.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open config directory: %w", err)
+	}
+	defer func() { _ = d.Close() }()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync config directory: %w", err)
+	}
+	return nil
+}
+
+// hyprlangSidecarPath returns the hyprmon.conf file next to hyprland.conf.
+func hyprlangSidecarPath(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), "hyprmon.conf")
+}
+
+func generateHyprlangSidecarConfig(monitors []Monitor, workspaces []WorkspaceRule, syntax string) string {
+	lines := []string{
+		"# Generated by HyprMon. Manual changes may be overwritten.",
+		"",
+	}
+	for _, m := range orderMonitorsForApply(monitors) {
+		lines = append(lines, generateMonitorRule(m, syntax))
+	}
+	lines = append(lines, workspaceRuleLines(workspaces, monitors, generateWorkspaceRule)...)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// writeHyprlangSidecarConfig is the hyprlang counterpart of writeLuaConfig:
+// the rules go to hyprmon.conf and hyprland.conf only gets the source line,
+// so it is left untouched once it has been set up.
+func writeHyprlangSidecarConfig(configPath string, monitors []Monitor, workspaces []WorkspaceRule, syntax string, policy conflictPolicy) error {
+	if configPath == "" {
+		return fmt.Errorf("could not determine config path")
+	}
 
 	input, err := os.ReadFile(configPath)
 	if err != nil {
 		return fmt.Errorf("failed to read config: %w", err)
 	}
 
-	if err := os.WriteFile(backupPath, input, backupFileMode); err != nil {
+	content, err := renderHyprlangSourceConfig(string(input), monitors)
+	if err != nil {
+		return fmt.Errorf("failed to update config: %w", err)
+	}
+
+	sidecarPath := hyprlangSidecarPath(configPath)
+	sidecar, sidecarExists, err := readFileIfExists(sidecarPath)
+	if err != nil {
+		return fmt.Errorf("failed to read hyprmon.conf: %w", err)
+	}
+	newSidecar := generateHyprlangSidecarConfig(monitors, workspaces, syntax)
+
+	// All of hyprmon.conf is written by hyprmon, so any edit to it counts
+	if sidecarExists && managedBlockEdited(sidecarPath, sidecar, true) {
+		switch policy {
+		case conflictAsk:
+			return &configEditedError{Path: sidecarPath}
+		case conflictMerge:
+			if kept := mergeBlockLines(strings.Split(sidecar, "\n"), monitors, hyprlangRuleEnd); len(kept) > 0 {
+				newSidecar += strings.Join(kept, "\n") + "\n"
+			}
+		}
+	}
+
+	if sidecarExists && sidecar != newSidecar {
+		if err := backupConfigFile(sidecarPath, []byte(sidecar)); err != nil {
+			return fmt.Errorf("failed to create backup: %w", err)
+		}
+	}
+
+	if err := replaceConfigFileIfUnchanged(sidecarPath, sidecar, []byte(newSidecar)); err != nil {
+		return fmt.Errorf("failed to write hyprmon.conf: %w", err)
+	}
+	_ = recordWrittenHash(sidecarPath, contentHash(newSidecar)) // best-effort; only used to detect edits
+
+	if content == string(input) {
+		return nil
+	}
+
+	if err := backupConfigFile(configPath, input); err != nil {
 		return fmt.Errorf("failed to create backup: %w", err)
 	}
+	return replaceConfigFileIfUnchanged(configPath, string(input), []byte(content))
+}
 
-	lines := strings.Split(string(input), "\n")
-	var newLines []string
-	inMonitorSection := false
-	monitorLinesWritten := false
+// isHyprmonSourceLine reports whether a config line includes hyprmon.conf.
+func isHyprmonSourceLine(trimmed string) bool {
+	rest, ok := strings.CutPrefix(trimmed, "source")
+	if !ok {
+		return false
+	}
+	rest, ok = strings.CutPrefix(strings.TrimSpace(rest), "=")
+	if !ok {
+		return false
+	}
+	return filepath.Base(strings.TrimSpace(rest)) == "hyprmon.conf"
+}
+
+// isMonitorV2Start reports whether a config line opens a monitorv2 block.
+func isMonitorV2Start(trimmed string) bool {
+	name, ok := strings.CutSuffix(trimmed, "{")
+	return ok && strings.TrimSpace(name) == "monitorv2"
+}
 
+// monitorV2BlockEnd returns the index of the line closing the monitorv2
+// block that starts at lines[start], and the block's output. An unclosed
+// block runs to the end of the file.
+func monitorV2BlockEnd(lines []string, start int) (int, string) {
+	output := ""
+	for i := start + 1; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(stripHyprlangComment(lines[i]))
+		if trimmed == "}" {
+			return i, output
+		}
+		if key, value, ok := strings.Cut(trimmed, "="); ok && strings.TrimSpace(key) == "output" {
+			output = strings.TrimSpace(value)
+		}
+	}
+	return len(lines) - 1, output
+}
+
+// managedMonitorTargets returns the rule targets, connector names and
+// desc: identifiers, that belong to the monitors hyprmon writes.
+func managedMonitorTargets(monitors []Monitor) map[string]bool {
+	managed := make(map[string]bool)
+	for _, m := range monitors {
+		managed[m.Name] = true
+		managed[resolveMonitorIdentifier(m)] = true
+	}
+	return managed
+}
+
+// renderHyprlangSourceConfig returns hyprland.conf as sidecar mode leaves
+// it. Rules for the monitors in hyprmon.conf are commented out so they
+// can't override it, a managed block left by inline mode is replaced by
+// the source line, and the source line is appended if there is none yet.
+func renderHyprlangSourceConfig(input string, monitors []Monitor) (string, error) {
+	managed := managedMonitorTargets(monitors)
+	lines := strings.Split(input, "\n")
+
+	hasSource := false
 	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
+		if isHyprmonSourceLine(strings.TrimSpace(line)) {
+			hasSource = true
+			break
+		}
+	}
 
-		if strings.HasPrefix(trimmed, "monitor=") || strings.HasPrefix(trimmed, "monitor ") {
-			if !monitorLinesWritten {
-				for _, m := range monitors {
-					newLines = append(newLines, generateMonitorLine(m))
+	newLines := make([]string, 0, len(lines)+2)
+	begin, inBlock := -1, false
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == hyprmonBlockBegin:
+			if begin != -1 {
+				return "", fmt.Errorf("config has more than one %q line (line %d)", hyprmonBlockBegin, i+1)
+			}
+			begin, inBlock = i, true
+			if !hasSource {
+				newLines = append(newLines, hyprmonSourceComment, hyprmonSourceLine)
+				hasSource = true
+			}
+		case trimmed == hyprmonBlockEnd:
+			if !inBlock {
+				return "", fmt.Errorf("unexpected %q on line %d", hyprmonBlockEnd, i+1)
+			}
+			inBlock = false
+		case inBlock:
+			// The block's rules now live in hyprmon.conf
+		case isMonitorLine(trimmed) && managed[monitorLineTarget(trimmed)]:
+			newLines = append(newLines, hyprmonDisabledPrefix+line)
+		case isMonitorV2Start(trimmed):
+			end, output := monitorV2BlockEnd(lines, i)
+			for _, blockLine := range lines[i : end+1] {
+				if managed[output] {
+					blockLine = hyprmonDisabledPrefix + blockLine
 				}
-				monitorLinesWritten = true
+				newLines = append(newLines, blockLine)
+			}
+			i = end
+		default:
+			newLines = append(newLines, line)
+		}
+	}
+	if inBlock {
+		return "", fmt.Errorf("%q on line %d has no matching %q", hyprmonBlockBegin, begin+1, hyprmonBlockEnd)
+	}
+
+	content := strings.Join(newLines, "\n")
+	if hasSource {
+		return content, nil
+	}
+	if strings.TrimSpace(content) == "" {
+		return hyprmonSourceComment + "\n" + hyprmonSourceLine + "\n", nil
+	}
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return content + "\n" + hyprmonSourceComment + "\n" + hyprmonSourceLine + "\n", nil
+}
+
+// renderHyprlangConfig returns the config text input with hyprmon's
+// managed block set to monitors and workspace rules, as writeHyprlangConfig
+// would write it.
+// Everything outside the block, including other monitor lines, is kept.
+// Files without a block yet are migrated by migrateHyprlangMonitorLines.
+func renderHyprlangConfig(input string, monitors []Monitor, workspaces []WorkspaceRule, syntax string) (string, error) {
+	block := hyprmonBlockLines(monitors, workspaces, syntax)
+	lines := withoutHyprmonSource(strings.Split(input, "\n"))
+
+	begin, end, err := findHyprlangBlock(lines)
+	if err != nil {
+		return "", err
+	}
+	if begin == -1 {
+		return migrateHyprlangMonitorLines(lines, monitors, block), nil
+	}
+
+	newLines := make([]string, 0, len(lines)-(end-begin+1)+len(block))
+	newLines = append(newLines, lines[:begin]...)
+	newLines = append(newLines, block...)
+	newLines = append(newLines, lines[end+1:]...)
+	return strings.Join(newLines, "\n"), nil
+}
+
+// findHyprlangBlock returns the line indexes of the managed block's
+// markers in hyprland.conf, or -1, -1 if the file has no block.
+func findHyprlangBlock(lines []string) (begin, end int, err error) {
+	begin, end = -1, -1
+	for i, line := range lines {
+		switch strings.TrimSpace(line) {
+		case hyprmonBlockBegin:
+			if begin != -1 {
+				return -1, -1, fmt.Errorf("config has more than one %q line (line %d)", hyprmonBlockBegin, i+1)
 			}
-			inMonitorSection = true
+			begin = i
+		case hyprmonBlockEnd:
+			if begin == -1 || end != -1 {
+				return -1, -1, fmt.Errorf("unexpected %q on line %d", hyprmonBlockEnd, i+1)
+			}
+			end = i
+		}
+	}
+	if begin != -1 && end == -1 {
+		return -1, -1, fmt.Errorf("%q on line %d has no matching %q", hyprmonBlockBegin, begin+1, hyprmonBlockEnd)
+	}
+	return begin, end, nil
+}
+
+// hyprlangManagedBlock returns the managed block of hyprland.conf as text,
+// and false if the file has none.
+func hyprlangManagedBlock(content string) (string, bool) {
+	lines := strings.Split(content, "\n")
+	begin, end, err := findHyprlangBlock(lines)
+	if err != nil || begin == -1 {
+		return "", false
+	}
+	return strings.Join(lines[begin:end+1], "\n"), true
+}
+
+// withoutHyprmonSource drops the hyprmon.conf include left by sidecar
+// mode, so its rules don't override the managed block.
+func withoutHyprmonSource(lines []string) []string {
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == hyprmonSourceComment || isHyprmonSourceLine(trimmed) {
 			continue
 		}
+		kept = append(kept, line)
+	}
+	return kept
+}
+
+// hyprmonBlockLines returns the managed block, markers included, with the
+// monitor rules in the same order they are applied live, followed by the
+// workspace rules.
+func hyprmonBlockLines(monitors []Monitor, workspaces []WorkspaceRule, syntax string) []string {
+	lines := []string{hyprmonBlockBegin, hyprmonBlockComment}
+	for _, m := range orderMonitorsForApply(monitors) {
+		lines = append(lines, generateMonitorRule(m, syntax))
+	}
+	lines = append(lines, workspaceRuleLines(workspaces, monitors, generateWorkspaceRule)...)
+	return append(lines, hyprmonBlockEnd)
+}
+
+// isMonitorLine reports whether a config line is a monitor rule.
+func isMonitorLine(trimmed string) bool {
+	return strings.HasPrefix(trimmed, "monitor=") || strings.HasPrefix(trimmed, "monitor ")
+}
+
+// monitorLineTarget returns the output a monitor rule applies to: the
+// connector name, a "desc:..." identifier, or "" for a catch-all rule.
+func monitorLineTarget(trimmed string) string {
+	rest := strings.TrimSpace(strings.TrimPrefix(trimmed, "monitor"))
+	rest = strings.TrimSpace(strings.TrimPrefix(rest, "="))
+	target, _, _ := strings.Cut(rest, ",")
+	return strings.TrimSpace(target)
+}
 
-		if inMonitorSection && trimmed != "" && !strings.HasPrefix(trimmed, "monitor") {
-			inMonitorSection = false
+// migrateHyprlangMonitorLines is the one-time conversion of a config
+// without a managed block. Earlier versions kept their rules in the first
+// group of monitor lines, so the block takes the place of that group's
+// rules for the monitors being written. Other lines in the group, such as
+// a catch-all "monitor=,preferred,auto,1" or comments, and monitor lines
+// anywhere else in the file stay where they are.
+func migrateHyprlangMonitorLines(lines []string, monitors []Monitor, block []string) string {
+	managed := managedMonitorTargets(monitors)
+
+	first := -1
+	for i, line := range lines {
+		if isMonitorLine(strings.TrimSpace(line)) {
+			first = i
+			break
 		}
+	}
 
-		if !inMonitorSection || trimmed == "" {
-			newLines = append(newLines, line)
+	if first == -1 {
+		input := strings.Join(lines, "\n")
+		if input != "" && !strings.HasSuffix(input, "\n") {
+			input += "\n"
 		}
+		if input != "" {
+			input += "\n"
+		}
+		return input + strings.Join(block, "\n") + "\n"
 	}
 
-	if !monitorLinesWritten {
-		newLines = append(newLines, "")
-		for _, m := range monitors {
-			newLines = append(newLines, generateMonitorLine(m))
+	// The group runs until the first line that is neither a monitor rule,
+	// a comment nor blank
+	groupEnd := first
+	for groupEnd < len(lines) {
+		trimmed := strings.TrimSpace(lines[groupEnd])
+		if trimmed != "" && !isMonitorLine(trimmed) && !strings.HasPrefix(trimmed, "#") {
+			break
 		}
+		groupEnd++
 	}
 
-	// Open the file once to avoid TOCTOU race condition
-	// This also preserves symlinks by writing through them
-	file, err := os.OpenFile(configPath, os.O_WRONLY|os.O_TRUNC, 0)
-	if err != nil {
-		return fmt.Errorf("failed to open config for writing: %w", err)
+	newLines := make([]string, 0, len(lines)+len(block))
+	newLines = append(newLines, lines[:first]...)
+	placed := false
+	for _, line := range lines[first:groupEnd] {
+		trimmed := strings.TrimSpace(line)
+		if isMonitorLine(trimmed) && managed[monitorLineTarget(trimmed)] {
+			if !placed {
+				newLines = append(newLines, block...)
+				placed = true
+			}
+			continue
+		}
+		newLines = append(newLines, line)
+	}
+	if !placed {
+		// Nothing to replace; put the block before the existing rules
+		rest := append([]string{}, newLines[first:]...)
+		newLines = append(append(newLines[:first], block...), rest...)
+	}
+	newLines = append(newLines, lines[groupEnd:]...)
+	return strings.Join(newLines, "\n")
+}
+
+// generateLuaMonitorConfig returns a new hyprmon.lua holding just the
+// managed block.
+func generateLuaMonitorConfig(monitors []Monitor, workspaces []WorkspaceRule) string {
+	return strings.Join(luaBlockLines(monitors, workspaces), "\n") + "\n"
+}
+
+// luaBlockLines returns the managed block of hyprmon.lua, markers
+// included, with the monitor rules in apply order followed by the
+// workspace rules.
+func luaBlockLines(monitors []Monitor, workspaces []WorkspaceRule) []string {
+	lines := []string{hyprmonLuaBlockBegin, hyprmonLuaBlockComment}
+	for _, m := range orderMonitorsForApply(monitors) {
+		lines = append(lines, generateLuaMonitorRule(m))
 	}
-	defer func() {
-		if closeErr := file.Close(); closeErr != nil && err == nil {
-			err = fmt.Errorf("failed to close config: %w", closeErr)
+	lines = append(lines, workspaceRuleLines(workspaces, monitors, generateLuaWorkspaceRule)...)
+	return append(lines, hyprmonLuaBlockEnd)
+}
+
+// findLuaBlock returns the line indexes of the managed block's markers in
+// hyprmon.lua, or -1, -1 if the file has no block.
+func findLuaBlock(lines []string) (begin, end int, err error) {
+	begin, end = -1, -1
+	for i, line := range lines {
+		switch strings.TrimSpace(line) {
+		case hyprmonLuaBlockBegin:
+			if begin != -1 {
+				return -1, -1, fmt.Errorf("hyprmon.lua has more than one %q line (line %d)", hyprmonLuaBlockBegin, i+1)
+			}
+			begin = i
+		case hyprmonLuaBlockEnd:
+			if begin == -1 || end != -1 {
+				return -1, -1, fmt.Errorf("unexpected %q on line %d of hyprmon.lua", hyprmonLuaBlockEnd, i+1)
+			}
+			end = i
 		}
-	}()
+	}
+	if begin != -1 && end == -1 {
+		return -1, -1, fmt.Errorf("%q on line %d of hyprmon.lua has no matching %q", hyprmonLuaBlockBegin, begin+1, hyprmonLuaBlockEnd)
+	}
+	return begin, end, nil
+}
 
-	// Write the new content
-	content := []byte(strings.Join(newLines, "\n"))
-	if _, err = file.Write(content); err != nil {
-		return fmt.Errorf("failed to write config: %w", err)
+// luaManagedBlock returns the managed block of hyprmon.lua as text, and
+// false if the file has none.
+func luaManagedBlock(content string) (string, bool) {
+	lines := strings.Split(content, "\n")
+	begin, end, err := findLuaBlock(lines)
+	if err != nil || begin == -1 {
+		return "", false
 	}
+	return strings.Join(lines[begin:end+1], "\n"), true
+}
 
-	// Ensure data is written to disk
-	if err = file.Sync(); err != nil {
-		return fmt.Errorf("failed to sync config: %w", err)
+// renderLuaMonitorConfig returns hyprmon.lua with its managed block set to
+// monitors. Lua outside the block is kept as is.
+func renderLuaMonitorConfig(input string, monitors []Monitor, workspaces []WorkspaceRule) (string, error) {
+	if strings.TrimSpace(input) == "" {
+		return generateLuaMonitorConfig(monitors, workspaces), nil
 	}
 
-	return nil
+	block := luaBlockLines(monitors, workspaces)
+	lines := strings.Split(input, "\n")
+	begin, end, err := findLuaBlock(lines)
+	if err != nil {
+		return "", err
+	}
+	if begin == -1 {
+		return migrateLuaMonitorConfig(lines, monitors, block), nil
+	}
+
+	newLines := make([]string, 0, len(lines)-(end-begin+1)+len(block))
+	newLines = append(newLines, lines[:begin]...)
+	newLines = append(newLines, block...)
+	newLines = append(newLines, lines[end+1:]...)
+	return strings.Join(newLines, "\n"), nil
 }
 
-func generateLuaMonitorConfig(monitors []Monitor) string {
-	lines := []string{
-		"-- Generated by HyprMon. Manual changes may be overwritten.",
-		"",
+// migrateLuaMonitorConfig converts a hyprmon.lua written before the file
+// had a managed block. The old header and the rules for the monitors being
+// written make way for the block; any other Lua is kept.
+func migrateLuaMonitorConfig(lines []string, monitors []Monitor, block []string) string {
+	managed := managedMonitorTargets(monitors)
+
+	newLines := make([]string, 0, len(lines)+len(block))
+	placed := false
+	for i := 0; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == hyprmonLuaLegacyHeader {
+			// Drop the blank line the old writer put after the header
+			if i+1 < len(lines) && strings.TrimSpace(lines[i+1]) == "" {
+				i++
+			}
+			continue
+		}
+		if output, ok := luaRuleOutput(trimmed); ok && managed[output] {
+			if !placed {
+				newLines = append(newLines, block...)
+				placed = true
+			}
+			continue
+		}
+		newLines = append(newLines, lines[i])
 	}
-	for _, m := range monitors {
-		lines = append(lines, generateLuaMonitorRule(m))
+
+	content := strings.Join(newLines, "\n")
+	if placed {
+		return content
 	}
-	return strings.Join(lines, "\n") + "\n"
+	if strings.TrimSpace(content) == "" {
+		return strings.Join(block, "\n") + "\n"
+	}
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return content + "\n" + strings.Join(block, "\n") + "\n"
+}
+
+// luaRuleOutput returns the output of a single-line hl.monitor({ ... })
+// call as generateLuaMonitorRule writes it.
+func luaRuleOutput(trimmed string) (string, bool) {
+	if !strings.HasPrefix(trimmed, "hl.monitor(") {
+		return "", false
+	}
+	_, rest, ok := strings.Cut(trimmed, "output = ")
+	if !ok {
+		return "", false
+	}
+	quoted, err := strconv.QuotedPrefix(rest)
+	if err != nil {
+		return "", false
+	}
+	output, err := strconv.Unquote(quoted)
+	if err != nil {
+		return "", false
+	}
+	return output, true
 }
 
 func hasHyprmonLuaRequire(input string) bool {
@@ -742,7 +1696,12 @@ func ensureHyprmonLuaRequire(input string) string {
 	return b.String()
 }
 
-func writeLuaConfig(configPath string, monitors []Monitor) error {
+// luaSidecarPath returns the hyprmon.lua file next to the Lua config.
+func luaSidecarPath(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), "hyprmon.lua")
+}
+
+func writeLuaConfig(configPath string, monitors []Monitor, workspaces []WorkspaceRule, policy conflictPolicy) error {
 	if configPath == "" {
 		return fmt.Errorf("could not determine config path")
 	}
@@ -756,133 +1715,220 @@ func writeLuaConfig(configPath string, monitors []Monitor) error {
 	if err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to read lua config: %w", err)
 	}
+	mainExists := err == nil
 
-	if err == nil {
-		backupPath := fmt.Sprintf("%s.bak.%d", configPath, time.Now().Unix())
-		if err := os.WriteFile(backupPath, input, backupFileMode); err != nil {
+	sidecarPath := luaSidecarPath(configPath)
+	sidecar, sidecarExists, err := readFileIfExists(sidecarPath)
+	if err != nil {
+		return fmt.Errorf("failed to read lua monitor config: %w", err)
+	}
+	newSidecar, err := renderLuaMonitorConfig(sidecar, monitors, workspaces)
+	if err != nil {
+		return fmt.Errorf("failed to update lua monitor config: %w", err)
+	}
+
+	// Don't silently overwrite edits made to the block since the last save
+	if block, ok := luaManagedBlock(sidecar); sidecarExists && managedBlockEdited(sidecarPath, block, ok) {
+		switch policy {
+		case conflictAsk:
+			return &configEditedError{Path: sidecarPath}
+		case conflictMerge:
+			if newSidecar, err = renderLuaMonitorConfig(mergeLuaBlock(sidecar, monitors), monitors, workspaces); err != nil {
+				return fmt.Errorf("failed to update lua monitor config: %w", err)
+			}
+		}
+		if err := backupConfigFile(sidecarPath, []byte(sidecar)); err != nil {
 			return fmt.Errorf("failed to create backup: %w", err)
 		}
 	}
 
-	sidecarPath := filepath.Join(configDir, "hyprmon.lua")
-	if err := os.WriteFile(sidecarPath, []byte(generateLuaMonitorConfig(monitors)), configFileMode); err != nil {
+	if mainExists {
+		if err := backupConfigFile(configPath, input); err != nil {
+			return fmt.Errorf("failed to create backup: %w", err)
+		}
+	}
+
+	if err := replaceConfigFileIfUnchanged(sidecarPath, sidecar, []byte(newSidecar)); err != nil {
 		return fmt.Errorf("failed to write lua monitor config: %w", err)
 	}
+	if block, ok := luaManagedBlock(newSidecar); ok {
+		_ = recordWrittenHash(sidecarPath, contentHash(block)) // best-effort; only used to detect edits
+	}
 
 	mainConfig := ensureHyprmonLuaRequire(string(input))
-	if err := os.WriteFile(configPath, []byte(mainConfig), configFileMode); err != nil {
+	if err := replaceConfigFileIfUnchanged(configPath, string(input), []byte(mainConfig)); err != nil {
 		return fmt.Errorf("failed to write lua config include: %w", err)
 	}
 
 	return nil
 }
 
-func reloadConfig() error {
-	ctx, cancel := context.WithTimeout(context.Background(), hyprctlTimeout)
-	defer cancel()
-
-	return exec.CommandContext(ctx, "hyprctl", "reload").Run()
+// managedBlockEdited reports whether the managed block found in a file
+// (ok is false if it has none) differs from what hyprmon last wrote there.
+// Files hyprmon has no record of count as unedited.
+func managedBlockEdited(path, block string, ok bool) bool {
+	recorded, err := loadWrittenHash(path)
+	if err != nil || recorded == "" {
+		return false
+	}
+	return !ok || contentHash(block) != recorded
 }
 
-var previousMonitors []Monitor
-
-func saveRollback(monitors []Monitor) {
-	previousMonitors = make([]Monitor, len(monitors))
-	copy(previousMonitors, monitors)
+// mergeBlockLines splits the lines between a managed block's markers into
+// the ones to keep: rules for outputs that aren't in monitors, as returned
+// by ruleEnd for the rule starting at a line. ruleEnd returns the index of
+// the rule's last line and its output, or ok=false for non-rule lines.
+func mergeBlockLines(body []string, monitors []Monitor, ruleEnd func(lines []string, i int) (end int, output string, ok bool)) []string {
+	managed := managedMonitorTargets(monitors)
+	var kept []string
+	for i := 0; i < len(body); i++ {
+		end, output, ok := ruleEnd(body, i)
+		if !ok {
+			continue
+		}
+		if !managed[output] {
+			kept = append(kept, body[i:end+1]...)
+		}
+		i = end
+	}
+	return kept
 }
 
-func rollback() error {
-	if previousMonitors == nil {
-		return fmt.Errorf("no previous state to rollback to")
+// mergeHyprlangBlock moves hand-written rules for other monitors out of
+// the managed block of hyprland.conf, to just after it, so rendering the
+// block afterwards keeps them.
+func mergeHyprlangBlock(input string, monitors []Monitor) string {
+	lines := strings.Split(input, "\n")
+	begin, end, err := findHyprlangBlock(lines)
+	if err != nil || begin == -1 {
+		return input
 	}
-	return applyMonitors(previousMonitors)
+	kept := mergeBlockLines(lines[begin+1:end], monitors, hyprlangRuleEnd)
+	return spliceAfterBlock(lines, begin, end, kept)
 }
 
-func readWorkspaces() ([]hyprWorkspace, error) {
-	var workspaces []hyprWorkspace
-	if err := execHyprctlJSON(&workspaces, "workspaces", "-j"); err != nil {
-		return nil, err
+// hyprlangRuleEnd is the ruleEnd of mergeBlockLines for hyprlang: monitor
+// lines and monitorv2 blocks.
+func hyprlangRuleEnd(lines []string, i int) (int, string, bool) {
+	trimmed := strings.TrimSpace(lines[i])
+	if isMonitorV2Start(trimmed) {
+		end, output := monitorV2BlockEnd(lines, i)
+		return end, output, true
 	}
-	return workspaces, nil
+	if isMonitorLine(trimmed) {
+		return i, monitorLineTarget(trimmed), true
+	}
+	return i, "", false
 }
 
-func getCurrentMonitorNames() ([]string, error) {
-	var hyprMonitors []hyprMonitor
-	if err := execHyprctlJSON(&hyprMonitors, "monitors", "-j"); err != nil {
-		return nil, err
+// mergeLuaBlock is mergeHyprlangBlock for hyprmon.lua. Only single-line
+// hl.monitor calls are recognised as rules.
+func mergeLuaBlock(input string, monitors []Monitor) string {
+	lines := strings.Split(input, "\n")
+	begin, end, err := findLuaBlock(lines)
+	if err != nil || begin == -1 {
+		return input
 	}
+	kept := mergeBlockLines(lines[begin+1:end], monitors, func(lines []string, i int) (int, string, bool) {
+		output, ok := luaRuleOutput(strings.TrimSpace(lines[i]))
+		return i, output, ok
+	})
+	return spliceAfterBlock(lines, begin, end, kept)
+}
 
-	var names []string
-	for _, m := range hyprMonitors {
-		if !m.Disabled {
-			names = append(names, m.Name)
-		}
-	}
-	return names, nil
+// spliceAfterBlock returns lines with the block between begin and end
+// emptied of everything but its markers and kept placed after it.
+func spliceAfterBlock(lines []string, begin, end int, kept []string) string {
+	newLines := make([]string, 0, len(lines)+len(kept))
+	newLines = append(newLines, lines[:begin+1]...)
+	newLines = append(newLines, lines[end])
+	newLines = append(newLines, kept...)
+	newLines = append(newLines, lines[end+1:]...)
+	return strings.Join(newLines, "\n")
+}
+
+func reloadConfig() error {
+	return hyprCommand("reload")
+}
+
+var previousMonitors []Monitor
+
+// saveRollback remembers the layout that is live before an apply, both in
+// memory and in the state directory so `hyprmon --revert` can restore it
+// from a later process. The in-memory copy is kept even if writing the
+// state file fails.
+func saveRollback(monitors []Monitor) error {
+	previousMonitors = make([]Monitor, len(monitors))
+	copy(previousMonitors, monitors)
+	return saveRollbackState(previousMonitors)
 }
 
-func migrateOrphanedWorkspaces(previousMonitorNames, currentMonitorNames []string) error {
-	// Check if we're switching to a single monitor setup
-	if len(currentMonitorNames) == 1 {
-		// Move all workspaces to the single active monitor
-		workspaces, err := readWorkspaces()
+// rollbackMonitors returns the saved pre-apply layout, mapped by HardwareID
+// onto the currently connected monitors.
+func rollbackMonitors() ([]Monitor, error) {
+	snapshot := previousMonitors
+	if snapshot == nil {
+		state, err := loadRollbackState()
 		if err != nil {
-			return fmt.Errorf("failed to read workspaces: %w", err)
-		}
-
-		targetMonitor := currentMonitorNames[0]
-		for _, workspace := range workspaces {
-			// Move workspace if it's not already on the target monitor
-			if workspace.Monitor != targetMonitor {
-				cmd := fmt.Sprintf("hyprctl dispatch moveworkspacetomonitor %d %s", workspace.ID, targetMonitor)
-				ctx, cancel := context.WithTimeout(context.Background(), hyprctlTimeout)
-				defer cancel()
-				if err := exec.CommandContext(ctx, "sh", "-c", cmd).Run(); err != nil {
-					return fmt.Errorf("failed to migrate workspace %d to monitor %s: %w", workspace.ID, targetMonitor, err)
-				}
-			}
+			return nil, err
 		}
-		return nil
+		if state == nil || len(state.Monitors) == 0 {
+			return nil, fmt.Errorf("no previous state to rollback to")
+		}
+		snapshot = state.Monitors
 	}
 
-	// Original logic for multiple monitors - only migrate from removed monitors
-	removedMonitors := findRemovedMonitors(previousMonitorNames, currentMonitorNames)
-	if len(removedMonitors) == 0 {
-		return nil
+	current, err := readMonitors()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read current monitors: %w", err)
 	}
+	resolved := resolveProfileMonitors(snapshot, current)
+	if len(resolved) == 0 {
+		return nil, fmt.Errorf("none of the monitors from the previous state are connected")
+	}
+	return resolved, nil
+}
 
-	workspaces, err := readWorkspaces()
+// rollback restores the pre-apply layout live and returns it.
+func rollback() ([]Monitor, error) {
+	monitors, err := rollbackMonitors()
 	if err != nil {
-		return fmt.Errorf("failed to read workspaces: %w", err)
+		return nil, err
 	}
-
-	for _, workspace := range workspaces {
-		for _, removedMonitor := range removedMonitors {
-			if workspace.Monitor == removedMonitor {
-				cmd := fmt.Sprintf("hyprctl dispatch moveworkspacetomonitor %d current", workspace.ID)
-				ctx, cancel := context.WithTimeout(context.Background(), hyprctlTimeout)
-				defer cancel()
-				if err := exec.CommandContext(ctx, "sh", "-c", cmd).Run(); err != nil {
-					return fmt.Errorf("failed to migrate workspace %d: %w", workspace.ID, err)
-				}
-			}
-		}
+	if err := applyMonitors(monitors); err != nil {
+		return nil, err
 	}
-
-	return nil
+	return monitors, nil
 }
 
-func findRemovedMonitors(previous, current []string) []string {
-	currentSet := make(map[string]bool)
-	for _, name := range current {
-		currentSet[name] = true
+// revertToSaved restores the pre-apply layout live and writes it back to
+// the Hyprland config, undoing a previous `--profile` or apply.
+func revertToSaved() error {
+	monitors, err := rollbackMonitors()
+	if err != nil {
+		return err
+	}
+	if err := applyMonitors(monitors); err != nil {
+		return fmt.Errorf("failed to apply previous state: %w", err)
+	}
+	// Keep the workspace rules of the profile the restored layout belongs to
+	workspaces := activeProfileWorkspaces(monitors)
+	if err := applyWorkspaceRules(workspaces, monitors); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to apply workspace rules: %v\n", err)
+	}
+	if err := writeConfig(monitors, workspaces); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := reloadConfig(); err != nil {
+		return fmt.Errorf("failed to reload config: %w", err)
 	}
+	return nil
+}
 
-	var removed []string
-	for _, name := range previous {
-		if !currentSet[name] {
-			removed = append(removed, name)
-		}
+func readWorkspaces() ([]hyprWorkspace, error) {
+	var workspaces []hyprWorkspace
+	if err := hyprRequestJSON(&workspaces, "workspaces"); err != nil {
+		return nil, err
 	}
-	return removed
+	return workspaces, nil
 }
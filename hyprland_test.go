@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -280,6 +281,121 @@ func TestGenerateMonitorLineDescFormat(t *testing.T) {
 	})
 }
 
+func TestGenerateMonitorV2Block(t *testing.T) {
+	base := Monitor{
+		Name:       "DP-9",
+		HardwareID: "Dell Inc./DELL U3419W/5HJB6T2",
+		EDIDName:   "Dell Inc. DELL U3419W 5HJB6T2",
+		PxW:        3440,
+		PxH:        1440,
+		Hz:         60,
+		X:          0,
+		Y:          0,
+		Scale:      1.0,
+		Active:     true,
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Monitor)
+		want   string
+	}{
+		{
+			name:   "connector name",
+			modify: func(m *Monitor) {},
+			want: `monitorv2 {
+    output = DP-9
+    mode = 3440x1440@60.00
+    position = 0x0
+    scale = 1.00
+}`,
+		},
+		{
+			name:   "desc on",
+			modify: func(m *Monitor) { m.UseDescFormat = true },
+			want: `monitorv2 {
+    output = desc:Dell Inc. DELL U3419W 5HJB6T2
+    mode = 3440x1440@60.00
+    position = 0x0
+    scale = 1.00
+}`,
+		},
+		{
+			name:   "disabled",
+			modify: func(m *Monitor) { m.Active = false },
+			want: `monitorv2 {
+    output = DP-9
+    disabled = true
+}`,
+		},
+		{
+			name: "mirror skips advanced options",
+			modify: func(m *Monitor) {
+				m.IsMirrored, m.MirrorSource = true, "DP-1"
+				m.BitDepth = 10
+			},
+			want: `monitorv2 {
+    output = DP-9
+    mode = 3440x1440@60.00
+    position = 0x0
+    scale = 1.00
+    mirror = DP-1
+}`,
+		},
+		{
+			name: "HDR with luminance",
+			modify: func(m *Monitor) {
+				m.X, m.Y = -3440, 200
+				m.BitDepth = 10
+				m.ColorMode = "hdr"
+				m.SDRBrightness = 1.2
+				m.SDRMinLuminance = 0.005
+				m.SDRMaxLuminance = 250
+				m.VRR = 2
+				m.Transform = 1
+			},
+			want: `monitorv2 {
+    output = DP-9
+    mode = 3440x1440@60.00
+    position = -3440x200
+    scale = 1.00
+    bitdepth = 10
+    cm = hdr
+    sdrbrightness = 1.20
+    sdr_min_luminance = 0.005
+    sdr_max_luminance = 250
+    vrr = 2
+    transform = 1
+}`,
+		},
+		{
+			name:   "luminance needs an HDR color mode",
+			modify: func(m *Monitor) { m.SDRMaxLuminance = 250 },
+			want: `monitorv2 {
+    output = DP-9
+    mode = 3440x1440@60.00
+    position = 0x0
+    scale = 1.00
+}`,
+		},
+		{
+			name:   "invalid name",
+			modify: func(m *Monitor) { m.Name = "DP-1,evil" },
+			want:   "# Invalid monitor name: DP-1,evil",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := base
+			tt.modify(&m)
+			if got := generateMonitorV2Block(m); got != tt.want {
+				t.Errorf("generateMonitorV2Block() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestGenerateLuaMonitorRule(t *testing.T) {
 	m := Monitor{
 		Name:   "DP-1",
@@ -398,6 +514,7 @@ func TestApplyMonitorPrefs(t *testing.T) {
 }
 
 func TestWriteConfigUsesHyprlangWriterForConf(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
 	dir := t.TempDir()
 	confPath := filepath.Join(dir, "hyprland.conf")
 	input := strings.Join([]string{
@@ -419,7 +536,7 @@ func TestWriteConfigUsesHyprlangWriterForConf(t *testing.T) {
 		Active: true,
 	}}
 
-	if err := writeConfig(monitors); err != nil {
+	if err := writeConfig(monitors, nil); err != nil {
 		t.Fatalf("writeConfig() error = %v", err)
 	}
 
@@ -437,6 +554,7 @@ func TestWriteConfigUsesHyprlangWriterForConf(t *testing.T) {
 }
 
 func TestWriteConfigUsesLuaSidecarForLua(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
 	dir := t.TempDir()
 	luaPath := filepath.Join(dir, "hyprland.lua")
 	if err := os.WriteFile(luaPath, []byte("-- user config\n"), 0600); err != nil {
@@ -455,7 +573,7 @@ func TestWriteConfigUsesLuaSidecarForLua(t *testing.T) {
 		Active: true,
 	}}
 
-	if err := writeConfig(monitors); err != nil {
+	if err := writeConfig(monitors, nil); err != nil {
 		t.Fatalf("writeConfig() error = %v", err)
 	}
 
@@ -484,6 +602,7 @@ func TestWriteConfigUsesLuaSidecarForLua(t *testing.T) {
 }
 
 func TestWriteLuaConfigDoesNotDuplicateRequire(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
 	dir := t.TempDir()
 	luaPath := filepath.Join(dir, "hyprland.lua")
 	input := strings.Join([]string{
@@ -498,10 +617,10 @@ func TestWriteLuaConfigDoesNotDuplicateRequire(t *testing.T) {
 
 	monitors := []Monitor{{Name: "eDP-1", Active: false}}
 
-	if err := writeConfig(monitors); err != nil {
+	if err := writeConfig(monitors, nil); err != nil {
 		t.Fatalf("writeConfig() first call error = %v", err)
 	}
-	if err := writeConfig(monitors); err != nil {
+	if err := writeConfig(monitors, nil); err != nil {
 		t.Fatalf("writeConfig() second call error = %v", err)
 	}
 
@@ -538,3 +657,723 @@ func TestEnsureHyprmonLuaRequireIgnoresCommentedRequire(t *testing.T) {
 		t.Fatalf("expected managed require block:\n%s", got)
 	}
 }
+
+const twoMonitorsJSON = `[
+	{"name":"DP-1","make":"Dell Inc.","model":"U2720Q","serial":"ABC","width":2560,"height":1440,"refreshRate":60,"scale":1,"x":0,"y":0},
+	{"name":"HDMI-A-1","make":"LG","model":"27GL850","serial":"XYZ","width":1920,"height":1080,"refreshRate":60,"scale":1,"x":2560,"y":0}
+]`
+
+func TestApplyMonitorsRestoresOnFailure(t *testing.T) {
+	fake := startFakeHyprland(t, func(request string) string {
+		switch {
+		case request == "j/monitors all":
+			return twoMonitorsJSON
+		case strings.HasPrefix(request, "keyword monitor HDMI-A-1,") && strings.HasSuffix(request, ",2.00"):
+			return "invalid scale"
+		}
+		return "ok"
+	})
+	t.Setenv("HYPRLAND_CONFIG", filepath.Join(t.TempDir(), "hyprland.conf"))
+
+	err := applyMonitors([]Monitor{
+		{Name: "DP-1", PxW: 2560, PxH: 1440, Hz: 60, Scale: 1.5, X: 0, Y: 0, Active: true},
+		{Name: "HDMI-A-1", PxW: 1920, PxH: 1080, Hz: 60, Scale: 2, X: 1707, Y: 0, Active: true},
+	})
+
+	var applyErr *applyError
+	if !errors.As(err, &applyErr) {
+		t.Fatalf("applyMonitors() error = %v, want *applyError", err)
+	}
+	if applyErr.Monitor != "HDMI-A-1" || !strings.Contains(applyErr.Err.Error(), "invalid scale") {
+		t.Errorf("applyError = %+v, want HDMI-A-1 with the rejection reason", applyErr)
+	}
+	if len(applyErr.Restored) != 1 || applyErr.Restored[0] != "DP-1" || applyErr.RestoreErr != nil {
+		t.Errorf("Restored = %v, RestoreErr = %v, want [DP-1], nil", applyErr.Restored, applyErr.RestoreErr)
+	}
+	if !strings.Contains(err.Error(), "HDMI-A-1") || !strings.Contains(err.Error(), "restored DP-1") {
+		t.Errorf("error message = %q, want failing and restored monitors named", err.Error())
+	}
+
+	var keywords []string
+	for _, request := range fake.Requests() {
+		if strings.HasPrefix(request, "keyword monitor ") {
+			keywords = append(keywords, request)
+		}
+	}
+	want := []string{
+		"keyword monitor DP-1,2560x1440@60.00,0x0,1.50",
+		"keyword monitor HDMI-A-1,1920x1080@60.00,1707x0,2.00",
+		"keyword monitor DP-1,2560x1440@60.00,0x0,1.00",
+	}
+	if strings.Join(keywords, "\n") != strings.Join(want, "\n") {
+		t.Errorf("keyword requests =\n%s\nwant\n%s", strings.Join(keywords, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestApplyMonitorsFirstFailureChangesNothing(t *testing.T) {
+	startFakeHyprland(t, func(request string) string {
+		if request == "j/monitors all" {
+			return twoMonitorsJSON
+		}
+		return "invalid mode"
+	})
+	t.Setenv("HYPRLAND_CONFIG", filepath.Join(t.TempDir(), "hyprland.conf"))
+
+	err := applyMonitors([]Monitor{{Name: "DP-1", PxW: 640, PxH: 480, Hz: 60, Scale: 1, Active: true}})
+	var applyErr *applyError
+	if !errors.As(err, &applyErr) || len(applyErr.Restored) != 0 {
+		t.Fatalf("applyMonitors() error = %v, want applyError with nothing restored", err)
+	}
+	if !strings.Contains(err.Error(), "no monitors were changed") {
+		t.Errorf("error message = %q", err.Error())
+	}
+}
+
+func TestApplyMonitorsLuaSendsOneEval(t *testing.T) {
+	fake := startFakeHyprland(t, func(request string) string {
+		if request == "j/monitors all" {
+			return twoMonitorsJSON
+		}
+		return "ok"
+	})
+	t.Setenv("HYPRLAND_CONFIG", filepath.Join(t.TempDir(), "hyprland.lua"))
+
+	err := applyMonitors([]Monitor{
+		{Name: "HDMI-A-1", Active: true, IsMirrored: true, MirrorSource: "DP-1", PxW: 1920, PxH: 1080, Hz: 60, Scale: 1},
+		{Name: "eDP-1", Active: false},
+		{Name: "DP-1", PxW: 2560, PxH: 1440, Hz: 60, Scale: 1, Active: true},
+	})
+	if err != nil {
+		t.Fatalf("applyMonitors() error = %v", err)
+	}
+
+	var evals []string
+	for _, request := range fake.Requests() {
+		if strings.HasPrefix(request, "eval ") {
+			evals = append(evals, request)
+		}
+	}
+	if len(evals) != 1 {
+		t.Fatalf("sent %d evals, want 1: %q", len(evals), evals)
+	}
+	lines := strings.Split(strings.TrimPrefix(evals[0], "eval "), "\n")
+	var outputs []string
+	for _, line := range lines {
+		output, _ := luaRuleOutput(line)
+		outputs = append(outputs, output)
+	}
+	if strings.Join(outputs, ",") != "DP-1,HDMI-A-1,eDP-1" {
+		t.Errorf("eval applies %v, want source, mirror, then disabled output", outputs)
+	}
+}
+
+func TestApplyMonitorsLuaMapsErrorToMonitor(t *testing.T) {
+	tests := []struct {
+		name         string
+		reply        string
+		wantMonitor  string
+		wantRestored string
+	}{
+		{"lua line number", `[string "hl.monitor({ output = "DP-1", mode = "2..."]:2: invalid scale`, "HDMI-A-1", "DP-1"},
+		{"monitor named", "HDMI-A-1: mode 1920x1080@60.00 is not supported", "HDMI-A-1", "DP-1"},
+		{"unknown", "something went wrong", "", "DP-1,HDMI-A-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := startFakeHyprland(t, func(request string) string {
+				switch {
+				case request == "j/monitors all":
+					return twoMonitorsJSON
+				case strings.Contains(request, "scale = 2.00"):
+					return tt.reply
+				}
+				return "ok"
+			})
+			t.Setenv("HYPRLAND_CONFIG", filepath.Join(t.TempDir(), "hyprland.lua"))
+
+			err := applyMonitors([]Monitor{
+				{Name: "DP-1", PxW: 2560, PxH: 1440, Hz: 60, Scale: 1.5, Active: true},
+				{Name: "HDMI-A-1", PxW: 1920, PxH: 1080, Hz: 60, Scale: 2, X: 1707, Active: true},
+			})
+			var applyErr *applyError
+			if !errors.As(err, &applyErr) {
+				t.Fatalf("applyMonitors() error = %v, want *applyError", err)
+			}
+			if applyErr.Monitor != tt.wantMonitor || !strings.Contains(err.Error(), tt.reply) {
+				t.Errorf("applyError = %v, want monitor %q", err, tt.wantMonitor)
+			}
+			if strings.Join(applyErr.Restored, ",") != tt.wantRestored || applyErr.RestoreErr != nil {
+				t.Errorf("Restored = %v, RestoreErr = %v, want %s", applyErr.Restored, applyErr.RestoreErr, tt.wantRestored)
+			}
+
+			requests := fake.Requests()
+			restore := requests[len(requests)-1]
+			if strings.Count(restore, "hl.monitor(") != len(applyErr.Restored) || !strings.Contains(restore, "scale = 1.00") {
+				t.Errorf("restore request = %q, want one eval with the previous settings", restore)
+			}
+		})
+	}
+}
+
+func TestRenderHyprlangConfigMigratesLegacyMonitorLines(t *testing.T) {
+	input := strings.Join([]string{
+		"$mod = SUPER",
+		"",
+		"# Displays",
+		"monitor=DP-1,1920x1080@60,0x0,1",
+		"# keep the laptop panel crisp",
+		"monitor=eDP-1,preferred,auto,1.5",
+		"monitor=,preferred,auto,1",
+		"",
+		"bind = $mod, Q, exec, kitty",
+		"monitor=HDMI-A-2,preferred,auto,1",
+		"",
+	}, "\n")
+
+	got, err := renderHyprlangConfig(input, []Monitor{
+		{Name: "DP-1", PxW: 2560, PxH: 1440, Hz: 60, Scale: 1, Active: true},
+		{Name: "eDP-1", Active: false},
+	}, nil, monitorSyntaxV1)
+	if err != nil {
+		t.Fatalf("renderHyprlangConfig() error = %v", err)
+	}
+
+	want := strings.Join([]string{
+		"$mod = SUPER",
+		"",
+		"# Displays",
+		hyprmonBlockBegin,
+		hyprmonBlockComment,
+		"monitor=DP-1,2560x1440@60.00,0x0,1.00",
+		"monitor=eDP-1,disable",
+		hyprmonBlockEnd,
+		"# keep the laptop panel crisp",
+		"monitor=,preferred,auto,1",
+		"",
+		"bind = $mod, Q, exec, kitty",
+		"monitor=HDMI-A-2,preferred,auto,1",
+		"",
+	}, "\n")
+	if got != want {
+		t.Errorf("renderHyprlangConfig() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRenderHyprlangConfigRewritesOnlyManagedBlock(t *testing.T) {
+	input := strings.Join([]string{
+		"monitor=,preferred,auto,1",
+		hyprmonBlockBegin,
+		"monitor=DP-1,1920x1080@60.00,0x0,1.00",
+		hyprmonBlockEnd,
+		"",
+		"# projector at work",
+		"monitor=desc:Epson PJ,preferred,auto,1",
+		"",
+	}, "\n")
+	monitors := []Monitor{{Name: "DP-1", PxW: 2560, PxH: 1440, Hz: 60, Scale: 1.25, Active: true}}
+
+	got, err := renderHyprlangConfig(input, monitors, nil, monitorSyntaxV1)
+	if err != nil {
+		t.Fatalf("renderHyprlangConfig() error = %v", err)
+	}
+	want := strings.Join([]string{
+		"monitor=,preferred,auto,1",
+		hyprmonBlockBegin,
+		hyprmonBlockComment,
+		"monitor=DP-1,2560x1440@60.00,0x0,1.25",
+		hyprmonBlockEnd,
+		"",
+		"# projector at work",
+		"monitor=desc:Epson PJ,preferred,auto,1",
+		"",
+	}, "\n")
+	if got != want {
+		t.Errorf("renderHyprlangConfig() =\n%s\nwant\n%s", got, want)
+	}
+
+	again, err := renderHyprlangConfig(got, monitors, nil, monitorSyntaxV1)
+	if err != nil || again != got {
+		t.Errorf("second render changed the file:\n%s", again)
+	}
+}
+
+func TestRenderHyprlangConfigAppendsBlockWithoutMonitorLines(t *testing.T) {
+	got, err := renderHyprlangConfig("$mod = SUPER", []Monitor{{Name: "DP-1", PxW: 1920, PxH: 1080, Hz: 60, Scale: 1, Active: true}}, nil, monitorSyntaxV1)
+	if err != nil {
+		t.Fatalf("renderHyprlangConfig() error = %v", err)
+	}
+	want := "$mod = SUPER\n\n" + hyprmonBlockBegin + "\n" + hyprmonBlockComment + "\nmonitor=DP-1,1920x1080@60.00,0x0,1.00\n" + hyprmonBlockEnd + "\n"
+	if got != want {
+		t.Errorf("renderHyprlangConfig() = %q, want %q", got, want)
+	}
+}
+
+func TestWriteHyprlangConfigRejectsUnterminatedBlock(t *testing.T) {
+	confPath := filepath.Join(t.TempDir(), "hyprland.conf")
+	input := hyprmonBlockBegin + "\nmonitor=DP-1,preferred,auto,1\nbind = SUPER, Q, killactive\n"
+	if err := os.WriteFile(confPath, []byte(input), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	err := writeHyprlangConfig(confPath, []Monitor{{Name: "DP-1", PxW: 1920, PxH: 1080, Hz: 60, Scale: 1, Active: true}}, nil, monitorSyntaxV1, conflictAsk)
+	if err == nil || !strings.Contains(err.Error(), "no matching") {
+		t.Fatalf("writeHyprlangConfig() error = %v, want unterminated block error", err)
+	}
+	data, _ := os.ReadFile(confPath)
+	if string(data) != input {
+		t.Error("config was modified despite the error")
+	}
+}
+
+func TestRenderHyprlangSourceConfig(t *testing.T) {
+	monitors := []Monitor{{Name: "DP-1", PxW: 2560, PxH: 1440, Hz: 60, Scale: 1, Active: true}}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name: "Conflicting rules are commented out and the source line appended",
+			input: strings.Join([]string{
+				"monitor=DP-1,1920x1080@60,0x0,1",
+				"monitor=,preferred,auto,1",
+				"bind = SUPER, Q, killactive",
+			}, "\n"),
+			want: strings.Join([]string{
+				hyprmonDisabledPrefix + "monitor=DP-1,1920x1080@60,0x0,1",
+				"monitor=,preferred,auto,1",
+				"bind = SUPER, Q, killactive",
+				"",
+				hyprmonSourceComment,
+				hyprmonSourceLine,
+				"",
+			}, "\n"),
+		},
+		{
+			name: "Managed block is replaced by the source line",
+			input: strings.Join([]string{
+				"$mod = SUPER",
+				hyprmonBlockBegin,
+				hyprmonBlockComment,
+				"monitor=DP-1,1920x1080@60.00,0x0,1.00",
+				hyprmonBlockEnd,
+				"bind = $mod, Q, killactive",
+				"",
+			}, "\n"),
+			want: strings.Join([]string{
+				"$mod = SUPER",
+				hyprmonSourceComment,
+				hyprmonSourceLine,
+				"bind = $mod, Q, killactive",
+				"",
+			}, "\n"),
+		},
+		{
+			name: "Conflicting monitorv2 blocks are commented out",
+			input: strings.Join([]string{
+				"monitorv2 {",
+				"    output = DP-1",
+				"    mode = preferred",
+				"}",
+				"monitorv2 {",
+				"    output = HDMI-A-1",
+				"}",
+				hyprmonSourceLine,
+				"",
+			}, "\n"),
+			want: strings.Join([]string{
+				hyprmonDisabledPrefix + "monitorv2 {",
+				hyprmonDisabledPrefix + "    output = DP-1",
+				hyprmonDisabledPrefix + "    mode = preferred",
+				hyprmonDisabledPrefix + "}",
+				"monitorv2 {",
+				"    output = HDMI-A-1",
+				"}",
+				hyprmonSourceLine,
+				"",
+			}, "\n"),
+		},
+		{
+			name:  "Existing source line is kept",
+			input: "source = ~/.config/hypr/hyprmon.conf\nmonitor=HDMI-A-1,preferred,auto,1\n",
+			want:  "source = ~/.config/hypr/hyprmon.conf\nmonitor=HDMI-A-1,preferred,auto,1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderHyprlangSourceConfig(tt.input, monitors)
+			if err != nil {
+				t.Fatalf("renderHyprlangSourceConfig() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("renderHyprlangSourceConfig() =\n%s\nwant\n%s", got, tt.want)
+			}
+			again, err := renderHyprlangSourceConfig(got, monitors)
+			if err != nil || again != got {
+				t.Errorf("second render changed the file:\n%s", again)
+			}
+		})
+	}
+}
+
+func TestRenderHyprlangConfigDropsSidecarSource(t *testing.T) {
+	input := "$mod = SUPER\n\n" + hyprmonSourceComment + "\n" + hyprmonSourceLine + "\n"
+	got, err := renderHyprlangConfig(input, []Monitor{{Name: "DP-1", PxW: 1920, PxH: 1080, Hz: 60, Scale: 1, Active: true}}, nil, monitorSyntaxV1)
+	if err != nil {
+		t.Fatalf("renderHyprlangConfig() error = %v", err)
+	}
+	if strings.Contains(got, "hyprmon.conf") {
+		t.Errorf("inline mode kept the hyprmon.conf include:\n%s", got)
+	}
+	if !strings.Contains(got, "monitor=DP-1,1920x1080@60.00,0x0,1.00") {
+		t.Errorf("inline mode did not write the managed block:\n%s", got)
+	}
+}
+
+func TestWriteConfigUsesHyprlangSidecarWhenSelected(t *testing.T) {
+	useTempConfigDir(t)
+	if err := saveSettings(&Settings{HyprlangMode: hyprlangModeSidecar}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	confPath := filepath.Join(dir, "hyprland.conf")
+	if err := os.WriteFile(confPath, []byte("$mod = SUPER\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HYPRLAND_CONFIG", confPath)
+
+	monitors := []Monitor{{Name: "DP-1", PxW: 1920, PxH: 1080, Hz: 60, Scale: 1, Active: true}}
+	if err := writeConfig(monitors, nil); err != nil {
+		t.Fatalf("writeConfig() error = %v", err)
+	}
+
+	sidecar, err := os.ReadFile(filepath.Join(dir, "hyprmon.conf"))
+	if err != nil {
+		t.Fatalf("hyprmon.conf was not written: %v", err)
+	}
+	if !strings.Contains(string(sidecar), "monitor=DP-1,1920x1080@60.00,0x0,1.00") {
+		t.Errorf("hyprmon.conf missing monitor rule:\n%s", sidecar)
+	}
+	main, err := os.ReadFile(confPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(main), hyprmonSourceLine) != 1 || strings.Contains(string(main), "monitor=") {
+		t.Errorf("hyprland.conf = %q, want only the source line added", main)
+	}
+
+	// Once the include is set up, saving again leaves hyprland.conf alone
+	backups, _ := filepath.Glob(confPath + ".bak.*")
+	monitors[0].Scale = 2
+	if err := writeConfig(monitors, nil); err != nil {
+		t.Fatalf("second writeConfig() error = %v", err)
+	}
+	again, _ := os.ReadFile(confPath)
+	if string(again) != string(main) {
+		t.Errorf("hyprland.conf changed on second save:\n%s", again)
+	}
+	if after, _ := filepath.Glob(confPath + ".bak.*"); len(after) != len(backups) {
+		t.Errorf("second save made a backup of an unchanged hyprland.conf")
+	}
+	// but hyprmon.conf did change, and is backed up like any other file
+	sidecarBackups, _ := filepath.Glob(filepath.Join(dir, "hyprmon.conf.bak.*"))
+	if len(sidecarBackups) != 1 {
+		t.Fatalf("hyprmon.conf backups = %v, want one from the second save", sidecarBackups)
+	}
+	if data, _ := os.ReadFile(sidecarBackups[0]); string(data) != string(sidecar) {
+		t.Errorf("hyprmon.conf backup = %q, want the first save's content", data)
+	}
+}
+
+func TestWriteHyprlangSidecarHandlesEdits(t *testing.T) {
+	useTempConfigDir(t)
+	if err := saveSettings(&Settings{HyprlangMode: hyprlangModeSidecar}); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	confPath := filepath.Join(dir, "hyprland.conf")
+	writeTestFile(t, confPath, "$mod = SUPER\n")
+	t.Setenv("HYPRLAND_CONFIG", confPath)
+	sidecarPath := filepath.Join(dir, "hyprmon.conf")
+	monitors := []Monitor{{Name: "DP-1", PxW: 1920, PxH: 1080, Hz: 60, Scale: 1, Active: true}}
+	projector := "monitor=HDMI-A-1,1920x1080@60,auto,1"
+
+	if err := writeConfigWithPolicy(monitors, nil, conflictAsk); err != nil {
+		t.Fatalf("first write error = %v", err)
+	}
+
+	data, _ := os.ReadFile(sidecarPath)
+	edited := strings.Replace(string(data), ",1.00", ",2.00", 1) + projector + "\n"
+	writeTestFile(t, sidecarPath, edited)
+
+	err := writeConfigWithPolicy(monitors, nil, conflictAsk)
+	var editedErr *configEditedError
+	if !errors.As(err, &editedErr) || editedErr.Path != sidecarPath {
+		t.Fatalf("write after editing hyprmon.conf error = %v, want configEditedError", err)
+	}
+	if data, _ := os.ReadFile(sidecarPath); string(data) != edited {
+		t.Fatal("hyprmon.conf was written despite the conflict")
+	}
+
+	if err := writeConfigWithPolicy(monitors, nil, conflictMerge); err != nil {
+		t.Fatalf("merge error = %v", err)
+	}
+	data, _ = os.ReadFile(sidecarPath)
+	if got := string(data); strings.Contains(got, ",2.00") || !strings.HasSuffix(got, "\n"+projector+"\n") {
+		t.Errorf("merge result:\n%s", got)
+	}
+	if backups, _ := filepath.Glob(sidecarPath + ".bak.*"); len(backups) != 1 {
+		t.Errorf("merge made %d backups of hyprmon.conf, want 1", len(backups))
+	}
+
+	// The result is what hyprmon wrote, so the next save doesn't ask
+	if err := writeConfigWithPolicy(monitors, nil, conflictAsk); err != nil {
+		t.Errorf("save after resolving the conflict error = %v", err)
+	}
+}
+
+func TestReplaceConfigFileIfUnchanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hyprland.conf")
+	writeTestFile(t, path, "edited while saving\n")
+
+	err := replaceConfigFileIfUnchanged(path, "as read\n", []byte("new\n"))
+	var editedErr *configEditedError
+	if !errors.As(err, &editedErr) || editedErr.Path != path {
+		t.Fatalf("replaceConfigFileIfUnchanged() error = %v, want configEditedError", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "edited while saving\n" {
+		t.Errorf("changed file was replaced: %q", data)
+	}
+
+	if err := replaceConfigFileIfUnchanged(path, "edited while saving\n", []byte("new\n")); err != nil {
+		t.Fatalf("replaceConfigFileIfUnchanged() error = %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new\n" {
+		t.Errorf("content = %q, want the new content", data)
+	}
+}
+
+func TestRenderLuaMonitorConfig(t *testing.T) {
+	monitors := []Monitor{{Name: "DP-1", PxW: 1920, PxH: 1080, Hz: 60, Scale: 1, Active: true}}
+	rule := `hl.monitor({ output = "DP-1", mode = "1920x1080@60.00", position = "0x0", scale = 1.00, disabled = false })`
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "New file",
+			input: "",
+			want:  strings.Join([]string{hyprmonLuaBlockBegin, hyprmonLuaBlockComment, rule, hyprmonLuaBlockEnd, ""}, "\n"),
+		},
+		{
+			name: "Lua outside the block is kept",
+			input: strings.Join([]string{
+				"local projector = os.getenv(\"PROJECTOR\")",
+				hyprmonLuaBlockBegin,
+				`hl.monitor({ output = "DP-1", disabled = true })`,
+				hyprmonLuaBlockEnd,
+				"if projector then hl.monitor({ output = projector, mode = \"preferred\" }) end",
+				"",
+			}, "\n"),
+			want: strings.Join([]string{
+				"local projector = os.getenv(\"PROJECTOR\")",
+				hyprmonLuaBlockBegin,
+				hyprmonLuaBlockComment,
+				rule,
+				hyprmonLuaBlockEnd,
+				"if projector then hl.monitor({ output = projector, mode = \"preferred\" }) end",
+				"",
+			}, "\n"),
+		},
+		{
+			name: "File from before the block is migrated",
+			input: strings.Join([]string{
+				hyprmonLuaLegacyHeader,
+				"",
+				`hl.monitor({ output = "DP-1", disabled = true })`,
+				`hl.monitor({ output = "HDMI-A-1", mode = "preferred" })`,
+				"",
+			}, "\n"),
+			want: strings.Join([]string{
+				hyprmonLuaBlockBegin,
+				hyprmonLuaBlockComment,
+				rule,
+				hyprmonLuaBlockEnd,
+				`hl.monitor({ output = "HDMI-A-1", mode = "preferred" })`,
+				"",
+			}, "\n"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderLuaMonitorConfig(tt.input, monitors, nil)
+			if err != nil {
+				t.Fatalf("renderLuaMonitorConfig() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("renderLuaMonitorConfig() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := renderLuaMonitorConfig(hyprmonLuaBlockBegin+"\n"+rule+"\n", monitors, nil); err == nil {
+		t.Error("renderLuaMonitorConfig() accepted an unterminated block")
+	}
+}
+
+func TestWriteHyprlangConfigHandlesEditedBlock(t *testing.T) {
+	monitors := []Monitor{{Name: "DP-1", PxW: 1920, PxH: 1080, Hz: 60, Scale: 1, Active: true}}
+	projector := "monitor=HDMI-A-1,1920x1080@60,auto,1"
+
+	tests := []struct {
+		policy        conflictPolicy
+		wantProjector bool
+	}{
+		{conflictOverwrite, false},
+		{conflictMerge, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("XDG_STATE_HOME", t.TempDir())
+		confPath := filepath.Join(t.TempDir(), "hyprland.conf")
+		writeTestFile(t, confPath, "$mod = SUPER\n")
+
+		if err := writeHyprlangConfig(confPath, monitors, nil, monitorSyntaxV1, conflictAsk); err != nil {
+			t.Fatalf("first write error = %v", err)
+		}
+
+		// Edit the block by hand: change the rule and add one for a projector
+		data, _ := os.ReadFile(confPath)
+		edited := strings.Replace(string(data), ",1.00", ",2.00", 1)
+		edited = strings.Replace(edited, hyprmonBlockEnd, projector+"\n"+hyprmonBlockEnd, 1)
+		writeTestFile(t, confPath, edited)
+
+		err := writeHyprlangConfig(confPath, monitors, nil, monitorSyntaxV1, conflictAsk)
+		var editedErr *configEditedError
+		if !errors.As(err, &editedErr) || editedErr.Path != confPath {
+			t.Fatalf("write after editing the block error = %v, want configEditedError", err)
+		}
+		if data, _ := os.ReadFile(confPath); string(data) != edited {
+			t.Fatal("hyprland.conf was written despite the conflict")
+		}
+
+		if err := writeHyprlangConfig(confPath, monitors, nil, monitorSyntaxV1, tt.policy); err != nil {
+			t.Fatalf("write with policy %d error = %v", tt.policy, err)
+		}
+		data, _ = os.ReadFile(confPath)
+		got := string(data)
+		if strings.Contains(got, ",2.00") || !strings.Contains(got, generateMonitorLine(monitors[0])) {
+			t.Errorf("policy %d left the edited DP-1 rule:\n%s", tt.policy, got)
+		}
+		if hasProjector := strings.Contains(got, hyprmonBlockEnd+"\n"+projector); hasProjector != tt.wantProjector {
+			t.Errorf("policy %d: projector rule kept after the block = %v, want %v:\n%s", tt.policy, hasProjector, tt.wantProjector, got)
+		}
+
+		// The result is what hyprmon wrote, so the next save doesn't ask
+		if err := writeHyprlangConfig(confPath, monitors, nil, monitorSyntaxV1, conflictAsk); err != nil {
+			t.Errorf("save after resolving the conflict error = %v", err)
+		}
+	}
+}
+
+func TestReplaceConfigFileWritesThroughSymlink(t *testing.T) {
+	dotfiles := t.TempDir()
+	realPath := filepath.Join(dotfiles, "hyprland.conf")
+	if err := os.WriteFile(realPath, []byte("old\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	linkPath := filepath.Join(t.TempDir(), "hyprland.conf")
+	if err := os.Symlink(realPath, linkPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := replaceConfigFile(linkPath, []byte("new\n")); err != nil {
+		t.Fatalf("replaceConfigFile() error = %v", err)
+	}
+
+	if info, err := os.Lstat(linkPath); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("symlink replaced by a regular file: %v, %v", info, err)
+	}
+	data, err := os.ReadFile(realPath)
+	if err != nil || string(data) != "new\n" {
+		t.Errorf("target content = %q, %v", data, err)
+	}
+	if info, _ := os.Stat(realPath); info.Mode().Perm() != 0640 {
+		t.Errorf("target mode = %v, want 0640 kept", info.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(dotfiles); len(entries) != 1 {
+		t.Errorf("temp files left behind: %v", entries)
+	}
+}
+
+func TestWriteLuaConfigAsksBeforeOverwritingEditedBlock(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	luaPath := filepath.Join(dir, "hyprland.lua")
+	if err := os.WriteFile(luaPath, []byte("-- user config\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HYPRLAND_CONFIG", luaPath)
+	monitors := []Monitor{{Name: "DP-1", PxW: 1920, PxH: 1080, Hz: 60, Scale: 1, Active: true}}
+
+	if err := writeConfigWithPolicy(monitors, nil, conflictAsk); err != nil {
+		t.Fatalf("first write error = %v", err)
+	}
+
+	// Edits outside the block are not a conflict
+	sidecarPath := filepath.Join(dir, "hyprmon.lua")
+	data, _ := os.ReadFile(sidecarPath)
+	outside := "-- my projector rules\n" + string(data)
+	if err := os.WriteFile(sidecarPath, []byte(outside), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeConfigWithPolicy(monitors, nil, conflictAsk); err != nil {
+		t.Fatalf("write after editing outside the block error = %v", err)
+	}
+
+	// Edits inside the block are
+	data, _ = os.ReadFile(sidecarPath)
+	edited := strings.Replace(string(data), "scale = 1.00", "scale = 1.50", 1)
+	if err := os.WriteFile(sidecarPath, []byte(edited), 0600); err != nil {
+		t.Fatal(err)
+	}
+	err := writeConfigWithPolicy(monitors, nil, conflictAsk)
+	var editedErr *configEditedError
+	if !errors.As(err, &editedErr) || editedErr.Path != sidecarPath {
+		t.Fatalf("write after editing the block error = %v, want configEditedError", err)
+	}
+	if data, _ := os.ReadFile(sidecarPath); string(data) != edited {
+		t.Error("hyprmon.lua was written despite the conflict")
+	}
+
+	if err := writeConfigWithPolicy(monitors, nil, conflictOverwrite); err != nil {
+		t.Fatalf("overwrite error = %v", err)
+	}
+	data, _ = os.ReadFile(sidecarPath)
+	if !strings.HasPrefix(string(data), "-- my projector rules\n") || strings.Contains(string(data), "scale = 1.50") {
+		t.Errorf("overwrite result:\n%s", data)
+	}
+	if backups, _ := filepath.Glob(sidecarPath + ".bak.*"); len(backups) != 1 {
+		t.Errorf("overwrite made %d backups of hyprmon.lua, want 1", len(backups))
+	}
+
+	// Merging keeps hand-added rules for other monitors, outside the block
+	projector := `hl.monitor({ output = "HDMI-A-1", disabled = true })`
+	data, _ = os.ReadFile(sidecarPath)
+	edited = strings.Replace(string(data), hyprmonLuaBlockEnd, projector+"\n"+hyprmonLuaBlockEnd, 1)
+	if err := os.WriteFile(sidecarPath, []byte(edited), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeConfigWithPolicy(monitors, nil, conflictMerge); err != nil {
+		t.Fatalf("merge error = %v", err)
+	}
+	data, _ = os.ReadFile(sidecarPath)
+	if !strings.Contains(string(data), hyprmonLuaBlockEnd+"\n"+projector) {
+		t.Errorf("merge result:\n%s", data)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// hyprRequestSocket is the request/response socket (what hyprctl uses)
+	hyprRequestSocket = ".socket.sock"
+
+	// hyprEventSocket is the event stream socket
+	hyprEventSocket = ".socket2.sock"
+
+	// hyprBatchPrefix marks a request carrying several ;-separated commands
+	hyprBatchPrefix = "[[BATCH]]"
+)
+
+// hyprSocketPath returns the path of one of the current Hyprland instance's
+// IPC sockets. Hyprland >= 0.40 keeps them under $XDG_RUNTIME_DIR/hypr; older
+// releases used /tmp/hypr, which is tried as a fallback.
+func hyprSocketPath(name string) (string, error) {
+	signature := os.Getenv("HYPRLAND_INSTANCE_SIGNATURE")
+	if signature == "" {
+		return "", fmt.Errorf("HYPRLAND_INSTANCE_SIGNATURE is not set (is Hyprland running?)")
+	}
+	if strings.ContainsAny(signature, "/\x00") || signature == "." || signature == ".." {
+		return "", fmt.Errorf("invalid HYPRLAND_INSTANCE_SIGNATURE: %q", signature)
+	}
+
+	var candidates []string
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		candidates = append(candidates, filepath.Join(runtimeDir, "hypr", signature, name))
+	}
+	candidates = append(candidates, filepath.Join("/tmp", "hypr", signature, name))
+
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("hyprland socket %s not found for instance %s", name, signature)
+}
+
+// hyprRequest sends a single request to the Hyprland request socket and
+// returns the raw response. Hyprland closes the connection after replying,
+// so the response is everything read until EOF.
+func hyprRequest(request string) ([]byte, error) {
+	path, err := hyprSocketPath(hyprRequestSocket)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("unix", path, hyprctlTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to hyprland socket: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if err := conn.SetDeadline(time.Now().Add(hyprctlTimeout)); err != nil {
+		return nil, fmt.Errorf("failed to set hyprland socket deadline: %w", err)
+	}
+
+	if _, err := conn.Write([]byte(request)); err != nil {
+		return nil, fmt.Errorf("failed to send hyprland request %q: %w", request, err)
+	}
+
+	response, err := io.ReadAll(conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hyprland response to %q: %w", request, err)
+	}
+	return response, nil
+}
+
+// hyprRequestJSON sends a j/ (JSON output) request and unmarshals the response into result
+func hyprRequestJSON(result interface{}, request string) error {
+	output, err := hyprRequest("j/" + request)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(output, result); err != nil {
+		return fmt.Errorf("failed to parse JSON from hyprland %q: %w", request, err)
+	}
+	return nil
+}
+
+// checkHyprResponse converts the textual reply to a write command into an
+// error. Hyprland answers "ok" on success and a human-readable message on
+// failure, without any status code.
+func checkHyprResponse(request string, response []byte) error {
+	reply := strings.TrimSpace(string(response))
+	if reply == "" || reply == "ok" {
+		return nil
+	}
+	return fmt.Errorf("hyprland rejected %q: %s", request, reply)
+}
+
+// hyprCommand sends a write command (keyword, dispatch, reload, ...) and
+// fails unless Hyprland acknowledged it.
+func hyprCommand(request string) error {
+	response, err := hyprRequest(request)
+	if err != nil {
+		return err
+	}
+	return checkHyprResponse(request, response)
+}
+
+// hyprBatch sends several write commands in one [[BATCH]] request so that
+// Hyprland processes them back to back. Every command must be acknowledged;
+// the first rejected one is reported.
+func hyprBatch(requests []string) error {
+	if len(requests) == 0 {
+		return nil
+	}
+	for _, request := range requests {
+		if strings.Contains(request, ";") {
+			return fmt.Errorf("cannot batch hyprland request containing ';': %q", request)
+		}
+	}
+
+	response, err := hyprRequest(hyprBatchPrefix + strings.Join(requests, ";"))
+	if err != nil {
+		return err
+	}
+
+	// Replies to batched commands are separated by blank lines, in order.
+	replies := strings.Split(strings.TrimSpace(string(response)), "\n\n")
+	for i, request := range requests {
+		if i >= len(replies) {
+			break
+		}
+		if err := checkHyprResponse(request, []byte(replies[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeHyprland serves the Hyprland request socket from a temp runtime dir
+// and records every request it receives.
+type fakeHyprland struct {
+	runtimeDir string
+	mu         sync.Mutex
+	requests   []string
+}
+
+// useTempConfigDir points customConfigPath, and with it the profiles,
+// settings and state directories, at a fresh temp dir for the test.
+func useTempConfigDir(t *testing.T) string {
+	t.Helper()
+	orig := customConfigPath
+	customConfigPath = t.TempDir()
+	t.Cleanup(func() { customConfigPath = orig })
+	return customConfigPath
+}
+
+// startFakeHyprland points HYPRLAND_INSTANCE_SIGNATURE and XDG_RUNTIME_DIR
+// at a fresh runtime dir and answers requests on .socket.sock with respond.
+func startFakeHyprland(t *testing.T, respond func(request string) string) *fakeHyprland {
+	t.Helper()
+
+	// Unix socket paths are limited to ~108 bytes, so avoid t.TempDir()
+	// (which embeds the full test name) in favour of a short prefix.
+	runtimeDir, err := os.MkdirTemp("", "hypr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(runtimeDir) })
+
+	instanceDir := filepath.Join(runtimeDir, "hypr", "test")
+	if err := os.MkdirAll(instanceDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	t.Setenv("HYPRLAND_INSTANCE_SIGNATURE", "test")
+
+	listener, err := net.Listen("unix", filepath.Join(instanceDir, hyprRequestSocket))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	f := &fakeHyprland{runtimeDir: runtimeDir}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			buf := make([]byte, 64*1024)
+			n, _ := conn.Read(buf)
+			request := string(buf[:n])
+			f.mu.Lock()
+			f.requests = append(f.requests, request)
+			f.mu.Unlock()
+			_, _ = io.WriteString(conn, respond(request))
+			_ = conn.Close()
+		}
+	}()
+	return f
+}
+
+func (f *fakeHyprland) Requests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.requests...)
+}
+
+func TestHyprSocketPathRequiresSignature(t *testing.T) {
+	t.Setenv("HYPRLAND_INSTANCE_SIGNATURE", "")
+
+	if _, err := hyprSocketPath(hyprRequestSocket); err == nil {
+		t.Fatal("hyprSocketPath() error = nil, want error without HYPRLAND_INSTANCE_SIGNATURE")
+	}
+}
+
+func TestHyprSocketPathRejectsTraversal(t *testing.T) {
+	t.Setenv("HYPRLAND_INSTANCE_SIGNATURE", "../../etc")
+
+	if _, err := hyprSocketPath(hyprRequestSocket); err == nil {
+		t.Fatal("hyprSocketPath() error = nil, want error for path traversal")
+	}
+}
+
+func TestHyprRequestJSONUsesJFlag(t *testing.T) {
+	fake := startFakeHyprland(t, func(request string) string {
+		return `[{"id":0,"name":"DP-1","width":2560,"height":1440,"refreshRate":143.99,"scale":1.25,"x":0,"y":0,"make":"Dell Inc.","model":"U2720Q","serial":"ABC"}]`
+	})
+	useTempConfigDir(t)
+
+	monitors, err := readMonitors()
+	if err != nil {
+		t.Fatalf("readMonitors() error = %v", err)
+	}
+	if len(monitors) != 1 || monitors[0].Name != "DP-1" {
+		t.Fatalf("readMonitors() = %+v, want single DP-1", monitors)
+	}
+	if monitors[0].HardwareID != "Dell Inc./U2720Q/ABC" {
+		t.Errorf("HardwareID = %q, want Dell Inc./U2720Q/ABC", monitors[0].HardwareID)
+	}
+
+	requests := fake.Requests()
+	if len(requests) != 1 || requests[0] != "j/monitors all" {
+		t.Fatalf("requests = %q, want [\"j/monitors all\"]", requests)
+	}
+}
+
+func TestApplyMonitorHyprlangSendsKeyword(t *testing.T) {
+	fake := startFakeHyprland(t, func(string) string { return "ok" })
+
+	m := Monitor{Name: "DP-1", PxW: 1920, PxH: 1080, Hz: 60, X: 1920, Y: 0, Scale: 1, Active: true, VRR: 1}
+	if err := applyMonitorHyprlang(m); err != nil {
+		t.Fatalf("applyMonitorHyprlang() error = %v", err)
+	}
+
+	want := "keyword monitor DP-1,1920x1080@60.00,1920x0,1.00,vrr,1"
+	requests := fake.Requests()
+	if len(requests) != 1 || requests[0] != want {
+		t.Fatalf("requests = %q, want [%q]", requests, want)
+	}
+}
+
+func TestApplyMonitorHyprlangReportsRejection(t *testing.T) {
+	startFakeHyprland(t, func(string) string { return "invalid monitor rule" })
+
+	err := applyMonitorHyprlang(Monitor{Name: "DP-1", Active: false})
+	if err == nil || !strings.Contains(err.Error(), "invalid monitor rule") {
+		t.Fatalf("applyMonitorHyprlang() error = %v, want hyprland rejection", err)
+	}
+}
+
+func TestApplyMonitorHyprlangRejectsUnsafeName(t *testing.T) {
+	fake := startFakeHyprland(t, func(string) string { return "ok" })
+
+	if err := applyMonitorHyprlang(Monitor{Name: "DP-1;reload", Active: false}); err == nil {
+		t.Fatal("applyMonitorHyprlang() error = nil, want invalid name error")
+	}
+	if len(fake.Requests()) != 0 {
+		t.Fatalf("unsafe monitor name reached the socket: %q", fake.Requests())
+	}
+}
+
+func TestHyprBatchSendsSingleRequest(t *testing.T) {
+	fake := startFakeHyprland(t, func(string) string { return "ok\n\nok" })
+
+	err := hyprBatch([]string{
+		"dispatch moveworkspacetomonitor 1 DP-1",
+		"dispatch moveworkspacetomonitor 2 DP-1",
+	})
+	if err != nil {
+		t.Fatalf("hyprBatch() error = %v", err)
+	}
+
+	want := "[[BATCH]]dispatch moveworkspacetomonitor 1 DP-1;dispatch moveworkspacetomonitor 2 DP-1"
+	requests := fake.Requests()
+	if len(requests) != 1 || requests[0] != want {
+		t.Fatalf("requests = %q, want [%q]", requests, want)
+	}
+}
+
+func TestHyprBatchReportsFailedCommand(t *testing.T) {
+	startFakeHyprland(t, func(string) string { return "ok\n\nworkspace not found" })
+
+	err := hyprBatch([]string{
+		"dispatch moveworkspacetomonitor 1 DP-1",
+		"dispatch moveworkspacetomonitor 9 DP-1",
+	})
+	if err == nil || !strings.Contains(err.Error(), "moveworkspacetomonitor 9") {
+		t.Fatalf("hyprBatch() error = %v, want failure for second command", err)
+	}
+}
+
+func TestMigrateOrphanedWorkspacesBatchesMoves(t *testing.T) {
+	fake := startFakeHyprland(t, func(request string) string {
+		if request == "j/workspaces" {
+			return `[{"id":1,"monitor":"eDP-1"},{"id":2,"monitor":"DP-1"},{"id":3,"monitor":"DP-1"}]`
+		}
+		return "ok\n\nok"
+	})
+	useTempConfigDir(t)
+
+	previous := []Monitor{{Name: "eDP-1", Active: true}, {Name: "DP-1", Active: true}}
+	current := []Monitor{{Name: "eDP-1", Active: true}}
+	if err := migrateOrphanedWorkspaces(previous, current, nil); err != nil {
+		t.Fatalf("migrateOrphanedWorkspaces() error = %v", err)
+	}
+
+	requests := fake.Requests()
+	want := "[[BATCH]]dispatch moveworkspacetomonitor 2 eDP-1;dispatch moveworkspacetomonitor 3 eDP-1"
+	if len(requests) != 2 || requests[1] != want {
+		t.Fatalf("requests = %q, want workspaces query then %q", requests, want)
+	}
+}
+
+func TestHyprRequestWithoutSocket(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	t.Setenv("HYPRLAND_INSTANCE_SIGNATURE", "missing-instance")
+
+	_, err := hyprRequest("monitors")
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("hyprRequest() error = %v, want socket not found", err)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// lidPollInterval is how often the daemon reads the lid switch. procfs
+// files can't be watched with inotify, and a second is quick enough for a
+// lid.
+const lidPollInterval = time.Second
+
+// internalConnectorPrefixes are the connector types of built-in panels.
+var internalConnectorPrefixes = []string{"eDP", "LVDS", "DSI"}
+
+// isInternalConnector reports whether a connector name such as "eDP-1"
+// belongs to a laptop's built-in panel.
+func isInternalConnector(name string) bool {
+	for _, prefix := range internalConnectorPrefixes {
+		if strings.HasPrefix(name, prefix+"-") || name == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// followLid brings the internal panels in line with the lid: closing it
+// turns enabled panels off and moves their workspaces away, opening it
+// turns the panels hyprmon turned off back on with the mode, position and
+// scale they had. Panels are left on when nothing else would show the
+// session, and panels that were off for other reasons stay off. Reports
+// whether anything changed.
+func followLid(lid string) (bool, error) {
+	current, err := readMonitors()
+	if err != nil {
+		return false, fmt.Errorf("failed to read current monitors: %w", err)
+	}
+	if lid == lidClosed {
+		return closeLid(current)
+	}
+	return openLid(current)
+}
+
+// lidClosedLayout returns a copy of layout with its internal panels turned
+// off, for applying a profile while the lid is closed, so they are never
+// turned on in the first place. panels are the ones it turned off, as
+// layout has them, for rememberLidPanels. Like closeLid, it leaves them on
+// when no other monitor would show the session.
+func lidClosedLayout(layout []Monitor) (closed, panels []Monitor) {
+	others := false
+	for _, m := range layout {
+		if m.Active && !isInternalConnector(m.Name) {
+			others = true
+		}
+	}
+	if !others {
+		return layout, nil
+	}
+	closed = make([]Monitor, len(layout))
+	for i, m := range layout {
+		if isInternalConnector(m.Name) {
+			if m.Active {
+				panels = append(panels, m)
+			}
+			m.Active = false
+		}
+		closed[i] = m
+	}
+	return closed, panels
+}
+
+// closeLid turns off the enabled internal panels among current.
+func closeLid(current []Monitor) (bool, error) {
+	var panels []Monitor
+	others := false
+	for _, m := range current {
+		switch {
+		case !m.Active:
+		case isInternalConnector(m.Name):
+			panels = append(panels, m)
+		default:
+			others = true
+		}
+	}
+	if len(panels) == 0 || !others {
+		return false, nil
+	}
+
+	if err := rememberLidPanels(panels); err != nil {
+		return false, err
+	}
+
+	workspaces, _ := readWorkspaces()
+	off := make([]Monitor, len(panels))
+	for i, p := range panels {
+		p.Active = false
+		off[i] = p
+	}
+	if err := applyMonitors(off); err != nil {
+		return false, fmt.Errorf("failed to disable internal panel: %w", err)
+	}
+	return true, migrateAfter(current, workspaces)
+}
+
+// rememberLidPanels adds panels about to be turned off for a closed lid to
+// the ones openLid turns back on. A panel already saved is replaced: it
+// was turned back on since, maybe in another mode.
+func rememberLidPanels(panels []Monitor) error {
+	saved, err := loadLidPanels()
+	if err != nil {
+		saved = nil
+	}
+	for _, p := range panels {
+		kept := saved[:0]
+		for _, s := range saved {
+			if monitorKey(s) != monitorKey(p) {
+				kept = append(kept, s)
+			}
+		}
+		saved = append(kept, p)
+	}
+	return saveLidPanels(saved)
+}
+
+// openLid turns the panels closeLid turned off back on.
+func openLid(current []Monitor) (bool, error) {
+	saved, err := loadLidPanels()
+	if err != nil || len(saved) == 0 {
+		return false, err
+	}
+	byKey := make(map[string]Monitor, len(saved))
+	for _, p := range saved {
+		byKey[monitorKey(p)] = p
+	}
+
+	var on []Monitor
+	for _, m := range current {
+		if m.Active || !isInternalConnector(m.Name) {
+			continue
+		}
+		if p, ok := byKey[monitorKey(m)]; ok {
+			p.Name = m.Name
+			p.Active = true
+			on = append(on, p)
+		}
+	}
+
+	// Panels already back on, or gone, need nothing more
+	if len(on) == 0 {
+		return false, saveLidPanels(nil)
+	}
+	workspaces, _ := readWorkspaces()
+	if err := applyMonitors(on); err != nil {
+		return false, fmt.Errorf("failed to enable internal panel: %w", err)
+	}
+	if err := saveLidPanels(nil); err != nil {
+		return true, err
+	}
+	return true, migrateAfter(current, workspaces)
+}
+
+// migrateAfter moves workspaces for the layout change from previous, with
+// workspaces where they were then, to what Hyprland reports now.
+func migrateAfter(previous []Monitor, workspaces []hyprWorkspace) error {
+	after, err := readMonitors()
+	if err != nil {
+		return fmt.Errorf("failed to read current monitors: %w", err)
+	}
+	return migrateOrphanedWorkspaces(previous, after, workspaces)
+}
+
+// watchLid sends on wake whenever the lid under root opens or closes.
+// Returns when ctx is done.
+func watchLid(ctx context.Context, root string, interval time.Duration, wake chan<- struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := readLidState(root)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if now := readLidState(root); now != last {
+			last = now
+			select {
+			case wake <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIsInternalConnector(t *testing.T) {
+	for name, want := range map[string]bool{
+		"eDP-1": true, "eDP-2": true, "LVDS-1": true, "DSI-1": true,
+		"DP-1": false, "HDMI-A-1": false, "DP-eDP": false, "": false,
+	} {
+		if got := isInternalConnector(name); got != want {
+			t.Errorf("isInternalConnector(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestFollowLid(t *testing.T) {
+	shortVerifySettle(t)
+	var mu sync.Mutex
+	panelOff := false
+	workspace2 := "eDP-1"
+	fake := startFakeHyprland(t, func(request string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case request == "j/monitors all":
+			panel := `{"name":"eDP-1","make":"BOE","model":"0x0BCA","width":2880,"height":1800,"refreshRate":120,"scale":2,"x":2560,"y":0}`
+			if panelOff {
+				panel = `{"name":"eDP-1","make":"BOE","model":"0x0BCA","width":1920,"height":1080,"refreshRate":60,"scale":1,"x":0,"y":0,"disabled":true}`
+			}
+			return `[{"name":"DP-1","make":"Dell Inc.","model":"U2720Q","serial":"ABC","width":2560,"height":1440,"refreshRate":60,"scale":1,"x":0,"y":0},` + panel + `]`
+		case request == "j/workspaces":
+			return `[{"id":1,"name":"1","monitor":"DP-1"},{"id":2,"name":"2","monitor":"` + workspace2 + `"}]`
+		case request == "keyword monitor eDP-1,disable":
+			// Hyprland moves the workspaces of a disabled monitor itself
+			panelOff, workspace2 = true, "DP-1"
+		case request == hyprBatchPrefix+"dispatch moveworkspacetomonitor 2 eDP-1":
+			workspace2 = "eDP-1"
+		case strings.HasPrefix(request, "keyword monitor eDP-1,"):
+			panelOff = false
+		}
+		return "ok"
+	})
+	useTempConfigDir(t)
+	t.Setenv("HYPRLAND_CONFIG", filepath.Join(t.TempDir(), "hyprland.conf"))
+
+	changed, err := followLid(lidClosed)
+	if err != nil || !changed {
+		t.Fatalf("followLid(closed) = %v, %v", changed, err)
+	}
+	// Closing it again changes nothing
+	if changed, err := followLid(lidClosed); err != nil || changed {
+		t.Fatalf("second followLid(closed) = %v, %v", changed, err)
+	}
+	changed, err = followLid(lidOpen)
+	if err != nil || !changed {
+		t.Fatalf("followLid(open) = %v, %v", changed, err)
+	}
+
+	var got []string
+	for _, request := range fake.Requests() {
+		if strings.HasPrefix(request, "keyword monitor") || strings.HasPrefix(request, hyprBatchPrefix) {
+			got = append(got, request)
+		}
+	}
+	want := []string{
+		"keyword monitor eDP-1,disable",
+		"keyword monitor eDP-1,2880x1800@120.00,2560x0,2.00",
+		hyprBatchPrefix + "dispatch moveworkspacetomonitor 2 eDP-1",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if panels, _ := loadLidPanels(); panels != nil {
+		t.Errorf("lid state after opening = %+v, want none", panels)
+	}
+}
+
+func TestFollowLidAfterProfileAppliedWithLidClosed(t *testing.T) {
+	shortVerifySettle(t)
+	var mu sync.Mutex
+	panelOff := false
+	fake := startFakeHyprland(t, func(request string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case request == "j/monitors all" || request == "j/monitors":
+			panel := `{"name":"eDP-1","make":"BOE","model":"0x0BCA","width":2880,"height":1800,"refreshRate":120,"scale":2,"x":2560,"y":0}`
+			if panelOff {
+				panel = `{"name":"eDP-1","make":"BOE","model":"0x0BCA","width":1920,"height":1080,"refreshRate":60,"scale":1,"x":0,"y":0,"disabled":true}`
+			}
+			return `[{"name":"DP-1","make":"Dell Inc.","model":"U2720Q","serial":"ABC","width":2560,"height":1440,"refreshRate":60,"scale":1,"x":0,"y":0},` + panel + `]`
+		case request == "j/workspaces":
+			return `[]`
+		case request == "keyword monitor eDP-1,disable":
+			panelOff = true
+		case strings.HasPrefix(request, "keyword monitor eDP-1,"):
+			panelOff = false
+		}
+		return "ok"
+	})
+	useTempConfigDir(t)
+	configPath := filepath.Join(t.TempDir(), "hyprland.conf")
+	writeTestFile(t, configPath, "$mod = SUPER\n")
+	t.Setenv("HYPRLAND_CONFIG", configPath)
+
+	if err := saveProfile("docked", []Monitor{
+		{Name: "DP-1", HardwareID: "Dell Inc./U2720Q/ABC", PxW: 2560, PxH: 1440, Hz: 60, Scale: 1, Active: true},
+		{Name: "eDP-1", HardwareID: "BOE/0x0BCA", PxW: 2880, PxH: 1800, Hz: 120, Scale: 1.5, X: 0, Y: 1440, Active: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if changed, err := followLid(lidClosed); err != nil || !changed {
+		t.Fatalf("followLid(closed) = %v, %v", changed, err)
+	}
+	if _, err := applyProfileForLid("docked", lidClosed, conflictAsk, nil, io.Discard); err != nil {
+		t.Fatalf("applyProfileForLid(closed) error = %v", err)
+	}
+	if changed, err := followLid(lidOpen); err != nil || !changed {
+		t.Fatalf("followLid(open) = %v, %v", changed, err)
+	}
+
+	// Opening the lid turns the panel on where the profile puts it, not
+	// where it was before the profile was applied
+	var enabled []string
+	for _, request := range fake.Requests() {
+		if strings.HasPrefix(request, "keyword monitor eDP-1,") && request != "keyword monitor eDP-1,disable" {
+			enabled = append(enabled, request)
+		}
+	}
+	if want := "keyword monitor eDP-1,2880x1800@120.00,0x1440,1.50"; len(enabled) != 1 || enabled[0] != want {
+		t.Errorf("panel turned on with %q, want only %q", enabled, want)
+	}
+	if panels, _ := loadLidPanels(); panels != nil {
+		t.Errorf("lid state after opening = %+v, want none", panels)
+	}
+}
+
+func TestFollowLidKeepsTheOnlyScreen(t *testing.T) {
+	fake := startFakeHyprland(t, func(request string) string {
+		if request == "j/monitors all" {
+			return `[{"name":"eDP-1","width":1920,"height":1080,"refreshRate":60,"scale":1}]`
+		}
+		return "ok"
+	})
+	useTempConfigDir(t)
+
+	if changed, err := followLid(lidClosed); err != nil || changed {
+		t.Fatalf("followLid(closed) = %v, %v, want nothing done", changed, err)
+	}
+	if requests := fake.Requests(); len(requests) != 1 {
+		t.Errorf("requests = %q, want only the monitor query", requests)
+	}
+}
+
+func TestWatchLid(t *testing.T) {
+	root := t.TempDir()
+	state := filepath.Join(root, "acpi", "button", "lid", "LID0", "state")
+	if err := os.MkdirAll(filepath.Dir(state), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, state, "state:      open\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	wake := make(chan struct{})
+	go watchLid(ctx, root, 5*time.Millisecond, wake)
+
+	time.Sleep(20 * time.Millisecond)
+	writeTestFile(t, state, "state:      closed\n")
+	select {
+	case <-wake:
+	case <-time.After(2 * time.Second):
+		t.Fatal("watchLid() did not report the lid closing")
+	}
+}
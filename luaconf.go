@@ -0,0 +1,337 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// luaToken is a token of Lua source. Comments and whitespace are dropped.
+type luaToken struct {
+	kind byte // 'i' identifier, 's' string, 'n' number, 'p' punctuation
+	text string
+	line int
+}
+
+// luaLongBracket returns the length of a long bracket opener such as [[
+// or [==[ at s[i:], and its level, or 0 if there is none.
+func luaLongBracket(s string, i int) (length, level int) {
+	if i >= len(s) || s[i] != '[' {
+		return 0, 0
+	}
+	j := i + 1
+	for j < len(s) && s[j] == '=' {
+		j++
+	}
+	if j < len(s) && s[j] == '[' {
+		return j - i + 1, j - i - 1
+	}
+	return 0, 0
+}
+
+// tokenizeLua splits Lua source into tokens. It only needs to be good
+// enough to find table constructors with literal values; anything it
+// doesn't understand becomes punctuation.
+func tokenizeLua(src string) []luaToken {
+	var tokens []luaToken
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+
+		case strings.HasPrefix(src[i:], "--"):
+			// Block comment --[[ ... ]] or line comment
+			if n, level := luaLongBracket(src, i+2); n > 0 {
+				closer := "]" + strings.Repeat("=", level) + "]"
+				end := strings.Index(src[i+2+n:], closer)
+				if end == -1 {
+					end = len(src) - (i + 2 + n)
+				} else {
+					end += len(closer)
+				}
+				body := src[i : i+2+n+end]
+				line += strings.Count(body, "\n")
+				i += len(body)
+				continue
+			}
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+
+		case c == '"' || c == '\'':
+			start := line
+			var b strings.Builder
+			j := i + 1
+			for j < len(src) && src[j] != c && src[j] != '\n' {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+					switch src[j] {
+					case 'n':
+						b.WriteByte('\n')
+					case 't':
+						b.WriteByte('\t')
+					default:
+						b.WriteByte(src[j])
+					}
+				} else {
+					b.WriteByte(src[j])
+				}
+				j++
+			}
+			tokens = append(tokens, luaToken{'s', b.String(), start})
+			i = j + 1
+
+		case c == '[':
+			if n, level := luaLongBracket(src, i); n > 0 {
+				closer := "]" + strings.Repeat("=", level) + "]"
+				body := src[i+n:]
+				end := strings.Index(body, closer)
+				if end == -1 {
+					end = len(body)
+				}
+				tokens = append(tokens, luaToken{'s', strings.TrimPrefix(body[:end], "\n"), line})
+				line += strings.Count(body[:end], "\n")
+				i += n + end + len(closer)
+				continue
+			}
+			tokens = append(tokens, luaToken{'p', "[", line})
+			i++
+
+		case c == '_' || isASCIILetter(c):
+			j := i
+			for j < len(src) && (src[j] == '_' || isASCIILetter(src[j]) || isASCIIDigit(src[j])) {
+				j++
+			}
+			tokens = append(tokens, luaToken{'i', src[i:j], line})
+			i = j
+
+		case isASCIIDigit(c) || (c == '.' && i+1 < len(src) && isASCIIDigit(src[i+1])):
+			j := i
+			for j < len(src) {
+				d := src[j]
+				if isASCIIDigit(d) || isASCIILetter(d) || d == '.' ||
+					((d == '+' || d == '-') && (src[j-1] == 'e' || src[j-1] == 'E')) {
+					j++
+					continue
+				}
+				break
+			}
+			tokens = append(tokens, luaToken{'n', src[i:j], line})
+			i = j
+
+		default:
+			tokens = append(tokens, luaToken{'p', string(c), line})
+			i++
+		}
+	}
+	return tokens
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isASCIIDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// luaParser reads the monitor rules from a Lua config and the modules it
+// requires from the config directory.
+type luaParser struct {
+	root   string
+	loaded map[string]bool
+	rules  []configMonitorRule
+}
+
+// parseLuaConfig returns the hl.monitor({ ... }) rules in a Lua config, in
+// the order they run. require("name") calls that resolve to a file in the
+// config directory, such as require("hyprmon"), are followed where they
+// appear. The reader is tolerant: calls whose output isn't a literal are
+// skipped, fields with computed values are ignored, and literal values
+// hyprmon can't read are left out and listed in the rule's Dropped.
+func parseLuaConfig(path string) ([]configMonitorRule, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	p := &luaParser{
+		root:   filepath.Dir(abs),
+		loaded: make(map[string]bool),
+	}
+	if err := p.parseFile(abs); err != nil {
+		return nil, err
+	}
+	return p.rules, nil
+}
+
+func (p *luaParser) parseFile(path string) error {
+	// Like Lua's module cache, each file runs once
+	if p.loaded[path] {
+		return nil
+	}
+	p.loaded[path] = true
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read lua config: %w", err)
+	}
+
+	tokens := tokenizeLua(string(data))
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.kind != 'i' {
+			continue
+		}
+
+		// hl.monitor({ ... }) or hl.monitor{ ... }
+		if t.text == "hl" && i+3 < len(tokens) && tokens[i+1].text == "." && tokens[i+2].text == "monitor" {
+			j := i + 3
+			if tokens[j].text == "(" {
+				j++
+			}
+			if j >= len(tokens) || tokens[j].text != "{" {
+				continue
+			}
+			fields, end := parseLuaTable(tokens, j)
+			i = end
+			rule, ok := luaMonitorRule(fields)
+			if !ok {
+				continue
+			}
+			rule.File = path
+			rule.Line = t.line
+			p.rules = append(p.rules, rule)
+			continue
+		}
+
+		// require("name"), require "name" or require 'name'
+		if t.text == "require" && i+1 < len(tokens) {
+			j := i + 1
+			if tokens[j].text == "(" && j+1 < len(tokens) {
+				j++
+			}
+			if tokens[j].kind != 's' {
+				continue
+			}
+			if module := p.resolveModule(tokens[j].text); module != "" {
+				if err := p.parseFile(module); err != nil {
+					return err
+				}
+			}
+			i = j
+		}
+	}
+	return nil
+}
+
+// resolveModule maps a module name to a file in the config directory, or
+// "" if it isn't one of the user's files.
+func (p *luaParser) resolveModule(name string) string {
+	base := filepath.Join(p.root, filepath.FromSlash(strings.ReplaceAll(name, ".", "/")))
+	for _, candidate := range []string{base + ".lua", filepath.Join(base, "init.lua")} {
+		if fileExists(candidate) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// parseLuaTable reads the name = value fields of the table constructor
+// starting at tokens[start], which is "{". Only literal strings, numbers
+// and booleans are kept; their values are returned as text. It returns the
+// index of the closing "}".
+func parseLuaTable(tokens []luaToken, start int) ([][2]string, int) {
+	var fields [][2]string
+	i := start + 1
+	for i < len(tokens) && tokens[i].text != "}" {
+		// Field key: name = or ["name"] =
+		key := ""
+		switch {
+		case tokens[i].kind == 'i' && i+1 < len(tokens) && tokens[i+1].text == "=":
+			key = tokens[i].text
+			i += 2
+		case tokens[i].text == "[" && i+3 < len(tokens) && tokens[i+1].kind == 's' &&
+			tokens[i+2].text == "]" && tokens[i+3].text == "=":
+			key = tokens[i+1].text
+			i += 4
+		}
+
+		// Value: everything up to the next separator at this depth
+		valueStart := i
+		depth := 0
+		for i < len(tokens) {
+			text := tokens[i].text
+			if depth == 0 && (text == "," || text == ";" || text == "}") {
+				break
+			}
+			switch text {
+			case "(", "{", "[":
+				depth++
+			case ")", "}", "]":
+				depth--
+			}
+			i++
+		}
+		if key != "" {
+			if value, ok := luaLiteral(tokens[valueStart:i]); ok {
+				fields = append(fields, [2]string{key, value})
+			}
+		}
+
+		if i < len(tokens) && tokens[i].text != "}" {
+			i++ // separator
+		}
+	}
+	return fields, i
+}
+
+// luaLiteral returns the text of a literal value expression.
+func luaLiteral(value []luaToken) (string, bool) {
+	switch {
+	case len(value) == 1 && value[0].kind == 's':
+		return value[0].text, true
+	case len(value) == 1 && value[0].kind == 'n':
+		return value[0].text, true
+	case len(value) == 1 && (value[0].text == "true" || value[0].text == "false"):
+		return value[0].text, true
+	case len(value) == 2 && value[0].text == "-" && value[1].kind == 'n':
+		return "-" + value[1].text, true
+	}
+	return "", false
+}
+
+// luaMonitorRule converts the fields of an hl.monitor table into a rule.
+// ok is false for tables without a literal output, which hyprmon can't
+// attribute to a monitor. Fields with values hyprmon can't read are left
+// out and listed in the rule's Dropped.
+func luaMonitorRule(fields [][2]string) (rule configMonitorRule, ok bool) {
+	output := ""
+	hasOutput := false
+	for _, field := range fields {
+		if field[0] == "output" {
+			output, hasOutput = field[1], true
+		}
+	}
+	if !hasOutput {
+		return rule, false
+	}
+
+	rule, err := monitorRuleFromFields(fields)
+	if err != nil {
+		rule = configMonitorRule{Target: output, Err: err}
+	}
+
+	var text []string
+	for _, field := range fields {
+		text = append(text, fmt.Sprintf("%s = %s", field[0], field[1]))
+	}
+	rule.Text = "hl.monitor({ " + strings.Join(text, ", ") + " })"
+	return rule, true
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseLuaConfigRoundTrip(t *testing.T) {
+	want := []Monitor{
+		{Name: "DP-1", PxW: 3840, PxH: 2160, Hz: 143.99, X: 0, Y: 0, Scale: 1.5, Active: true,
+			BitDepth: 10, ColorMode: "hdr", SDRBrightness: 1.2, SDRSaturation: 0.9, VRR: 1},
+		{Name: "DP-2", HardwareID: "Dell/U2720Q/ABC", EDIDName: "Dell U2720Q", UseDescFormat: true, PxW: 2560, PxH: 1440, Hz: 60, X: 2560, Y: 0, Scale: 1, Active: true, Transform: 1},
+		{Name: "HDMI-A-1", PxW: 1920, PxH: 1080, Hz: 60, X: 0, Y: 1440, Scale: 1, Active: true, IsMirrored: true, MirrorSource: "DP-1"},
+		{Name: "eDP-1"},
+	}
+
+	dir := t.TempDir()
+	main := filepath.Join(dir, "hyprland.lua")
+	writeTestFile(t, main, "hl.config({ general = { gaps_in = 5 } })\n"+hyprmonLuaRequireLine+"\n")
+	writeTestFile(t, filepath.Join(dir, "hyprmon.lua"), generateLuaMonitorConfig(want, nil))
+
+	rules, err := parseLuaConfig(main)
+	if err != nil {
+		t.Fatalf("parseLuaConfig() error = %v", err)
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("parseLuaConfig() = %d rules, want %d: %+v", len(rules), len(want), rules)
+	}
+
+	got := make(map[string]Monitor)
+	for _, rule := range rules {
+		if filepath.Base(rule.File) != "hyprmon.lua" || rule.Line == 0 {
+			t.Errorf("rule location = %s:%d", rule.File, rule.Line)
+		}
+		got[rule.Target] = rule.Monitor
+	}
+	for _, m := range want {
+		target := resolveMonitorIdentifier(m)
+		rule, ok := got[target]
+		if !ok {
+			t.Errorf("no rule for %s", target)
+			continue
+		}
+		if m.UseDescFormat {
+			// The rule only names the description; the live monitor fills in the rest
+			rule.Name = m.Name
+		}
+		if !monitorsEqualForTest(rule, m) || rule.SDRBrightness != m.SDRBrightness || rule.SDRSaturation != m.SDRSaturation {
+			t.Errorf("rule for %s = %+v, want %+v", target, rule, m)
+		}
+	}
+}
+
+func TestParseLuaConfigIsTolerant(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "hyprland.lua")
+	writeTestFile(t, main, strings.Join([]string{
+		"local scale = os.getenv('SCALE') or 1",
+		"--[[ hl.monitor({ output = 'commented-out', mode = 'preferred' }) ]]",
+		"-- hl.monitor({ output = 'also-commented' })",
+		"hl.monitor({ output = laptop_output(), mode = 'preferred' })",
+		"hl.monitor {",
+		"    output = \"DP-1\"; -- the desk",
+		"    mode = [[2560x1440@144]],",
+		"    position = \"-2560x0\",",
+		"    scale = scale,",
+		"    [\"vrr\"] = 2,",
+		"    extra = { nested = true },",
+		"}",
+		"require('conf.extra')",
+		"require(\"conf.extra\")",
+		"require('not.a.local.module')",
+	}, "\n"))
+	writeTestFile(t, filepath.Join(dir, "conf", "extra.lua"), "hl.monitor({ output = \"eDP-1\", disabled = true })\n")
+
+	rules, err := parseLuaConfig(main)
+	if err != nil {
+		t.Fatalf("parseLuaConfig() error = %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("parseLuaConfig() = %d rules, want 2: %+v", len(rules), rules)
+	}
+
+	desk := rules[0]
+	if desk.Target != "DP-1" || desk.Line != 5 || desk.Monitor.PxW != 2560 || desk.Monitor.Hz != 144 ||
+		desk.Monitor.X != -2560 || desk.Monitor.Scale != 1 || desk.Monitor.VRR != 2 {
+		t.Errorf("desk rule = line %d %+v", desk.Line, desk.Monitor)
+	}
+
+	laptop := rules[1]
+	if laptop.Target != "eDP-1" || laptop.Monitor.Active || filepath.Base(laptop.File) != "extra.lua" {
+		t.Errorf("required rule = %s %+v", laptop.File, laptop.Monitor)
+	}
+
+	// Values hyprmon can't read are dropped; the rest of the rule and the
+	// file are kept
+	bad := filepath.Join(dir, "bad.lua")
+	writeTestFile(t, bad, strings.Join([]string{
+		"",
+		"hl.monitor({ output = 'DP-1', mode = 'huge', position = '1920x0', cm = 'rec2100', vrr = 3 })",
+		"hl.monitor({ output = 'HDMI-A-1', mode = '1920x1080@60' })",
+	}, "\n"))
+	rules, err = parseLuaConfig(bad)
+	if err != nil {
+		t.Fatalf("parseLuaConfig() error = %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("parseLuaConfig() = %d rules, want 2: %+v", len(rules), rules)
+	}
+	dropped := rules[0]
+	if dropped.Err != nil || dropped.Line != 2 || dropped.Mode != "preferred" || dropped.Monitor.X != 1920 || dropped.Monitor.VRR != 3 {
+		t.Errorf("rule with bad values = line %d %+v", dropped.Line, dropped)
+	}
+	if want := []string{"mode = huge", "cm = rec2100"}; !slices.Equal(dropped.Dropped, want) {
+		t.Errorf("Dropped = %q, want %q", dropped.Dropped, want)
+	}
+	if rules[1].Target != "HDMI-A-1" || rules[1].Monitor.PxW != 1920 {
+		t.Errorf("rule after the bad one = %+v", rules[1])
+	}
+}
@@ -1,11 +1,19 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"golang.org/x/term"
@@ -24,8 +32,22 @@ func main() {
 	var showVersion bool
 	var configPath string
 	var jsonOutput bool
+	var autoProfile bool
+	var confirmTimeout time.Duration
+	var revert bool
+	var strict bool
+	var dryRun bool
+	var importConfig string
+	var showConfigRules bool
 
 	flag.StringVar(&profileName, "profile", "", "Apply a specific profile")
+	flag.BoolVar(&autoProfile, "auto", false, "Apply the saved profile that best matches the connected monitors")
+	flag.DurationVar(&confirmTimeout, "confirm-timeout", 0, "With --profile or --auto, ask to keep the new layout and revert if not confirmed within this time (e.g. 15s)")
+	flag.BoolVar(&dryRun, "dry-run", false, "With --profile or in the TUI's apply/save, print the Hyprland requests and config diff instead of changing anything")
+	flag.BoolVar(&strict, "strict", false, "With --profile or --auto, exit with code 3 if Hyprland did not apply every setting as requested")
+	flag.BoolVar(&revert, "revert", false, "Restore the monitor layout that was active before the last apply")
+	flag.StringVar(&importConfig, "import-config", "", "Save the monitor rules in your hyprland.conf (and the files it sources) as a profile with this name")
+	flag.BoolVar(&showConfigRules, "config-rules", false, "List the monitor rules in your hyprland.conf and the files it sources, with their locations")
 	flag.BoolVar(&showProfileMenu, "profiles", false, "Show profile selection menu")
 	flag.BoolVar(&listProfilesNames, "list-profiles", false, "List available profile names")
 	flag.BoolVar(&showActiveProfile, "active-profile", false, "Show currently active profile name")
@@ -63,39 +85,12 @@ func main() {
 
 	// Handle list-profiles flag
 	if listProfilesNames {
-		profiles, err := listProfiles()
+		profiles, err := listOrderedProfiles()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error listing profiles: %v\n", err)
 			os.Exit(1)
 		}
 
-		// Load saved order if exists
-		savedOrder, _ := loadProfileOrder()
-		if len(savedOrder) > 0 {
-			// Apply saved order
-			orderedProfiles := []string{}
-			remainingProfiles := make(map[string]bool)
-
-			for _, p := range profiles {
-				remainingProfiles[p] = true
-			}
-
-			for _, name := range savedOrder {
-				if remainingProfiles[name] {
-					orderedProfiles = append(orderedProfiles, name)
-					delete(remainingProfiles, name)
-				}
-			}
-
-			for _, p := range profiles {
-				if remainingProfiles[p] {
-					orderedProfiles = append(orderedProfiles, p)
-				}
-			}
-
-			profiles = orderedProfiles
-		}
-
 		// Get the currently active profile
 		activeProfile, _ := getCurrentActiveProfile()
 
@@ -131,16 +126,158 @@ func main() {
 		return
 	}
 
+	if showConfigRules {
+		rules, err := readConfigMonitorRules()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading config: %v\n", err)
+			os.Exit(1)
+		}
+		for _, rule := range rules {
+			if rule.Err != nil {
+				fmt.Printf("%s:%d: %s (ignored: %v)\n", rule.File, rule.Line, rule.Text, rule.Err)
+				continue
+			}
+			if len(rule.Dropped) > 0 {
+				fmt.Printf("%s:%d: %s (ignored: %s)\n", rule.File, rule.Line, rule.Text, strings.Join(rule.Dropped, ", "))
+				continue
+			}
+			fmt.Printf("%s:%d: %s\n", rule.File, rule.Line, rule.Text)
+		}
+		return
+	}
+
+	if importConfig != "" {
+		monitors, skipped, err := importConfigProfile(importConfig)
+		for _, rule := range skipped {
+			if rule.Err != nil {
+				fmt.Fprintf(os.Stderr, "Skipped %s:%d: %v\n", rule.File, rule.Line, rule.Err)
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "Skipped %s:%d: no connected monitor matches %s\n", rule.File, rule.Line, rule.Target)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error importing config: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Imported %d monitors as profile '%s'\n", len(monitors), importConfig)
+		return
+	}
+
+	if flag.Arg(0) == "backups" {
+		if err := runBackups(flag.Args()[1:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "daemon" {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := runDaemon(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Error running daemon: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if len(os.Args) > 1 && os.Args[1] == "profiles" {
 		showProfileMenu = true
 	}
 
+	// --dry-run must never apply or write anything, so it is handled
+	// before the flags that do
+	if dryRun && (revert || autoProfile) {
+		flagName, plan := "--auto", planAutoProfile
+		if revert {
+			flagName, plan = "--revert", planRevert
+		}
+		p, err := plan()
+		if errors.Is(err, errNoMatchingProfile) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitNoMatchingProfile)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: cannot plan %s --dry-run: %v\n", flagName, err)
+			os.Exit(1)
+		}
+		fmt.Print(p)
+		return
+	}
+
+	if revert {
+		if err := revertToSaved(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reverting: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Reverted to previous configuration")
+		return
+	}
+
+	// Ask before keeping a layout applied from the command line. Without a
+	// terminal (e.g. from a keybinding) nobody could answer, and the layout
+	// would always be reverted.
+	var confirm func() bool
+	if confirmTimeout > 0 && !term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintln(os.Stderr, "Error: --confirm-timeout needs a terminal to ask on; run it from a terminal or leave it out")
+		os.Exit(1)
+	}
+	if confirmTimeout > 0 {
+		confirm = func() bool {
+			return confirmKeepLayout(os.Stdin, os.Stderr, confirmTimeout)
+		}
+	}
+
+	if autoProfile {
+		name, drifts, err := applyAutoProfile(conflictAsk, confirm, os.Stdout)
+		var edited *configEditedError
+		if errors.As(err, &edited) {
+			// --auto runs from scripts and keybindings, so it never asks
+			policy, _ := resolveConfigConflict(edited, false)
+			name, drifts, err = applyAutoProfile(policy, confirm, os.Stdout)
+		}
+		if errors.Is(err, errNoMatchingProfile) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitNoMatchingProfile)
+		}
+		exitOnHookError(name, err)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error applying profile: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Profile '%s' applied successfully\n", name)
+		reportDrift(drifts, strict)
+		return
+	}
+
+	if profileName != "" && dryRun {
+		plan, err := planProfile(profileName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error planning profile: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(plan)
+		return
+	}
+
 	if profileName != "" {
-		if err := applyProfile(profileName); err != nil {
+		drifts, err := applyProfileWithConfirm(profileName, conflictAsk, confirm, os.Stdout)
+		var edited *configEditedError
+		if errors.As(err, &edited) {
+			policy, ok := resolveConfigConflict(edited, true)
+			if !ok {
+				fmt.Fprintf(os.Stderr, "Apply aborted; %s was not changed\n", edited.Path)
+				os.Exit(1)
+			}
+			drifts, err = applyProfileWithConfirm(profileName, policy, confirm, os.Stdout)
+		}
+		exitOnHookError(profileName, err)
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error applying profile: %v\n", err)
 			os.Exit(1)
 		}
 		fmt.Printf("Profile '%s' applied successfully\n", profileName)
+		reportDrift(drifts, strict)
 		return
 	}
 
@@ -162,6 +299,9 @@ func main() {
 		if profileModel, ok := finalModel.(profileMenuModel); ok && profileModel.launchFullUI {
 			// Continue to launch full UI below
 		} else {
+			if ok && profileModel.applied != "" {
+				fmt.Println(profileModel.applied)
+			}
 			return
 		}
 	}
@@ -169,6 +309,7 @@ func main() {
 	// Main UI loop - may need to restart if switching between views
 	for {
 		m := initialModel()
+		m.DryRun = dryRun
 		p := tea.NewProgram(m, tea.WithMouseCellMotion(), tea.WithAltScreen())
 
 		finalModel, err := p.Run()
@@ -194,11 +335,20 @@ func main() {
 			}
 
 			// Check if we should return to main UI
-			if pm, ok := finalProfileModel.(profileMenuModel); ok && pm.launchFullUI {
+			pm, ok := finalProfileModel.(profileMenuModel)
+			if ok && pm.launchFullUI {
 				continue // Go back to main UI
 			}
+			if ok && pm.applied != "" {
+				fmt.Println(pm.applied)
+			}
 			break // Exit completely
 		}
+
+		// Print what apply/save would have done in dry-run mode
+		if mainModel, ok := finalModel.(model); ok && mainModel.DryRunOutput != "" {
+			fmt.Print(mainModel.DryRunOutput)
+		}
 		break // Normal exit
 	}
 }
@@ -227,3 +377,59 @@ func initialModel() model {
 	m.updateWorld()
 	return m
 }
+
+// resolveConfigConflict decides what a command-line apply does about hand
+// edits inside the hyprmon block. With ask set and a terminal to ask on,
+// the user picks; otherwise nobody can answer, so the edits are merged
+// with a warning. ok is false when the user aborts.
+func resolveConfigConflict(edited *configEditedError, ask bool) (policy conflictPolicy, ok bool) {
+	if ask && term.IsTerminal(int(os.Stdin.Fd())) {
+		return askConflictPolicy(os.Stdin, os.Stderr, edited)
+	}
+	fmt.Fprintf(os.Stderr, "warning: %v; keeping its rules for other monitors (the old file is backed up)\n", edited)
+	return conflictMerge, true
+}
+
+// askConflictPolicy asks on out whether to overwrite or merge the hand
+// edits edited reports and reads the answer from in. Anything other than
+// o or m aborts.
+func askConflictPolicy(in io.Reader, out io.Writer, edited *configEditedError) (policy conflictPolicy, ok bool) {
+	_, _ = fmt.Fprintf(out, "%v\n[o]verwrite, [m]erge (keep rules for other monitors) or abort? ", edited)
+	line, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "o", "overwrite":
+		return conflictOverwrite, true
+	case "m", "merge":
+		return conflictMerge, true
+	}
+	return conflictAsk, false
+}
+
+// exitOnHookError reports a failed hook and exits with exitHookFailed. A
+// post_apply hook fails after the profile was applied, so that is said too.
+func exitOnHookError(name string, err error) {
+	var hookErr *hookError
+	if !errors.As(err, &hookErr) {
+		return
+	}
+	if hookErr.Hook == hookPostApply {
+		fmt.Printf("Profile '%s' applied successfully\n", name)
+	}
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(exitHookFailed)
+}
+
+// reportDrift lists the settings Hyprland changed or ignored after an apply
+// and, in strict mode, exits with exitApplyDrift.
+func reportDrift(drifts []monitorDrift, strict bool) {
+	if len(drifts) == 0 {
+		return
+	}
+	fmt.Fprintln(os.Stderr, "Warning: Hyprland did not apply every setting as requested:")
+	for _, d := range drifts {
+		fmt.Fprintf(os.Stderr, "  %s\n", d)
+	}
+	if strict {
+		os.Exit(exitApplyDrift)
+	}
+}
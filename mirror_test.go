@@ -1,6 +1,7 @@
 package main
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -116,3 +117,95 @@ func TestValidateMirrorConfiguration(t *testing.T) {
 		})
 	}
 }
+
+func TestOrderMonitorsForApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		monitors []Monitor
+		want     []string
+	}{
+		{
+			name: "Disable before enable is reordered",
+			monitors: []Monitor{
+				{Name: "eDP-1", Active: false},
+				{Name: "HDMI-A-1", Active: true},
+			},
+			want: []string{"HDMI-A-1", "eDP-1"},
+		},
+		{
+			name: "Mirror listed before its source",
+			monitors: []Monitor{
+				{Name: "eDP-1", Active: true, IsMirrored: true, MirrorSource: "HDMI-A-1"},
+				{Name: "HDMI-A-1", Active: true},
+			},
+			want: []string{"HDMI-A-1", "eDP-1"},
+		},
+		{
+			name: "Mirror chain follows its sources",
+			monitors: []Monitor{
+				{Name: "HDMI-A-1", Active: true},
+				{Name: "eDP-1", Active: true, IsMirrored: true, MirrorSource: "DP-1"},
+				{Name: "DP-1", Active: true, IsMirrored: true, MirrorSource: "DP-2"},
+				{Name: "DP-2", Active: true},
+			},
+			want: []string{"HDMI-A-1", "DP-2", "DP-1", "eDP-1"},
+		},
+		{
+			name: "Mirror of a monitor outside the apply",
+			monitors: []Monitor{
+				{Name: "eDP-1", Active: true, IsMirrored: true, MirrorSource: "HDMI-A-1"},
+				{Name: "DP-1", Active: false},
+				{Name: "DP-2", Active: true},
+			},
+			want: []string{"DP-2", "eDP-1", "DP-1"},
+		},
+		{
+			name: "Disabled mirror goes with the disables",
+			monitors: []Monitor{
+				{Name: "eDP-1", Active: false, IsMirrored: true, MirrorSource: "HDMI-A-1"},
+				{Name: "HDMI-A-1", Active: true},
+			},
+			want: []string{"HDMI-A-1", "eDP-1"},
+		},
+		{
+			name: "Circular mirrors keep their order",
+			monitors: []Monitor{
+				{Name: "HDMI-A-1", Active: true, IsMirrored: true, MirrorSource: "eDP-1"},
+				{Name: "eDP-1", Active: true, IsMirrored: true, MirrorSource: "HDMI-A-1"},
+				{Name: "DP-1", Active: true},
+			},
+			want: []string{"DP-1", "HDMI-A-1", "eDP-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ordered := orderMonitorsForApply(tt.monitors)
+			var got []string
+			for _, m := range ordered {
+				got = append(got, m.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("orderMonitorsForApply() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateLuaMonitorConfigUsesApplyOrder(t *testing.T) {
+	config := generateLuaMonitorConfig([]Monitor{
+		{Name: "eDP-1", Active: false},
+		{Name: "DP-1", Active: true, IsMirrored: true, MirrorSource: "HDMI-A-1", PxW: 1920, PxH: 1080, Hz: 60, Scale: 1},
+		{Name: "HDMI-A-1", Active: true, PxW: 1920, PxH: 1080, Hz: 60, Scale: 1},
+	}, nil)
+
+	hdmi := strings.Index(config, `output = "HDMI-A-1"`)
+	dp := strings.Index(config, `output = "DP-1"`)
+	edp := strings.Index(config, `output = "eDP-1"`)
+	if hdmi < 0 || dp < 0 || edp < 0 {
+		t.Fatalf("missing monitor rules in:\n%s", config)
+	}
+	if !(hdmi < dp && dp < edp) {
+		t.Errorf("rules not in apply order (source, mirror, disabled):\n%s", config)
+	}
+}
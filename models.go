@@ -1,6 +1,8 @@
 package main
 
 import (
+	"time"
+
 	tea "github.com/charmbracelet/bubbletea"
 )
 
@@ -18,6 +20,7 @@ type Monitor struct {
 	Model         string `json:"model,omitempty"`
 	Serial        string `json:"serial,omitempty"`
 	UseDescFormat bool   `json:"use_desc_format,omitempty"`
+	Presence      string `json:"presence,omitempty"` // profiles only: monitorRequired or monitorOptional
 	PxW           uint32
 	PxH           uint32
 	Hz            float32
@@ -30,12 +33,16 @@ type Monitor struct {
 
 	// Advanced display settings
 	BitDepth      uint8   // 8 or 10
-	ColorMode     string  // "auto", "srgb", "wide", "edid", "hdr", "hdredid"
+	ColorMode     string  // "auto", "srgb", "dcip3", "dp3", "adobe", "wide", "edid", "hdr", "hdredid"
 	SDRBrightness float32 // 1.0 default, typically 1.0-2.0
 	SDRSaturation float32 // 1.0 default
-	VRR           int     // 0=off, 1=on, 2=fullscreen-only
+	VRR           int     // 0=off, 1=on, 2=fullscreen-only, 3=fullscreen video/game
 	Transform     int     // 0-7 for rotation/flip
 
+	// HDR luminance overrides, only written in monitorv2 blocks
+	SDRMinLuminance float32 // 0 leaves Hyprland's default
+	SDRMaxLuminance int     // 0 leaves Hyprland's default
+
 	// Mirror settings
 	IsMirrored    bool     // Whether this monitor is mirroring another
 	MirrorSource  string   // Name of monitor being mirrored (empty if not mirroring)
@@ -100,28 +107,68 @@ type model struct {
 	ShowAdvancedSettings bool
 	AdvancedSettings     advancedSettingsModel
 
+	// Set to the file's path when a save stopped because the managed block
+	// was edited by hand; the next key picks overwrite, merge or abort
+	SaveConflict string
+
+	// Global hyprmon settings dialog
+	ShowSettings   bool
+	SettingsDialog settingsDialogModel
+
+	// Workspace rules saved and applied with the layout, and their editor
+	Workspaces          []WorkspaceRule
+	ShowWorkspaceEditor bool
+	WorkspaceEditor     workspaceEditorModel
+
+	// Keep-or-revert prompt shown after applying a layout
+	ShowConfirmRevert bool
+	ConfirmDeadline   time.Time
+	ConfirmID         int            // Identifies the current countdown's ticks
+	ApplyDrift        []monitorDrift // Differences found after the last apply
+
+	// Dry-run mode: apply/save print what they would do and exit
+	DryRun       bool
+	DryRunOutput string
+
+	// Undo/redo history for layout edits
+	History layoutHistory
+
 	// Monitor tracking for workspace migration
 	PreviousMonitorNames []string
 }
 
 type initMsg struct {
-	monitors []Monitor
-	err      error
+	monitors   []Monitor
+	workspaces []WorkspaceRule // from the profile matching the layout, if any
+	err        error
 }
 
 type applyMsg struct {
 	success bool
 	err     error
+	drifts  []monitorDrift // settings Hyprland did not apply as requested
+
+	workspaceErr error // workspace rules that couldn't be applied; the layout itself applied
+
+	hookOutput string // what the pre_apply and post_apply hooks printed
+	hookErr    error  // a failed post_apply hook; the apply itself went through
 }
 
 type saveMsg struct {
 	success bool
 	err     error
+	path    string // file the monitor rules were written to
+
+	hookOutput string // what the pre_apply and post_apply hooks printed
+	hookErr    error  // a failed post_apply hook; the save itself went through
 }
 
 type revertMsg struct {
 	success bool
 	err     error
+
+	hookOutput string // what the post_apply hooks printed
+	hookErr    error  // a failed post_apply hook; the revert itself went through
 }
 
 func (m *model) updateWorld() {
@@ -225,6 +272,9 @@ func (m *model) beginDrag(msg tea.MouseMsg) {
 		return
 	}
 
+	// The whole drag, from press to release, is a single undo step
+	m.beginEdit("moved " + m.Monitors[m.Selected].Name)
+
 	mon := &m.Monitors[m.Selected]
 	wx, wy := m.termToWorld(msg.X, msg.Y)
 	mon.Dragging = true
@@ -268,6 +318,7 @@ func (m *model) endDrag() {
 	mon.Dragging = false
 	m.Guides = nil
 	m.updateWorld()
+	m.commitEdit()
 }
 
 func (m *model) moveSelected(dx, dy int32) {
@@ -275,6 +326,8 @@ func (m *model) moveSelected(dx, dy int32) {
 		return
 	}
 
+	m.beginEdit("moved " + m.Monitors[m.Selected].Name)
+
 	mon := &m.Monitors[m.Selected]
 	mon.X += dx
 	mon.Y += dy
@@ -284,6 +337,7 @@ func (m *model) moveSelected(dx, dy int32) {
 		m.Guides = nil
 	}
 	m.updateWorld()
+	m.commitEdit()
 }
 
 func (m *model) snapPosition(mon *Monitor, x, y int32) (int32, int32, []guide) {
@@ -644,3 +644,148 @@ func TestMonitorUseDescFormatRoundTrip(t *testing.T) {
 		t.Errorf("omitempty failed: zero value appeared in JSON: %s", zeroData)
 	}
 }
+
+func newUndoTestModel() model {
+	m := model{
+		GridPx: 32,
+		Snap:   SnapOff,
+		World: world{
+			TermW: 80,
+			TermH: 24,
+		},
+		Selected: 0,
+		Monitors: []Monitor{
+			{Name: "DP-1", PxW: 1920, PxH: 1080, Scale: 1, Active: true},
+			{Name: "HDMI-A-1", X: 1920, PxW: 1920, PxH: 1080, Scale: 1, Active: true},
+		},
+	}
+	m.updateWorld()
+	return m
+}
+
+func pressKey(t *testing.T, m model, msg tea.KeyMsg) model {
+	t.Helper()
+	updated, _ := m.handleKey(msg)
+	return updated.(model)
+}
+
+func TestUndoRedoKeyboardMoves(t *testing.T) {
+	m := newUndoTestModel()
+
+	m = pressKey(t, m, tea.KeyMsg{Type: tea.KeyRight})
+	m = pressKey(t, m, tea.KeyMsg{Type: tea.KeyRight})
+	m = pressKey(t, m, tea.KeyMsg{Type: tea.KeyRight})
+	if m.Monitors[0].X != 96 {
+		t.Fatalf("X after three moves = %d, want 96", m.Monitors[0].X)
+	}
+
+	m = pressKey(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'u'}})
+	if m.Monitors[0].X != 64 {
+		t.Fatalf("X after undo = %d, want 64", m.Monitors[0].X)
+	}
+	if m.Status != "undo: moved DP-1" {
+		t.Errorf("Status = %q, want %q", m.Status, "undo: moved DP-1")
+	}
+
+	m = pressKey(t, m, tea.KeyMsg{Type: tea.KeyCtrlZ})
+	if m.Monitors[0].X != 32 {
+		t.Fatalf("X after second undo = %d, want 32", m.Monitors[0].X)
+	}
+
+	m = pressKey(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'U'}})
+	if m.Monitors[0].X != 0 {
+		t.Fatalf("X after third undo = %d, want 0", m.Monitors[0].X)
+	}
+
+	m = pressKey(t, m, tea.KeyMsg{Type: tea.KeyCtrlZ})
+	if m.Status != "Nothing to undo" {
+		t.Errorf("Status = %q, want %q", m.Status, "Nothing to undo")
+	}
+
+	m = pressKey(t, m, tea.KeyMsg{Type: tea.KeyCtrlY})
+	if m.Monitors[0].X != 32 || m.Status != "redo: moved DP-1" {
+		t.Fatalf("after redo X = %d, Status = %q", m.Monitors[0].X, m.Status)
+	}
+
+	// A fresh edit discards what could still be redone.
+	m = pressKey(t, m, tea.KeyMsg{Type: tea.KeyDown})
+	m = pressKey(t, m, tea.KeyMsg{Type: tea.KeyCtrlY})
+	if m.Status != "Nothing to redo" {
+		t.Errorf("Status = %q, want %q", m.Status, "Nothing to redo")
+	}
+}
+
+func TestUndoTreatsDragAsOneStep(t *testing.T) {
+	m := newUndoTestModel()
+
+	m.beginDrag(tea.MouseMsg{X: 2, Y: 2})
+	for x := 3; x < 10; x++ {
+		m.dragMove(tea.MouseMsg{X: x, Y: 2})
+	}
+	m.endDrag()
+
+	if m.Monitors[0].X == 0 {
+		t.Fatal("drag did not move the monitor")
+	}
+	if len(m.History.undo) != 1 {
+		t.Fatalf("undo steps after drag = %d, want 1", len(m.History.undo))
+	}
+
+	m.undo()
+	if m.Monitors[0].X != 0 || m.Monitors[0].Dragging {
+		t.Fatalf("after undo X = %d, Dragging = %v, want 0, false", m.Monitors[0].X, m.Monitors[0].Dragging)
+	}
+
+	// A click without movement is not an edit.
+	m.History = layoutHistory{}
+	m.beginDrag(tea.MouseMsg{X: 2, Y: 2})
+	m.endDrag()
+	if len(m.History.undo) != 0 {
+		t.Fatalf("undo steps after click = %d, want 0", len(m.History.undo))
+	}
+}
+
+func TestUndoToggleAndMirror(t *testing.T) {
+	m := newUndoTestModel()
+
+	m = pressKey(t, m, tea.KeyMsg{Type: tea.KeyEnter})
+	if m.Monitors[0].Active {
+		t.Fatal("enter did not disable DP-1")
+	}
+
+	m.ShowMirrorPicker = true
+	updated, _ := m.Update(mirrorSelectedMsg{source: "HDMI-A-1"})
+	m = updated.(model)
+	if m.Monitors[0].MirrorSource != "HDMI-A-1" || len(m.Monitors[1].MirrorTargets) != 1 {
+		t.Fatalf("mirror not set up: %+v", m.Monitors)
+	}
+
+	m.undo()
+	if m.Status != "undo: changed mirroring of DP-1" {
+		t.Errorf("Status = %q", m.Status)
+	}
+	if m.Monitors[0].MirrorSource != "" || len(m.Monitors[1].MirrorTargets) != 0 {
+		t.Fatalf("mirror not undone: %+v", m.Monitors)
+	}
+
+	m.undo()
+	if m.Status != "undo: disabled DP-1" || !m.Monitors[0].Active {
+		t.Fatalf("toggle not undone: Status = %q, Active = %v", m.Status, m.Monitors[0].Active)
+	}
+}
+
+func TestUndoHistoryIsBounded(t *testing.T) {
+	m := newUndoTestModel()
+	for i := 0; i < maxHistorySteps+20; i++ {
+		m.moveSelected(1, 0)
+	}
+	if len(m.History.undo) != maxHistorySteps {
+		t.Fatalf("undo steps = %d, want %d", len(m.History.undo), maxHistorySteps)
+	}
+	for len(m.History.undo) > 0 {
+		m.undo()
+	}
+	if m.Monitors[0].X != 20 {
+		t.Fatalf("X after undoing everything = %d, want 20 (oldest steps dropped)", m.Monitors[0].X)
+	}
+}
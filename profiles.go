@@ -1,8 +1,11 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -17,8 +20,18 @@ import (
 var customConfigPath string
 
 type Profile struct {
-	Name      string    `json:"name"`
-	Monitors  []Monitor `json:"monitors"`
+	Name       string          `json:"name"`
+	Monitors   []Monitor       `json:"monitors"`
+	Workspaces []WorkspaceRule `json:"workspaces,omitempty"`
+
+	// Priority decides between profiles that fit the connected monitors
+	// for --auto and the daemon; higher wins
+	Priority   int                `json:"priority,omitempty"`
+	Conditions *ProfileConditions `json:"conditions,omitempty"`
+
+	// Hooks run when the profile is applied, after those in settings
+	Hooks
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -45,11 +58,9 @@ func ensureProfilesDir() error {
 	return os.MkdirAll(dir, profileDirMode)
 }
 
+// saveProfile stores monitors as the named profile. An existing profile
+// keeps its creation time and workspace rules.
 func saveProfile(name string, monitors []Monitor) error {
-	if err := ensureProfilesDir(); err != nil {
-		return err
-	}
-
 	profile := Profile{
 		Name:      name,
 		Monitors:  monitors,
@@ -63,9 +74,35 @@ func saveProfile(name string, monitors []Monitor) error {
 		existingProfile, err := loadProfile(name)
 		if err == nil {
 			profile.CreatedAt = existingProfile.CreatedAt
+			profile.Workspaces = existingProfile.Workspaces
+			profile.Priority = existingProfile.Priority
+			profile.Conditions = existingProfile.Conditions
+			profile.Hooks = existingProfile.Hooks
+			profile.Monitors = keepMonitorPresence(monitors, existingProfile.Monitors)
 		}
 	}
 
+	return writeProfile(&profile)
+}
+
+// saveProfileWorkspaces replaces the workspace rules of a saved profile.
+func saveProfileWorkspaces(name string, workspaces []WorkspaceRule) error {
+	profile, err := loadProfile(name)
+	if err != nil {
+		return err
+	}
+	profile.Workspaces = workspaces
+	profile.UpdatedAt = time.Now()
+	return writeProfile(profile)
+}
+
+// writeProfile writes a profile to <name>.json in the profiles directory.
+func writeProfile(profile *Profile) error {
+	if err := ensureProfilesDir(); err != nil {
+		return err
+	}
+	filename := filepath.Join(getProfilesDir(), fmt.Sprintf("%s.json", profile.Name))
+
 	data, err := json.MarshalIndent(profile, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal profile: %w", err)
@@ -118,6 +155,41 @@ func listProfiles() ([]string, error) {
 	return profiles, nil
 }
 
+// listOrderedProfiles returns profile names in the user's saved order,
+// followed by any profiles that are not in the saved order yet.
+func listOrderedProfiles() ([]string, error) {
+	profiles, err := listProfiles()
+	if err != nil {
+		return nil, err
+	}
+
+	savedOrder, _ := loadProfileOrder()
+	if len(savedOrder) == 0 {
+		return profiles, nil
+	}
+
+	orderedProfiles := []string{}
+	remainingProfiles := make(map[string]bool)
+	for _, p := range profiles {
+		remainingProfiles[p] = true
+	}
+
+	for _, name := range savedOrder {
+		if remainingProfiles[name] {
+			orderedProfiles = append(orderedProfiles, name)
+			delete(remainingProfiles, name)
+		}
+	}
+
+	for _, p := range profiles {
+		if remainingProfiles[p] {
+			orderedProfiles = append(orderedProfiles, p)
+		}
+	}
+
+	return orderedProfiles, nil
+}
+
 func deleteProfile(name string) error {
 	filename := filepath.Join(getProfilesDir(), fmt.Sprintf("%s.json", name))
 	return os.Remove(filename)
@@ -195,7 +267,7 @@ func renameProfile(oldName, newName string) error {
 	profile.Name = newName
 
 	// Save with new name
-	if err := saveProfile(newName, profile.Monitors); err != nil {
+	if err := writeProfile(profile); err != nil {
 		return fmt.Errorf("failed to save renamed profile: %w", err)
 	}
 
@@ -207,45 +279,137 @@ func renameProfile(oldName, newName string) error {
 }
 
 func applyProfile(name string) error {
+	_, err := applyProfileWithConfirm(name, conflictMerge, nil, io.Discard)
+	return err
+}
+
+// applyProfileWithConfirm applies a profile live and, when confirm is not nil,
+// asks it whether to keep the result before touching the config file. If
+// confirm returns false the previous layout is restored and
+// errLayoutNotConfirmed is returned. The returned drifts list the settings
+// Hyprland did not apply as requested. The output of the pre_apply and
+// post_apply hooks goes to out; a pre_apply failure stops the apply.
+// policy handles hand edits to the managed block; with conflictAsk they
+// are looked for before anything is applied and returned as a
+// *configEditedError, so the apply can be retried with the answer.
+func applyProfileWithConfirm(name string, policy conflictPolicy, confirm func() bool, out io.Writer) ([]monitorDrift, error) {
+	return applyProfileForLid(name, lidOpen, policy, confirm, out)
+}
+
+// applyProfileForLid is applyProfileWithConfirm for a lid in state lid.
+// With the lid closed, the profile's internal panels are applied and
+// saved as off, as long as another of its monitors is on, and remembered
+// like closing the lid does, so opening it turns them on as the profile
+// has them.
+func applyProfileForLid(name, lid string, policy conflictPolicy, confirm func() bool, out io.Writer) ([]monitorDrift, error) {
 	profile, err := loadProfile(name)
 	if err != nil {
-		return fmt.Errorf("failed to load profile %s: %w", name, err)
+		return nil, fmt.Errorf("failed to load profile %s: %w", name, err)
 	}
 
 	currentMonitors, err := readMonitors()
 	if err != nil {
-		return fmt.Errorf("failed to read current monitors: %w", err)
+		return nil, fmt.Errorf("failed to read current monitors: %w", err)
 	}
 
 	resolved := resolveProfileMonitors(profile.Monitors, currentMonitors)
 	if len(resolved) == 0 {
-		return fmt.Errorf("no monitors from profile %q are currently connected", name)
+		return nil, fmt.Errorf("no monitors from profile %q are currently connected", name)
+	}
+	var lidPanels []Monitor
+	if lid == lidClosed {
+		resolved, lidPanels = lidClosedLayout(resolved)
+	}
+
+	// Ask about hand edits first, so the hooks run once per apply and not
+	// again when it is retried with overwrite or merge. Other problems
+	// with the config are left to writing it, which reports them.
+	if policy == conflictAsk {
+		var edited *configEditedError
+		if err := checkConfigEdited(resolved); errors.As(err, &edited) {
+			return nil, err
+		}
+	}
+
+	// What the layout will be: the profile's monitors, and the connected
+	// monitors it doesn't mention, which are left as they are
+	layout := append([]Monitor(nil), resolved...)
+	for _, m := range currentMonitors {
+		if !containsMonitorNamed(resolved, m.Name) {
+			layout = append(layout, m)
+		}
+	}
+	if err := runHooks(hookPreApply, profile, layout, out); err != nil {
+		return nil, err
+	}
+
+	if err := saveRollback(currentMonitors); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to save rollback state: %v\n", err)
 	}
 
-	saveRollback(resolved)
+	// Where the workspaces are, in case Hyprland moves some off monitors
+	// the profile turns off before they can be migrated
+	workspaces, _ := readWorkspaces()
 
-	// Get current monitor names before applying changes
-	previousNames, _ := getCurrentMonitorNames()
+	if len(lidPanels) > 0 {
+		if err := rememberLidPanels(lidPanels); err != nil {
+			return nil, err
+		}
+	}
 
 	if err := applyMonitors(resolved); err != nil {
-		return fmt.Errorf("failed to apply profile: %w", err)
+		return nil, fmt.Errorf("failed to apply profile: %w", err)
+	}
+
+	drifts, err := verifyApplied(resolved)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to verify applied settings: %v\n", err)
+	}
+
+	if confirm != nil && !confirm() {
+		restored, err := rollback()
+		if err != nil {
+			return drifts, fmt.Errorf("failed to revert unconfirmed profile: %w", err)
+		}
+		// pre_apply already ran, so let post_apply see the layout that is back
+		if err := runHooks(hookPostApply, profile, restored, out); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		return drifts, errLayoutNotConfirmed
 	}
 
-	// Get monitor names after applying and migrate orphaned workspaces
-	currentNames, _ := getCurrentMonitorNames()
-	if err := migrateOrphanedWorkspaces(previousNames, currentNames); err != nil {
-		fmt.Printf("Warning: Failed to migrate workspaces: %v\n", err)
+	// Move workspaces off monitors the profile turned off, and back onto
+	// the ones it turned on again
+	if after, err := readMonitors(); err == nil {
+		if err := migrateOrphanedWorkspaces(currentMonitors, after, workspaces); err != nil {
+			fmt.Printf("Warning: Failed to migrate workspaces: %v\n", err)
+		}
+	}
+
+	// Then put the profile's workspaces where it wants them
+	if err := applyWorkspaceRules(profile.Workspaces, resolved); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to apply workspace rules: %v\n", err)
 	}
 
-	if err := writeConfig(resolved); err != nil {
-		return fmt.Errorf("failed to write config: %w", err)
+	if err := writeConfigWithPolicy(resolved, profile.Workspaces, policy); err != nil {
+		return drifts, fmt.Errorf("failed to write config: %w", err)
 	}
 
 	if err := reloadConfig(); err != nil {
-		return fmt.Errorf("failed to reload config: %w", err)
+		return drifts, fmt.Errorf("failed to reload config: %w", err)
 	}
 
-	return nil
+	return drifts, runHooks(hookPostApply, profile, layout, out)
+}
+
+// containsMonitorNamed reports whether monitors has one on connector name.
+func containsMonitorNamed(monitors []Monitor, name string) bool {
+	for _, m := range monitors {
+		if m.Name == name {
+			return true
+		}
+	}
+	return false
 }
 
 // compareMonitorConfigurations compares two monitor configurations for equality.
@@ -368,9 +532,17 @@ type profileMenuModel struct {
 	renameCursor    int
 	profileOrder    []string // Keep track of custom order
 	showHelp        bool
-	launchFullUI    bool // Flag to indicate launching full UI
-	termWidth       int  // Terminal width for responsive layout
-	termHeight      int  // Terminal height
+	launchFullUI    bool   // Flag to indicate launching full UI
+	applied         string // What was applied, printed once the menu closes
+	conflictProfile string // Profile whose apply waits for overwrite, merge or abort
+	conflictPath    string // File with hand edits inside the hyprmon block
+	termWidth       int    // Terminal width for responsive layout
+	termHeight      int    // Terminal height
+
+	// Workspace rule editor for the profile named by workspaceProfile
+	editingWorkspaces bool
+	workspaceProfile  string
+	workspaceEditor   workspaceEditorModel
 }
 
 func initialProfileMenu() (profileMenuModel, error) {
@@ -440,6 +612,31 @@ func (m profileMenuModel) Init() tea.Cmd {
 }
 
 func (m profileMenuModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	// Handle the workspace rule editor first
+	if m.editingWorkspaces {
+		switch msg := msg.(type) {
+		case workspaceEditorSaveMsg:
+			if err := saveProfileWorkspaces(m.workspaceProfile, msg.rules); err != nil {
+				m.err = err
+			}
+			m.editingWorkspaces = false
+			return m, nil
+		case workspaceEditorCancelMsg:
+			m.editingWorkspaces = false
+			return m, nil
+		case tea.WindowSizeMsg:
+			m.termWidth = msg.Width
+			m.termHeight = msg.Height
+		case tea.KeyMsg:
+			if msg.String() == "ctrl+c" {
+				return m, tea.Quit
+			}
+		}
+		newEditor, cmd := m.workspaceEditor.Update(msg)
+		m.workspaceEditor = newEditor
+		return m, cmd
+	}
+
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.termWidth = msg.Width
@@ -535,6 +732,20 @@ func (m profileMenuModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 
+		// An apply stopped by hand edits waits for overwrite, merge or abort
+		if m.conflictProfile != "" {
+			name, path := m.conflictProfile, filepath.Base(m.conflictPath)
+			m.conflictProfile, m.conflictPath = "", ""
+			switch msg.String() {
+			case "o", "O":
+				return m.applySelected(name, conflictOverwrite)
+			case "m", "M":
+				return m.applySelected(name, conflictMerge)
+			}
+			m.err = fmt.Errorf("applying %s aborted; %s was not changed", name, path)
+			return m, nil
+		}
+
 		// Handle delete confirmation
 		if m.confirmDelete {
 			switch msg.String() {
@@ -660,13 +871,7 @@ func (m profileMenuModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				// Separator line, do nothing
 				return m, nil
 			} else {
-				err := applyProfile(selectedProfile)
-				if err != nil {
-					m.err = err
-					return m, nil
-				}
-				fmt.Printf("Applied profile: %s\n", selectedProfile)
-				return m, tea.Quit
+				return m.applySelected(selectedProfile, conflictAsk)
 			}
 
 		case "d", "D":
@@ -685,6 +890,20 @@ func (m profileMenuModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.renaming = true
 			}
 
+		case "w", "W":
+			// Edit the workspace rules of the selected profile
+			if m.selected < len(m.profiles)-2 && !strings.HasPrefix(m.profiles[m.selected], "─") {
+				name := m.profiles[m.selected]
+				profile, err := loadProfile(name)
+				if err != nil {
+					m.err = err
+					return m, nil
+				}
+				m.workspaceProfile = name
+				m.workspaceEditor = newWorkspaceEditor(name, profile.Workspaces, profile.Monitors, m.termWidth, m.termHeight)
+				m.editingWorkspaces = true
+			}
+
 		case "?":
 			m.showHelp = true
 			return m, nil
@@ -694,6 +913,32 @@ func (m profileMenuModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// applySelected applies the profile picked in the menu and closes it. Hand
+// edits to the managed block leave the menu open to ask about them.
+func (m profileMenuModel) applySelected(name string, policy conflictPolicy) (tea.Model, tea.Cmd) {
+	var output bytes.Buffer
+	_, err := applyProfileWithConfirm(name, policy, nil, &output)
+	var edited *configEditedError
+	if errors.As(err, &edited) && policy == conflictAsk {
+		m.err = nil
+		m.conflictProfile, m.conflictPath = name, edited.Path
+		return m, nil
+	}
+	// A failed post_apply hook doesn't undo the apply; report it next to
+	// the result instead
+	var postErr error
+	var hookErr *hookError
+	if errors.As(err, &hookErr) && hookErr.Hook == hookPostApply {
+		postErr, err = err, nil
+	}
+	if err != nil {
+		m.err = err
+		return m, nil
+	}
+	m.applied = hookStatus("Applied profile: "+name, output.String(), postErr)
+	return m, tea.Quit
+}
+
 func (m profileMenuModel) renderHelp() string {
 	helpStyle := lipgloss.NewStyle().
 		Padding(2, 4).
@@ -752,6 +997,7 @@ func (m profileMenuModel) renderHelp() string {
 		desc string
 	}{
 		{"R", "Rename selected profile"},
+		{"W", "Edit which monitor each workspace goes on"},
 		{"D", "Delete selected profile (with confirmation)"},
 	}
 
@@ -765,6 +1011,7 @@ func (m profileMenuModel) renderHelp() string {
 	content.WriteString("\n")
 	content.WriteString("• Profiles save your complete monitor configuration\n")
 	content.WriteString("• Includes position, resolution, refresh rate, and scale\n")
+	content.WriteString("• Workspace rules move workspaces to their monitors on apply\n")
 	content.WriteString("• Profiles are stored in ~/.config/hyprmon/profiles/\n")
 	content.WriteString("• Custom ordering is preserved between sessions\n")
 	content.WriteString("• Use 'hyprmon -profile NAME' to apply directly from CLI\n")
@@ -787,6 +1034,11 @@ func (m profileMenuModel) View() string {
 		return m.renderHelp()
 	}
 
+	// Show the workspace rule editor if active
+	if m.editingWorkspaces {
+		return m.workspaceEditor.View()
+	}
+
 	var s strings.Builder
 
 	titleStyle := lipgloss.NewStyle().
@@ -828,6 +1080,22 @@ func (m profileMenuModel) View() string {
 		return s.String()
 	}
 
+	// Ask what to do about hand edits before applying
+	if m.conflictProfile != "" {
+		conflictStyle := lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("214")).
+			Padding(1, 2).
+			MarginTop(1).
+			MarginBottom(1)
+
+		conflictPrompt := fmt.Sprintf("%s has hand edits inside the hyprmon block.\n\nApply '%s' anyway?\n\nO: overwrite  •  M: merge (keep rules for other monitors)  •  any other key: abort",
+			filepath.Base(m.conflictPath), m.conflictProfile)
+		s.WriteString(conflictStyle.Render(conflictPrompt))
+		s.WriteString("\n")
+		return s.String()
+	}
+
 	// Show delete confirmation dialog if active
 	if m.confirmDelete {
 		confirmStyle := lipgloss.NewStyle().
@@ -898,6 +1166,7 @@ func (m profileMenuModel) renderFooter() string {
 		{"Shift+↑/↓ Reorder", "S+↑/↓ Order", "S+↑↓", 2},
 		{"Enter Select", "Enter Sel", "⏎", 1},
 		{"R Rename", "R Rename", "R", 2},
+		{"W Workspaces", "W Wksp", "W", 3},
 		{"D Delete", "D Delete", "D", 2},
 		{"? Help", "? Help", "?", 1},
 		{"Q Quit", "Q Quit", "Q", 1},
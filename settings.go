@@ -13,9 +13,71 @@ type MonitorPref struct {
 	UseDescFormat bool `json:"use_desc_format,omitempty"`
 }
 
+// Ways of writing monitor rules to a hyprlang config
+const (
+	// hyprlangModeInline keeps the rules in a managed block inside
+	// hyprland.conf. This is the default.
+	hyprlangModeInline = "inline"
+	// hyprlangModeSidecar writes the rules to hyprmon.conf and includes it
+	// from hyprland.conf with a source line.
+	hyprlangModeSidecar = "sidecar"
+)
+
+// Syntax of the monitor rules hyprmon writes to a hyprlang config
+const (
+	// monitorSyntaxV1 is the comma-separated monitor= line. This is the
+	// default.
+	monitorSyntaxV1 = "monitor"
+	// monitorSyntaxV2 is a monitorv2 { ... } block, which needs a newer
+	// Hyprland.
+	monitorSyntaxV2 = "monitorv2"
+)
+
+// Where config backups are written
+const (
+	// backupLocationConfig puts <file>.bak.<unix> next to the config file.
+	// This is the default.
+	backupLocationConfig = "config"
+	// backupLocationState keeps backups under the state directory instead,
+	// mirroring the config file's absolute path.
+	backupLocationState = "state"
+)
+
+// Where workspaces go when the monitor they are on is disabled or unplugged
+const (
+	// workspaceMigrationFocused moves them to the focused monitor. This is
+	// the default.
+	workspaceMigrationFocused = "focused"
+	// workspaceMigrationPrimary moves them to the primary monitor: the one
+	// named by primary_monitor, or the one closest to the layout's origin.
+	workspaceMigrationPrimary = "primary"
+	// workspaceMigrationNearest moves them to the monitor that was closest
+	// to the one they were on.
+	workspaceMigrationNearest = "nearest"
+)
+
 // Settings is the on-disk hyprmon settings file.
 type Settings struct {
-	MonitorPrefs map[string]MonitorPref `json:"monitor_prefs,omitempty"`
+	MonitorPrefs  map[string]MonitorPref `json:"monitor_prefs,omitempty"`
+	HyprlangMode  string                 `json:"hyprlang_mode,omitempty"`
+	MonitorSyntax string                 `json:"monitor_syntax,omitempty"`
+
+	// Backup retention for `hyprmon backups prune`: keep at most BackupKeep
+	// backups per file and drop those older than BackupKeepDays. Zero
+	// disables a limit.
+	BackupKeep     int    `json:"backup_keep,omitempty"`
+	BackupKeepDays int    `json:"backup_keep_days,omitempty"`
+	BackupLocation string `json:"backup_location,omitempty"`
+
+	// Workspace migration strategy, and the HardwareID (or connector name)
+	// of the primary monitor it can target
+	WorkspaceMigration string `json:"workspace_migration,omitempty"`
+	PrimaryMonitor     string `json:"primary_monitor,omitempty"`
+
+	// Hooks run around every layout change, before those of a profile.
+	// HookTimeout is in seconds; zero means defaultHookTimeout.
+	Hooks
+	HookTimeout int `json:"hook_timeout,omitempty"`
 }
 
 // getSettingsDir returns the directory that holds settings.json. It mirrors
@@ -75,8 +137,14 @@ func saveSettings(s *Settings) error {
 		return fmt.Errorf("failed to marshal settings: %w", err)
 	}
 
-	path := filepath.Join(dir, "settings.json")
-	tmp, err := os.CreateTemp(dir, "settings.*.tmp")
+	return writeFileAtomic(filepath.Join(dir, "settings.json"), data)
+}
+
+// writeFileAtomic writes data to path via a temp file in the same directory
+// and a rename, so readers never see a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	dir, name := filepath.Split(path)
+	tmp, err := os.CreateTemp(dir, name+".*.tmp")
 	if err != nil {
 		return fmt.Errorf("failed to create temp file: %w", err)
 	}
@@ -87,26 +155,26 @@ func saveSettings(s *Settings) error {
 	if err := tmp.Chmod(configFileMode); err != nil {
 		_ = tmp.Close()
 		cleanup()
-		return fmt.Errorf("failed to chmod temp settings file: %w", err)
+		return fmt.Errorf("failed to chmod temp file: %w", err)
 	}
 	if _, err := tmp.Write(data); err != nil {
 		_ = tmp.Close()
 		cleanup()
-		return fmt.Errorf("failed to write settings: %w", err)
+		return fmt.Errorf("failed to write %s: %w", name, err)
 	}
 	if err := tmp.Sync(); err != nil {
 		_ = tmp.Close()
 		cleanup()
-		return fmt.Errorf("failed to sync settings: %w", err)
+		return fmt.Errorf("failed to sync %s: %w", name, err)
 	}
 	if err := tmp.Close(); err != nil {
 		cleanup()
-		return fmt.Errorf("failed to close settings: %w", err)
+		return fmt.Errorf("failed to close %s: %w", name, err)
 	}
 
 	if err := os.Rename(tmpPath, path); err != nil {
 		cleanup()
-		return fmt.Errorf("failed to rename settings into place: %w", err)
+		return fmt.Errorf("failed to rename %s into place: %w", name, err)
 	}
 	return nil
 }
@@ -131,3 +199,39 @@ func setMonitorPref(s *Settings, hwid string, pref MonitorPref) {
 	}
 	s.MonitorPrefs[hwid] = pref
 }
+
+// getHyprlangMode returns how monitor rules are written to a hyprlang
+// config. Unknown values fall back to inline.
+func getHyprlangMode(s *Settings) string {
+	if s != nil && s.HyprlangMode == hyprlangModeSidecar {
+		return hyprlangModeSidecar
+	}
+	return hyprlangModeInline
+}
+
+// getMonitorSyntax returns the syntax for monitor rules written to a
+// hyprlang config. Unknown values fall back to monitor= lines.
+func getMonitorSyntax(s *Settings) string {
+	if s != nil && s.MonitorSyntax == monitorSyntaxV2 {
+		return monitorSyntaxV2
+	}
+	return monitorSyntaxV1
+}
+
+// getBackupLocation returns where config backups are written. Unknown
+// values fall back to next to the config file.
+func getBackupLocation(s *Settings) string {
+	if s != nil && s.BackupLocation == backupLocationState {
+		return backupLocationState
+	}
+	return backupLocationConfig
+}
+
+// getWorkspaceMigration returns where orphaned workspaces are moved.
+// Unknown values fall back to the focused monitor.
+func getWorkspaceMigration(s *Settings) string {
+	if s != nil && (s.WorkspaceMigration == workspaceMigrationPrimary || s.WorkspaceMigration == workspaceMigrationNearest) {
+		return s.WorkspaceMigration
+	}
+	return workspaceMigrationFocused
+}
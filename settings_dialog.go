@@ -0,0 +1,286 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// settingsDialogModel edits the global hyprmon settings. It works on a
+// copy; the caller saves it when the dialog is confirmed.
+type settingsDialogModel struct {
+	settings     Settings
+	focusedField int
+	width        int
+	height       int
+}
+
+const (
+	settingsFieldHyprlangMode = iota
+	settingsFieldMonitorSyntax
+	settingsFieldBackupLocation
+	settingsFieldBackupKeep
+	settingsFieldBackupKeepDays
+	settingsFieldWorkspaceMigration
+	settingsFieldCount
+)
+
+// The values the backup retention fields step through; 0 is no limit
+var (
+	backupKeepSteps     = []int{0, 3, 5, 10, 20, 50, 100}
+	backupKeepDaysSteps = []int{0, 7, 14, 30, 90, 365}
+)
+
+func newSettingsDialog(s *Settings, width, height int) settingsDialogModel {
+	return settingsDialogModel{
+		settings: *s,
+		width:    width,
+		height:   height,
+	}
+}
+
+func (m settingsDialogModel) Update(msg tea.Msg) (settingsDialogModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "up", "shift+tab":
+			m.focusedField = (m.focusedField - 1 + settingsFieldCount) % settingsFieldCount
+
+		case "down", "tab":
+			m.focusedField = (m.focusedField + 1) % settingsFieldCount
+
+		case "left":
+			m.adjustValue(-1)
+
+		case "right", " ", "space":
+			m.adjustValue(1)
+		}
+	}
+
+	return m, nil
+}
+
+// adjustValue steps the number in a retention field up or down, and flips
+// the other fields.
+func (m *settingsDialogModel) adjustValue(delta int) {
+	switch m.focusedField {
+	case settingsFieldBackupKeep:
+		m.settings.BackupKeep = stepValue(backupKeepSteps, m.settings.BackupKeep, delta)
+	case settingsFieldBackupKeepDays:
+		m.settings.BackupKeepDays = stepValue(backupKeepDaysSteps, m.settings.BackupKeepDays, delta)
+	default:
+		m.toggleValue()
+	}
+}
+
+// stepValue returns the step after current (delta > 0) or before it,
+// staying at the ends. Values set by hand between steps move to the
+// nearest step in that direction.
+func stepValue(steps []int, current, delta int) int {
+	if delta > 0 {
+		for _, step := range steps {
+			if step > current {
+				return step
+			}
+		}
+		return max(current, steps[len(steps)-1])
+	}
+	for i := len(steps) - 1; i >= 0; i-- {
+		if steps[i] < current {
+			return steps[i]
+		}
+	}
+	return steps[0]
+}
+
+func (m *settingsDialogModel) toggleValue() {
+	switch m.focusedField {
+	case settingsFieldHyprlangMode:
+		if getHyprlangMode(&m.settings) == hyprlangModeSidecar {
+			m.settings.HyprlangMode = hyprlangModeInline
+		} else {
+			m.settings.HyprlangMode = hyprlangModeSidecar
+		}
+
+	case settingsFieldMonitorSyntax:
+		if getMonitorSyntax(&m.settings) == monitorSyntaxV2 {
+			m.settings.MonitorSyntax = monitorSyntaxV1
+		} else {
+			m.settings.MonitorSyntax = monitorSyntaxV2
+		}
+
+	case settingsFieldBackupLocation:
+		if getBackupLocation(&m.settings) == backupLocationState {
+			m.settings.BackupLocation = backupLocationConfig
+		} else {
+			m.settings.BackupLocation = backupLocationState
+		}
+
+	case settingsFieldWorkspaceMigration:
+		switch getWorkspaceMigration(&m.settings) {
+		case workspaceMigrationFocused:
+			m.settings.WorkspaceMigration = workspaceMigrationPrimary
+		case workspaceMigrationPrimary:
+			m.settings.WorkspaceMigration = workspaceMigrationNearest
+		default:
+			m.settings.WorkspaceMigration = workspaceMigrationFocused
+		}
+	}
+}
+
+func (m settingsDialogModel) View() string {
+	if m.width == 0 || m.height == 0 {
+		m.width = 60
+		m.height = 20
+	}
+
+	dialogStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("42")).
+		Padding(1, 2).
+		Width(64)
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("214")).
+		MarginBottom(1)
+
+	labelStyle := lipgloss.NewStyle().
+		Width(16).
+		Foreground(lipgloss.Color("244"))
+
+	focusedLabelStyle := labelStyle.
+		Foreground(lipgloss.Color("214")).
+		Bold(true)
+
+	valueStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("42"))
+
+	focusedValueStyle := valueStyle.
+		Foreground(lipgloss.Color("214")).
+		Bold(true)
+
+	hintStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	var content strings.Builder
+
+	content.WriteString(titleStyle.Render("HyprMon Settings"))
+	content.WriteString("\n\n")
+
+	renderField := func(field int, label, value, hint string) {
+		if m.focusedField == field {
+			content.WriteString(focusedLabelStyle.Render(label))
+			content.WriteString("  ")
+			content.WriteString(focusedValueStyle.Render(value))
+		} else {
+			content.WriteString(labelStyle.Render(label))
+			content.WriteString("  ")
+			content.WriteString(valueStyle.Render(value))
+		}
+		content.WriteString("\n")
+		content.WriteString(hintStyle.Render(hint))
+		content.WriteString("\n\n")
+	}
+
+	// Hyprlang write mode
+	value, hint := m.renderHyprlangMode()
+	renderField(settingsFieldHyprlangMode, "Save rules to:", value, hint)
+
+	// Rule syntax
+	value, hint = m.renderMonitorSyntax()
+	renderField(settingsFieldMonitorSyntax, "Rule syntax:", value, hint)
+
+	// Backup location
+	value, hint = m.renderBackupLocation()
+	renderField(settingsFieldBackupLocation, "Backups in:", value, hint)
+
+	// Backup retention
+	value, hint = m.renderBackupKeep()
+	renderField(settingsFieldBackupKeep, "Keep backups:", value, hint)
+	value, hint = m.renderBackupKeepDays()
+	renderField(settingsFieldBackupKeepDays, "Keep for:", value, hint)
+
+	// Workspace migration
+	value, hint = m.renderWorkspaceMigration()
+	renderField(settingsFieldWorkspaceMigration, "Workspaces to:", value, hint)
+
+	// Controls
+	controlsStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241")).
+		MarginTop(1)
+
+	controls := "[Tab/↑↓] Navigate  [Space/←→] Change\n[Enter] Save  [Esc] Cancel"
+	content.WriteString(controlsStyle.Render(controls))
+
+	dialog := dialogStyle.Render(content.String())
+
+	// Center the dialog
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, dialog)
+}
+
+// renderHyprlangMode returns the toggle and a line explaining the current
+// choice.
+func (m settingsDialogModel) renderHyprlangMode() (string, string) {
+	if getHyprlangMode(&m.settings) == hyprlangModeSidecar {
+		return "○ hyprland.conf  ● hyprmon.conf",
+			"Rules go to hyprmon.conf, included with source = ./hyprmon.conf"
+	}
+	return "● hyprland.conf  ○ hyprmon.conf",
+		"Rules go to a # BEGIN/END hyprmon block in hyprland.conf"
+}
+
+func (m settingsDialogModel) renderMonitorSyntax() (string, string) {
+	if getMonitorSyntax(&m.settings) == monitorSyntaxV2 {
+		return "○ monitor=  ● monitorv2 { }",
+			"Block syntax with HDR luminance fields; needs a recent Hyprland"
+	}
+	return "● monitor=  ○ monitorv2 { }",
+		"Comma-separated monitor= lines, understood by every Hyprland"
+}
+
+func (m settingsDialogModel) renderBackupLocation() (string, string) {
+	if getBackupLocation(&m.settings) == backupLocationState {
+		return "○ config dir  ● state dir",
+			"Backups go to ~/.local/state/hyprmon/backups"
+	}
+	return "● config dir  ○ state dir",
+		"Backups go next to the config file as <file>.bak.<time>"
+}
+
+func (m settingsDialogModel) renderBackupKeep() (string, string) {
+	if m.settings.BackupKeep <= 0 {
+		return "◀ no limit ▶",
+			"hyprmon backups prune keeps every backup"
+	}
+	return fmt.Sprintf("◀ %d per file ▶", m.settings.BackupKeep),
+		fmt.Sprintf("hyprmon backups prune keeps the newest %d of each file", m.settings.BackupKeep)
+}
+
+func (m settingsDialogModel) renderBackupKeepDays() (string, string) {
+	if m.settings.BackupKeepDays <= 0 {
+		return "◀ no limit ▶",
+			"hyprmon backups prune ignores how old backups are"
+	}
+	return fmt.Sprintf("◀ %d days ▶", m.settings.BackupKeepDays),
+		fmt.Sprintf("hyprmon backups prune deletes backups older than %d days", m.settings.BackupKeepDays)
+}
+
+func (m settingsDialogModel) renderWorkspaceMigration() (string, string) {
+	switch getWorkspaceMigration(&m.settings) {
+	case workspaceMigrationPrimary:
+		return "○ focused  ● primary  ○ nearest",
+			"Workspaces of a removed monitor go to the primary monitor"
+	case workspaceMigrationNearest:
+		return "○ focused  ○ primary  ● nearest",
+			"Workspaces of a removed monitor go to the monitor next to it"
+	}
+	return "● focused  ○ primary  ○ nearest",
+		"Workspaces of a removed monitor go to the focused monitor"
+}
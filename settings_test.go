@@ -4,13 +4,12 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
 )
 
 func TestSettingsLoadSaveRoundTrip(t *testing.T) {
-	tmp := t.TempDir()
-	orig := customConfigPath
-	customConfigPath = tmp
-	t.Cleanup(func() { customConfigPath = orig })
+	tmp := useTempConfigDir(t)
 
 	// Missing file returns empty settings, no error.
 	s, err := loadSettings()
@@ -55,10 +54,7 @@ func TestSettingsLoadSaveRoundTrip(t *testing.T) {
 }
 
 func TestSettingsSaveAtomic(t *testing.T) {
-	tmp := t.TempDir()
-	orig := customConfigPath
-	customConfigPath = tmp
-	t.Cleanup(func() { customConfigPath = orig })
+	tmp := useTempConfigDir(t)
 
 	s := &Settings{}
 	setMonitorPref(s, "Foo/Bar/123", MonitorPref{UseDescFormat: true})
@@ -77,3 +73,26 @@ func TestSettingsSaveAtomic(t *testing.T) {
 		}
 	}
 }
+
+func TestSettingsDialogStepsBackupRetention(t *testing.T) {
+	m := newSettingsDialog(&Settings{BackupKeepDays: 10}, 80, 40)
+	m.focusedField = settingsFieldBackupKeep
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRight})
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRight})
+	if m.settings.BackupKeep != 5 {
+		t.Errorf("BackupKeep = %d, want 5 after two steps up", m.settings.BackupKeep)
+	}
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyLeft})
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyLeft})
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyLeft})
+	if m.settings.BackupKeep != 0 {
+		t.Errorf("BackupKeep = %d, want 0 (no limit) at the bottom", m.settings.BackupKeep)
+	}
+
+	// A value set by hand moves to the neighbouring step
+	m.focusedField = settingsFieldBackupKeepDays
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRight})
+	if m.settings.BackupKeepDays != 14 {
+		t.Errorf("BackupKeepDays = %d, want 14 after stepping up from 10", m.settings.BackupKeepDays)
+	}
+}
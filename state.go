@@ -0,0 +1,240 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// rollbackStateFile holds the layout that was live before the last apply.
+	rollbackStateFile = "rollback.json"
+
+	// writtenStateFile maps each file hyprmon manages a block in to a hash
+	// of the block it last wrote there.
+	writtenStateFile = "written.json"
+
+	// workspaceHomesStateFile maps workspaces moved off a monitor that went
+	// away to that monitor, so they can go back when it returns.
+	workspaceHomesStateFile = "workspaces.json"
+
+	// lidPanelsStateFile holds the internal panels hyprmon turned off when
+	// the lid closed, with the settings to turn them back on with.
+	lidPanelsStateFile = "lid.json"
+)
+
+// RollbackState is the on-disk snapshot used by `hyprmon --revert`. Monitors
+// keep their HardwareIDs so the snapshot can be restored after connector
+// names have changed.
+type RollbackState struct {
+	SavedAt  time.Time `json:"saved_at"`
+	Monitors []Monitor `json:"monitors"`
+}
+
+// getStateDir returns the directory for runtime state that should survive
+// restarts but isn't configuration: <cfg>/state when -cfg is set, otherwise
+// $XDG_STATE_HOME/hyprmon (default ~/.local/state/hyprmon).
+func getStateDir() string {
+	if customConfigPath != "" {
+		return filepath.Join(customConfigPath, "state")
+	}
+	if xdg := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(xdg) {
+		return filepath.Join(xdg, "hyprmon")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".local", "state", "hyprmon")
+}
+
+// saveRollbackState writes the pre-apply snapshot to the state directory.
+func saveRollbackState(monitors []Monitor) error {
+	dir := getStateDir()
+	if dir == "" {
+		return fmt.Errorf("could not determine state directory")
+	}
+	if err := os.MkdirAll(dir, profileDirMode); err != nil {
+		return fmt.Errorf("failed to ensure state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(RollbackState{
+		SavedAt:  time.Now(),
+		Monitors: monitors,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal rollback state: %w", err)
+	}
+	return writeFileAtomic(filepath.Join(dir, rollbackStateFile), data)
+}
+
+// loadRollbackState reads the snapshot written by saveRollbackState. A
+// missing file returns (nil, nil).
+func loadRollbackState() (*RollbackState, error) {
+	dir := getStateDir()
+	if dir == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Join(dir, rollbackStateFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read rollback state: %w", err)
+	}
+	var state RollbackState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse rollback state: %w", err)
+	}
+	return &state, nil
+}
+
+// contentHash returns a hex SHA-256 of s.
+func contentHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func loadWrittenHashes() (map[string]string, error) {
+	hashes := make(map[string]string)
+	dir := getStateDir()
+	if dir == "" {
+		return hashes, nil
+	}
+	data, err := os.ReadFile(filepath.Join(dir, writtenStateFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return hashes, nil
+		}
+		return nil, fmt.Errorf("failed to read written state: %w", err)
+	}
+	if err := json.Unmarshal(data, &hashes); err != nil {
+		return nil, fmt.Errorf("failed to parse written state: %w", err)
+	}
+	return hashes, nil
+}
+
+// loadWrittenHash returns the hash recorded for path, or "" if there is
+// none.
+func loadWrittenHash(path string) (string, error) {
+	hashes, err := loadWrittenHashes()
+	if err != nil {
+		return "", err
+	}
+	return hashes[path], nil
+}
+
+// recordWrittenHash remembers the hash of the block just written to path.
+func recordWrittenHash(path, hash string) error {
+	dir := getStateDir()
+	if dir == "" {
+		return fmt.Errorf("could not determine state directory")
+	}
+	if err := os.MkdirAll(dir, profileDirMode); err != nil {
+		return fmt.Errorf("failed to ensure state directory: %w", err)
+	}
+
+	hashes, err := loadWrittenHashes()
+	if err != nil {
+		// A corrupt file only loses the edit detection; start over
+		hashes = make(map[string]string)
+	}
+	hashes[path] = hash
+
+	data, err := json.MarshalIndent(hashes, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal written state: %w", err)
+	}
+	return writeFileAtomic(filepath.Join(dir, writtenStateFile), data)
+}
+
+// loadWorkspaceHomes returns the workspace selector -> monitor key map
+// saved by saveWorkspaceHomes. A missing file is an empty map.
+func loadWorkspaceHomes() (map[string]string, error) {
+	homes := make(map[string]string)
+	dir := getStateDir()
+	if dir == "" {
+		return homes, nil
+	}
+	data, err := os.ReadFile(filepath.Join(dir, workspaceHomesStateFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return homes, nil
+		}
+		return nil, fmt.Errorf("failed to read workspace state: %w", err)
+	}
+	if err := json.Unmarshal(data, &homes); err != nil {
+		return nil, fmt.Errorf("failed to parse workspace state: %w", err)
+	}
+	if homes == nil {
+		homes = make(map[string]string) // the file held null
+	}
+	return homes, nil
+}
+
+// saveWorkspaceHomes replaces the remembered homes of displaced workspaces.
+func saveWorkspaceHomes(homes map[string]string) error {
+	dir := getStateDir()
+	if dir == "" {
+		return fmt.Errorf("could not determine state directory")
+	}
+	if err := os.MkdirAll(dir, profileDirMode); err != nil {
+		return fmt.Errorf("failed to ensure state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(homes, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal workspace state: %w", err)
+	}
+	return writeFileAtomic(filepath.Join(dir, workspaceHomesStateFile), data)
+}
+
+// loadLidPanels returns the panels saved by saveLidPanels. A missing file
+// returns nil.
+func loadLidPanels() ([]Monitor, error) {
+	dir := getStateDir()
+	if dir == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Join(dir, lidPanelsStateFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read lid state: %w", err)
+	}
+	var panels []Monitor
+	if err := json.Unmarshal(data, &panels); err != nil {
+		return nil, fmt.Errorf("failed to parse lid state: %w", err)
+	}
+	return panels, nil
+}
+
+// saveLidPanels replaces the panels turned off for a closed lid. Saving
+// none removes the file.
+func saveLidPanels(panels []Monitor) error {
+	dir := getStateDir()
+	if dir == "" {
+		return fmt.Errorf("could not determine state directory")
+	}
+	path := filepath.Join(dir, lidPanelsStateFile)
+	if len(panels) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to clear lid state: %w", err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(dir, profileDirMode); err != nil {
+		return fmt.Errorf("failed to ensure state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(panels, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal lid state: %w", err)
+	}
+	return writeFileAtomic(filepath.Join(dir, lidPanelsStateFile), data)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGetStateDir(t *testing.T) {
+	orig := customConfigPath
+	t.Cleanup(func() { customConfigPath = orig })
+
+	customConfigPath = "/tmp/hyprmon-cfg"
+	if got, want := getStateDir(), filepath.Join("/tmp/hyprmon-cfg", "state"); got != want {
+		t.Errorf("getStateDir() with -cfg = %q, want %q", got, want)
+	}
+
+	customConfigPath = ""
+	t.Setenv("XDG_STATE_HOME", "/tmp/xdg-state")
+	if got, want := getStateDir(), filepath.Join("/tmp/xdg-state", "hyprmon"); got != want {
+		t.Errorf("getStateDir() with XDG_STATE_HOME = %q, want %q", got, want)
+	}
+
+	// Relative XDG paths are invalid per the spec and must be ignored.
+	t.Setenv("XDG_STATE_HOME", "relative")
+	t.Setenv("HOME", "/home/test")
+	if got, want := getStateDir(), filepath.Join("/home/test", ".local", "state", "hyprmon"); got != want {
+		t.Errorf("getStateDir() fallback = %q, want %q", got, want)
+	}
+}
+
+func TestRollbackStateRoundTrip(t *testing.T) {
+	useTempConfigDir(t)
+
+	state, err := loadRollbackState()
+	if err != nil || state != nil {
+		t.Fatalf("loadRollbackState() with no file = (%v, %v), want (nil, nil)", state, err)
+	}
+
+	monitors := []Monitor{{
+		Name: "DP-1", HardwareID: "Dell Inc./U2720Q/ABC",
+		PxW: 2560, PxH: 1440, Hz: 60, Scale: 1.25, X: 1920, Active: true,
+	}}
+	if err := saveRollbackState(monitors); err != nil {
+		t.Fatalf("saveRollbackState() error = %v", err)
+	}
+
+	state, err = loadRollbackState()
+	if err != nil {
+		t.Fatalf("loadRollbackState() error = %v", err)
+	}
+	if len(state.Monitors) != 1 || state.Monitors[0].HardwareID != "Dell Inc./U2720Q/ABC" || state.Monitors[0].Scale != 1.25 {
+		t.Errorf("loadRollbackState() monitors = %+v", state.Monitors)
+	}
+	if state.SavedAt.IsZero() {
+		t.Error("SavedAt not recorded")
+	}
+}
+
+func TestRevertToSavedUsesStateFile(t *testing.T) {
+	// The monitor is now on DP-3; the snapshot was taken when it was DP-1.
+	fake := startFakeHyprland(t, func(request string) string {
+		switch request {
+		case "j/monitors all":
+			return `[{"name":"DP-3","make":"Dell Inc.","model":"U2720Q","serial":"ABC","width":2560,"height":1440,"refreshRate":60,"scale":2,"x":0,"y":0}]`
+		case "j/workspaces":
+			return `[]`
+		}
+		return "ok"
+	})
+	useTempConfigDir(t)
+	origPrevious := previousMonitors
+	t.Cleanup(func() { previousMonitors = origPrevious })
+	configPath := filepath.Join(t.TempDir(), "hyprland.conf")
+	if err := os.WriteFile(configPath, []byte("monitor=DP-3,2560x1440@60,0x0,2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HYPRLAND_CONFIG", configPath)
+
+	previous := []Monitor{{
+		Name: "DP-1", HardwareID: "Dell Inc./U2720Q/ABC",
+		PxW: 2560, PxH: 1440, Hz: 60, Scale: 1, Active: true,
+	}}
+	if err := saveRollbackState(previous); err != nil {
+		t.Fatal(err)
+	}
+	// The restored layout is a profile's, whose workspace rules stay
+	if err := saveProfile("desk", previous); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveProfileWorkspaces("desk", []WorkspaceRule{{Workspace: "1", HardwareID: "Dell Inc./U2720Q/ABC"}}); err != nil {
+		t.Fatal(err)
+	}
+	// Simulate a fresh process: nothing in memory.
+	previousMonitors = nil
+
+	if err := revertToSaved(); err != nil {
+		t.Fatalf("revertToSaved() error = %v", err)
+	}
+
+	var keyword string
+	reloaded := false
+	for _, request := range fake.Requests() {
+		if strings.HasPrefix(request, "keyword monitor ") {
+			keyword = request
+		}
+		if request == "reload" {
+			reloaded = true
+		}
+	}
+	if !strings.HasPrefix(keyword, "keyword monitor DP-3,") || !strings.Contains(keyword, ",1.00") {
+		t.Errorf("keyword request = %q, want DP-3 restored to scale 1", keyword)
+	}
+	if !reloaded {
+		t.Error("revertToSaved() did not reload the config")
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "monitor=DP-3,2560x1440@60.00,0x0,1.00") {
+		t.Errorf("config not reverted, got:\n%s", data)
+	}
+	if !strings.Contains(string(data), "workspace=1,monitor:DP-3\n") {
+		t.Errorf("workspace rules dropped by the revert, got:\n%s", data)
+	}
+}
+
+func TestRollbackWithoutState(t *testing.T) {
+	startFakeHyprland(t, func(string) string { return "[]" })
+	useTempConfigDir(t)
+	origPrevious := previousMonitors
+	t.Cleanup(func() { previousMonitors = origPrevious })
+	previousMonitors = nil
+
+	if _, err := rollback(); err == nil || !strings.Contains(err.Error(), "no previous state") {
+		t.Fatalf("rollback() error = %v, want no previous state", err)
+	}
+}
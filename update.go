@@ -1,8 +1,11 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"math"
+	"path/filepath"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -16,6 +19,11 @@ func (m model) Init() tea.Cmd {
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	// Handle the keep-or-revert prompt before anything else
+	if m.ShowConfirmRevert {
+		return m.updateConfirmRevert(msg)
+	}
+
 	// Handle help screen if it's shown
 	if m.ShowHelp {
 		switch msg := msg.(type) {
@@ -89,7 +97,11 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	if m.ShowProfileInput {
 		switch msg := msg.(type) {
 		case profileSaveMsg:
-			if err := saveProfile(msg.name, m.Monitors); err != nil {
+			err := saveProfile(msg.name, m.Monitors)
+			if err == nil {
+				err = saveProfileWorkspaces(msg.name, m.Workspaces)
+			}
+			if err != nil {
 				m.Status = fmt.Sprintf("Failed to save profile: %v", err)
 			} else {
 				m.Status = fmt.Sprintf("Profile '%s' saved", msg.name)
@@ -121,7 +133,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		switch msg := msg.(type) {
 		case scaleSelectedMsg:
 			if m.Selected >= 0 && m.Selected < len(m.Monitors) {
+				m.beginEdit("scaled " + m.selectedName())
 				m.Monitors[m.Selected].Scale = msg.scale
+				m.commitEdit()
 				m.Status = fmt.Sprintf("Scale set to %.2fx", msg.scale)
 			}
 			m.ShowScalePicker = false
@@ -150,9 +164,11 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		switch msg := msg.(type) {
 		case modeSelectedMsg:
 			if m.Selected >= 0 && m.Selected < len(m.Monitors) {
+				m.beginEdit("changed mode of " + m.selectedName())
 				m.Monitors[m.Selected].PxW = msg.mode.Width
 				m.Monitors[m.Selected].PxH = msg.mode.Height
 				m.Monitors[m.Selected].Hz = msg.mode.RefreshRate
+				m.commitEdit()
 				m.Status = fmt.Sprintf("Mode set to %dx%d@%.2fHz", msg.mode.Width, msg.mode.Height, msg.mode.RefreshRate)
 			}
 			m.ShowModePicker = false
@@ -181,6 +197,8 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		switch msg := msg.(type) {
 		case mirrorSelectedMsg:
 			if m.Selected >= 0 && m.Selected < len(m.Monitors) {
+				m.beginEdit("changed mirroring of " + m.selectedName())
+
 				// Update mirror settings
 				mon := &m.Monitors[m.Selected]
 
@@ -220,6 +238,8 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.Status = fmt.Sprintf("Mirroring %s to %s", mon.Name, msg.source)
 				}
 
+				m.commitEdit()
+
 				// Check for configuration warnings
 				warnings := validateMirrorConfiguration(m.Monitors)
 				if len(warnings) > 0 {
@@ -255,11 +275,13 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "enter":
 				// Apply settings and close dialog
 				m.ShowAdvancedSettings = false
+				m.commitEdit()
 				m.Status = "Advanced settings applied"
 				return m, nil
 			case "esc":
 				// Cancel and close dialog
 				m.ShowAdvancedSettings = false
+				m.commitEdit()
 				m.Status = "Advanced settings cancelled"
 				return m, nil
 			case "ctrl+c":
@@ -274,6 +296,58 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, cmd
 	}
 
+	// Handle global settings dialog if it's shown
+	if m.ShowSettings {
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			switch msg.String() {
+			case "enter":
+				m.ShowSettings = false
+				if err := saveSettings(&m.SettingsDialog.settings); err != nil {
+					m.Status = fmt.Sprintf("Failed to save settings: %v", err)
+				} else {
+					m.Status = "Settings saved"
+				}
+				return m, nil
+			case "esc":
+				m.ShowSettings = false
+				m.Status = "Settings unchanged"
+				return m, nil
+			case "ctrl+c":
+				// Allow force quitting
+				return m, tea.Quit
+			}
+		}
+
+		newDialog, cmd := m.SettingsDialog.Update(msg)
+		m.SettingsDialog = newDialog
+		return m, cmd
+	}
+
+	// Handle workspace rule editor if it's shown
+	if m.ShowWorkspaceEditor {
+		switch msg := msg.(type) {
+		case workspaceEditorSaveMsg:
+			m.ShowWorkspaceEditor = false
+			m.Workspaces = msg.rules
+			m.Status = fmt.Sprintf("%d workspace rules set; S saves them, P stores them in a profile", len(msg.rules))
+			return m, nil
+		case workspaceEditorCancelMsg:
+			m.ShowWorkspaceEditor = false
+			m.Status = "Workspace rules unchanged"
+			return m, nil
+		case tea.KeyMsg:
+			if msg.String() == "ctrl+c" {
+				// Allow force quitting
+				return m, tea.Quit
+			}
+		}
+
+		newEditor, cmd := m.WorkspaceEditor.Update(msg)
+		m.WorkspaceEditor = newEditor
+		return m, cmd
+	}
+
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.World.TermW = msg.Width
@@ -285,10 +359,16 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.Status = fmt.Sprintf("Error: %v", msg.err)
 		} else {
 			m.Monitors = msg.monitors
+			m.Workspaces = msg.workspaces
+			// Reloaded monitors replace the layout being edited
+			m.History = layoutHistory{}
 			if len(m.Monitors) > 0 {
 				m.Selected = 0
 			}
 			m.Status = fmt.Sprintf("Loaded %d monitors", len(m.Monitors))
+			if m.DryRun {
+				m.Status += " (dry run: A/S print the changes and exit)"
+			}
 			m.updateWorld()
 
 			// Store current monitor names for tracking
@@ -311,15 +391,31 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case applyMsg:
 		if msg.success {
-			m.Status = "Changes applied successfully"
-		} else {
-			m.Status = fmt.Sprintf("Failed to apply: %v", msg.err)
+			m.ApplyDrift = msg.drifts
+			m.Status = "Changes applied, waiting for confirmation"
+			if len(msg.drifts) > 0 {
+				m.Status = "Applied with differences: " + formatDrift(msg.drifts)
+			}
+			if msg.workspaceErr != nil {
+				m.Status = fmt.Sprintf("%s, but %v", m.Status, msg.workspaceErr)
+			}
+			m.Status = hookStatus(m.Status, msg.hookOutput, msg.hookErr)
+			return m, m.startConfirmRevert()
 		}
+		m.Status = fmt.Sprintf("Failed to apply: %v", msg.err)
 		return m, nil
 
 	case saveMsg:
+		var edited *configEditedError
 		if msg.success {
 			m.Status = "Configuration saved"
+			if msg.path != "" {
+				m.Status = "Configuration saved to " + displayConfigPath(msg.path)
+			}
+			m.Status = hookStatus(m.Status, msg.hookOutput, msg.hookErr)
+		} else if errors.As(msg.err, &edited) {
+			m.SaveConflict = edited.Path
+			m.Status = fmt.Sprintf("%s has hand edits inside the hyprmon block: [o] overwrite  [m] merge (keep rules for other monitors)  [any other key] abort", filepath.Base(edited.Path))
 		} else {
 			m.Status = fmt.Sprintf("Failed to save: %v", msg.err)
 		}
@@ -327,7 +423,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case revertMsg:
 		if msg.success {
-			m.Status = "Reverted to previous configuration"
+			m.Status = hookStatus("Reverted to previous configuration", msg.hookOutput, msg.hookErr)
 		} else {
 			m.Status = fmt.Sprintf("Failed to revert: %v", msg.err)
 		}
@@ -356,7 +452,9 @@ func (m model) handleMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
 			hit := m.hitTest(msg.X, msg.Y-2)
 			if hit >= 0 {
 				if m.canDisableMonitor(hit) {
+					m.beginEdit(map[bool]string{true: "disabled ", false: "enabled "}[m.Monitors[hit].Active] + m.Monitors[hit].Name)
 					m.Monitors[hit].Active = !m.Monitors[hit].Active
+					m.commitEdit()
 					m.Status = fmt.Sprintf("Monitor %s: %s",
 						m.Monitors[hit].Name,
 						map[bool]string{true: "Active", false: "Inactive"}[m.Monitors[hit].Active])
@@ -366,16 +464,20 @@ func (m model) handleMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
 			}
 		case tea.MouseButtonWheelUp:
 			if m.Selected >= 0 && m.Selected < len(m.Monitors) {
+				m.beginEdit("scaled " + m.selectedName())
 				mon := &m.Monitors[m.Selected]
 				delta := float32(0.05)
 				mon.Scale = clamp(mon.Scale+delta, 0.5, 3.0)
+				m.commitEdit()
 				m.Status = fmt.Sprintf("Scale: %.2f", mon.Scale)
 			}
 		case tea.MouseButtonWheelDown:
 			if m.Selected >= 0 && m.Selected < len(m.Monitors) {
+				m.beginEdit("scaled " + m.selectedName())
 				mon := &m.Monitors[m.Selected]
 				delta := float32(0.05)
 				mon.Scale = clamp(mon.Scale-delta, 0.5, 3.0)
+				m.commitEdit()
 				m.Status = fmt.Sprintf("Scale: %.2f", mon.Scale)
 			}
 		}
@@ -393,6 +495,20 @@ func (m model) handleMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
 }
 
 func (m model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	// A save stopped by hand edits waits for overwrite, merge or abort
+	if m.SaveConflict != "" {
+		path := filepath.Base(m.SaveConflict)
+		m.SaveConflict = ""
+		switch msg.String() {
+		case "o", "O":
+			return m, saveCmd(m.Monitors, m.Workspaces, conflictOverwrite)
+		case "m", "M":
+			return m, saveCmd(m.Monitors, m.Workspaces, conflictMerge)
+		}
+		m.Status = fmt.Sprintf("Save aborted; %s was not changed", path)
+		return m, nil
+	}
+
 	switch msg.String() {
 	case "?":
 		m.ShowHelp = true
@@ -498,24 +614,52 @@ func (m model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			if mon.SDRSaturation == 0 {
 				mon.SDRSaturation = 1.0
 			}
+			// Everything changed while the dialog is open is one undo step
+			m.beginEdit("changed settings of " + mon.Name)
 			m.AdvancedSettings = newAdvancedSettingsModel(mon)
 			m.ShowAdvancedSettings = true
 		}
 
+	case ",":
+		// Open global settings dialog
+		s, err := loadSettings()
+		if err != nil {
+			m.Status = fmt.Sprintf("Error: %v", err)
+			return m, nil
+		}
+		m.SettingsDialog = newSettingsDialog(s, m.World.TermW, m.World.TermH)
+		m.ShowSettings = true
+
 	case "a", "A":
-		saveRollback(m.Monitors)
-		return m, applyCmd(m.Monitors)
+		if m.DryRun {
+			return m.quitWithDryRun(true, false)
+		}
+		return m, applyCmd(m.Monitors, m.Workspaces)
 
 	case "s", "S":
-		return m, saveCmd(m.Monitors)
+		if m.DryRun {
+			return m.quitWithDryRun(false, true)
+		}
+		return m, saveCmd(m.Monitors, m.Workspaces, conflictAsk)
 
 	case "z", "Z":
 		return m, revertCmd()
 
+	case "u", "U", "ctrl+z":
+		m.undo()
+
+	case "ctrl+y":
+		m.redo()
+
 	case "ctrl+r":
 		m.Status = "Reloading monitors..."
 		return m, reloadMonitorsCmd()
 
+	case "w", "W":
+		// Open workspace rule editor
+		m.WorkspaceEditor = newWorkspaceEditor("current layout", m.Workspaces, m.Monitors, m.World.TermW, m.World.TermH)
+		m.ShowWorkspaceEditor = true
+
 	case "o", "O":
 		// Open profiles page
 		m.OpenProfiles = true
@@ -529,7 +673,9 @@ func (m model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "enter", " ":
 		if m.Selected >= 0 && m.Selected < len(m.Monitors) {
 			if m.canDisableMonitor(m.Selected) {
+				m.beginEdit(map[bool]string{true: "disabled ", false: "enabled "}[m.Monitors[m.Selected].Active] + m.selectedName())
 				m.Monitors[m.Selected].Active = !m.Monitors[m.Selected].Active
+				m.commitEdit()
 				m.Status = fmt.Sprintf("Monitor %s: %s",
 					m.Monitors[m.Selected].Name,
 					map[bool]string{true: "Active", false: "Inactive"}[m.Monitors[m.Selected].Active])
@@ -542,6 +688,18 @@ func (m model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// quitWithDryRun exits the TUI with the plan for an apply or save, which
+// main prints once the terminal has been restored.
+func (m model) quitWithDryRun(apply, save bool) (tea.Model, tea.Cmd) {
+	plan, err := planDryRun(m.Monitors, m.Workspaces, apply, save)
+	if err != nil {
+		m.Status = fmt.Sprintf("Dry run failed: %v", err)
+		return m, nil
+	}
+	m.DryRunOutput = plan.String()
+	return m, tea.Quit
+}
+
 func clamp(v, min, max float32) float32 {
 	return float32(math.Max(float64(min), math.Min(float64(max), float64(v))))
 }
@@ -549,54 +707,111 @@ func clamp(v, min, max float32) float32 {
 func loadMonitorsCmd() tea.Cmd {
 	return func() tea.Msg {
 		monitors, err := readMonitors()
-		return initMsg{monitors: monitors, err: err}
+		return initMsg{monitors: monitors, workspaces: activeProfileWorkspaces(monitors), err: err}
 	}
 }
 
 func reloadMonitorsCmd() tea.Cmd {
 	return func() tea.Msg {
 		monitors, err := readMonitors()
-		return initMsg{monitors: monitors, err: err}
+		return initMsg{monitors: monitors, workspaces: activeProfileWorkspaces(monitors), err: err}
 	}
 }
 
-func applyCmd(monitors []Monitor) tea.Cmd {
+func applyCmd(monitors []Monitor, workspaces []WorkspaceRule) tea.Cmd {
 	return func() tea.Msg {
-		// Get current monitor names before applying changes
-		previousNames, _ := getCurrentMonitorNames()
-
-		// Apply the monitor configuration
-		err := applyMonitors(monitors)
+		// Remember the live layout so it can be restored if the user
+		// doesn't confirm the new one
+		live, err := readMonitors()
 		if err != nil {
-			return applyMsg{success: false, err: err}
+			return applyMsg{success: false, err: fmt.Errorf("failed to read current monitors: %w", err)}
+		}
+		// The in-memory snapshot still covers this session if the state
+		// file can't be written, so don't block the apply on it
+		_ = saveRollback(live)
+
+		var output bytes.Buffer
+		if err := runHooks(hookPreApply, nil, monitors, &output); err != nil {
+			return applyMsg{success: false, err: err, hookOutput: output.String()}
 		}
 
-		// Get monitor names after applying changes
-		currentNames, _ := getCurrentMonitorNames()
+		// Where the workspaces are, for migrating them afterwards
+		workspacesBefore, _ := readWorkspaces()
+
+		// Apply the monitor configuration
+		if err := applyMonitors(monitors); err != nil {
+			return applyMsg{success: false, err: err, hookOutput: output.String()}
+		}
 
-		// Migrate orphaned workspaces if monitors were removed
-		if err := migrateOrphanedWorkspaces(previousNames, currentNames); err != nil {
-			// Log the error but don't fail the apply operation
-			fmt.Printf("Warning: Failed to migrate workspaces: %v\n", err)
+		// Migrate orphaned workspaces if monitors were removed, and
+		// bring them back to monitors that were enabled again
+		if after, err := readMonitors(); err == nil {
+			if err := migrateOrphanedWorkspaces(live, after, workspacesBefore); err != nil {
+				// Log the error but don't fail the apply operation
+				fmt.Printf("Warning: Failed to migrate workspaces: %v\n", err)
+			}
 		}
+		// Workspace rules are best-effort too; the layout itself applied
+		workspaceErr := applyWorkspaceRules(workspaces, monitors)
+
+		// Report anything Hyprland clamped or ignored; a failed re-read
+		// doesn't make the apply itself fail
+		drifts, _ := verifyApplied(monitors)
 
-		return applyMsg{success: true, err: nil}
+		hookErr := runHooks(hookPostApply, nil, monitors, &output)
+		return applyMsg{success: true, err: nil, drifts: drifts, workspaceErr: workspaceErr, hookOutput: output.String(), hookErr: hookErr}
 	}
 }
 
-func saveCmd(monitors []Monitor) tea.Cmd {
+func saveCmd(monitors []Monitor, workspaces []WorkspaceRule, policy conflictPolicy) tea.Cmd {
 	return func() tea.Msg {
-		err := writeConfig(monitors)
+		// Ask about hand edits first, so the hooks run once per save and
+		// not again when it is retried with overwrite or merge
+		if policy == conflictAsk {
+			if err := checkConfigEdited(monitors); err != nil {
+				return saveMsg{success: false, err: err}
+			}
+		}
+		var output bytes.Buffer
+		if err := runHooks(hookPreApply, nil, monitors, &output); err != nil {
+			return saveMsg{success: false, err: err, hookOutput: output.String()}
+		}
+		err := writeConfigWithPolicy(monitors, workspaces, policy)
 		if err == nil {
 			err = reloadConfig()
 		}
-		return saveMsg{success: err == nil, err: err}
+		// Only used for the status line, so a lookup failure isn't an error
+		path, _ := configRulesPath(monitors)
+		if err != nil {
+			return saveMsg{success: false, err: err, path: path, hookOutput: output.String()}
+		}
+		hookErr := runHooks(hookPostApply, nil, monitors, &output)
+		return saveMsg{success: true, path: path, hookOutput: output.String(), hookErr: hookErr}
 	}
 }
 
+// hookStatus adds what the hooks of an apply or save had to say to its
+// status line: the failure of a post_apply hook, or their last line of
+// output.
+func hookStatus(status, output string, err error) string {
+	if err != nil {
+		return fmt.Sprintf("%s, but %v", status, err)
+	}
+	if line := lastOutputLine(output); line != "" {
+		return fmt.Sprintf("%s (hook: %s)", status, line)
+	}
+	return status
+}
+
 func revertCmd() tea.Cmd {
 	return func() tea.Msg {
-		err := rollback()
-		return revertMsg{success: err == nil, err: err}
+		restored, err := rollback()
+		if err != nil {
+			return revertMsg{success: false, err: err}
+		}
+		// Hooks that reacted to the apply need to hear about the way back too
+		var output bytes.Buffer
+		hookErr := runHooks(hookPostApply, nil, restored, &output)
+		return revertMsg{success: true, hookOutput: output.String(), hookErr: hookErr}
 	}
 }
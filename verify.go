@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// exitApplyDrift is the exit code of `--profile --strict` when Hyprland did
+// not accept every requested setting.
+const exitApplyDrift = 3
+
+const (
+	// verifyPollInterval is how often monitors are re-read while waiting
+	// for Hyprland to finish applying the new rules.
+	verifyPollInterval = 100 * time.Millisecond
+
+	// Hyprland reports measured refresh rates (59.951 for "60") and may
+	// round fractional scales, so small differences are not drift.
+	verifyHzTolerance    = 0.5
+	verifyScaleTolerance = 0.01
+)
+
+// verifySettleTime is how long verifyApplied waits for the requested layout
+// to show up before reporting differences. Hyprland applies monitor rules
+// on its next frame rather than while answering the request.
+var verifySettleTime = time.Second
+
+// monitorDrift is one setting Hyprland did not apply as requested.
+type monitorDrift struct {
+	Monitor   string
+	Field     string
+	Requested string
+	Actual    string
+}
+
+func (d monitorDrift) String() string {
+	return fmt.Sprintf("%s %s: requested %s, got %s", d.Monitor, d.Field, d.Requested, d.Actual)
+}
+
+// formatDrift joins drifts into a single line for the status bar.
+func formatDrift(drifts []monitorDrift) string {
+	parts := make([]string, len(drifts))
+	for i, d := range drifts {
+		parts[i] = d.String()
+	}
+	return strings.Join(parts, "; ")
+}
+
+func mirrorName(m Monitor) string {
+	if m.IsMirrored && m.MirrorSource != "" {
+		return m.MirrorSource
+	}
+	return "none"
+}
+
+// diffMonitors compares requested monitors against what Hyprland reports,
+// matching them by connector name.
+func diffMonitors(requested, actual []Monitor) []monitorDrift {
+	actualByName := make(map[string]Monitor, len(actual))
+	for _, m := range actual {
+		actualByName[m.Name] = m
+	}
+
+	var drifts []monitorDrift
+	add := func(name, field, want, got string) {
+		drifts = append(drifts, monitorDrift{Monitor: name, Field: field, Requested: want, Actual: got})
+	}
+
+	for _, want := range requested {
+		got, ok := actualByName[want.Name]
+		if !ok {
+			add(want.Name, "connection", "connected", "not connected")
+			continue
+		}
+
+		if want.Active != got.Active {
+			state := map[bool]string{true: "enabled", false: "disabled"}
+			add(want.Name, "state", state[want.Active], state[got.Active])
+			continue
+		}
+		if !want.Active {
+			continue
+		}
+
+		if mirrorName(want) != mirrorName(got) {
+			add(want.Name, "mirror", mirrorName(want), mirrorName(got))
+		}
+		// A mirror takes its picture from the source; its own mode and
+		// position are not meaningful.
+		if want.IsMirrored && want.MirrorSource != "" {
+			continue
+		}
+
+		if want.PxW != got.PxW || want.PxH != got.PxH {
+			add(want.Name, "mode", fmt.Sprintf("%dx%d", want.PxW, want.PxH), fmt.Sprintf("%dx%d", got.PxW, got.PxH))
+		}
+		if math.Abs(float64(want.Hz-got.Hz)) > verifyHzTolerance {
+			add(want.Name, "refresh rate", fmt.Sprintf("%.2fHz", want.Hz), fmt.Sprintf("%.2fHz", got.Hz))
+		}
+		if math.Abs(float64(want.Scale-got.Scale)) > verifyScaleTolerance {
+			add(want.Name, "scale", fmt.Sprintf("%.2f", want.Scale), fmt.Sprintf("%.2f", got.Scale))
+		}
+		if want.X != got.X || want.Y != got.Y {
+			add(want.Name, "position", fmt.Sprintf("%dx%d", want.X, want.Y), fmt.Sprintf("%dx%d", got.X, got.Y))
+		}
+		if want.Transform != got.Transform {
+			add(want.Name, "transform", fmt.Sprint(want.Transform), fmt.Sprint(got.Transform))
+		}
+	}
+	return drifts
+}
+
+// verifyApplied re-reads the monitors after an apply and returns the
+// settings Hyprland clamped, replaced or ignored. It polls for up to
+// verifySettleTime so rules that are still being applied aren't reported.
+func verifyApplied(requested []Monitor) ([]monitorDrift, error) {
+	deadline := time.Now().Add(verifySettleTime)
+	for {
+		actual, err := readMonitors()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read monitors after apply: %w", err)
+		}
+		drifts := diffMonitors(requested, actual)
+		if len(drifts) == 0 || !time.Now().Before(deadline) {
+			return drifts, nil
+		}
+		time.Sleep(verifyPollInterval)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// shortVerifySettle keeps tests whose fake Hyprland never reports the
+// requested layout from waiting the full settle time.
+func shortVerifySettle(t *testing.T) {
+	t.Helper()
+	orig := verifySettleTime
+	verifySettleTime = 10 * time.Millisecond
+	t.Cleanup(func() { verifySettleTime = orig })
+}
+
+func TestDiffMonitors(t *testing.T) {
+	base := Monitor{Name: "DP-1", PxW: 2560, PxH: 1440, Hz: 60, Scale: 1.5, X: 0, Y: 0, Active: true}
+
+	tests := []struct {
+		name   string
+		want   Monitor
+		actual Monitor
+		fields []string
+	}{
+		{
+			name:   "Measured refresh rate and rounded scale are accepted",
+			want:   base,
+			actual: func() Monitor { m := base; m.Hz = 59.951; m.Scale = 1.5000001; return m }(),
+		},
+		{
+			name:   "Clamped scale",
+			want:   func() Monitor { m := base; m.Scale = 1.33; return m }(),
+			actual: base,
+			fields: []string{"scale"},
+		},
+		{
+			name:   "Fallback mode",
+			want:   func() Monitor { m := base; m.PxW, m.PxH, m.Hz = 3840, 2160, 144; return m }(),
+			actual: base,
+			fields: []string{"mode", "refresh rate"},
+		},
+		{
+			name:   "Moved and rotated",
+			want:   func() Monitor { m := base; m.X, m.Transform = 1920, 1; return m }(),
+			actual: base,
+			fields: []string{"position", "transform"},
+		},
+		{
+			name:   "Mirror ignored",
+			want:   func() Monitor { m := base; m.IsMirrored, m.MirrorSource = true, "eDP-1"; return m }(),
+			actual: func() Monitor { m := base; m.X = 4000; return m }(),
+			fields: []string{"mirror"},
+		},
+		{
+			name:   "Still enabled",
+			want:   func() Monitor { m := base; m.Active = false; return m }(),
+			actual: base,
+			fields: []string{"state"},
+		},
+		{
+			name:   "Disabled monitor settings are not compared",
+			want:   func() Monitor { m := base; m.Active = false; m.Scale = 2; return m }(),
+			actual: func() Monitor { m := base; m.Active = false; return m }(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drifts := diffMonitors([]Monitor{tt.want}, []Monitor{tt.actual})
+			var fields []string
+			for _, d := range drifts {
+				fields = append(fields, d.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.fields, ",") {
+				t.Errorf("diffMonitors() fields = %v, want %v (%s)", fields, tt.fields, formatDrift(drifts))
+			}
+		})
+	}
+}
+
+func TestDiffMonitorsReportsUnplugged(t *testing.T) {
+	drifts := diffMonitors([]Monitor{{Name: "HDMI-A-1", Active: true}}, nil)
+	if len(drifts) != 1 || drifts[0].String() != "HDMI-A-1 connection: requested connected, got not connected" {
+		t.Fatalf("diffMonitors() = %v", drifts)
+	}
+}
+
+func TestVerifyAppliedWaitsForSettle(t *testing.T) {
+	var reads atomic.Int32
+	startFakeHyprland(t, func(request string) string {
+		if request != "j/monitors all" {
+			return "ok"
+		}
+		// The first read still shows the old scale, as if Hyprland hadn't
+		// processed the rule yet.
+		if reads.Add(1) == 1 {
+			return `[{"id":0,"name":"DP-1","width":2560,"height":1440,"refreshRate":60,"scale":1,"x":0,"y":0}]`
+		}
+		return `[{"id":0,"name":"DP-1","width":2560,"height":1440,"refreshRate":59.95,"scale":1.5,"x":0,"y":0}]`
+	})
+
+	drifts, err := verifyApplied([]Monitor{{Name: "DP-1", PxW: 2560, PxH: 1440, Hz: 60, Scale: 1.5, Active: true}})
+	if err != nil {
+		t.Fatalf("verifyApplied() error = %v", err)
+	}
+	if len(drifts) != 0 {
+		t.Fatalf("verifyApplied() = %v, want no drift once settled", drifts)
+	}
+}
+
+func TestVerifyAppliedReportsClampedScale(t *testing.T) {
+	shortVerifySettle(t)
+	startFakeHyprland(t, func(request string) string {
+		return `[{"id":0,"name":"DP-1","width":2560,"height":1440,"refreshRate":60,"scale":1.25,"x":0,"y":0}]`
+	})
+
+	drifts, err := verifyApplied([]Monitor{{Name: "DP-1", PxW: 2560, PxH: 1440, Hz: 60, Scale: 1.33, Active: true}})
+	if err != nil {
+		t.Fatalf("verifyApplied() error = %v", err)
+	}
+	if len(drifts) != 1 || drifts[0].String() != "DP-1 scale: requested 1.33, got 1.25" {
+		t.Fatalf("verifyApplied() = %v", drifts)
+	}
+}
+
+func TestReadMonitorsResolvesMirrorID(t *testing.T) {
+	startFakeHyprland(t, func(string) string {
+		return `[
+			{"id":0,"name":"eDP-1","width":1920,"height":1080,"mirrorOf":"none"},
+			{"id":1,"name":"HDMI-A-1","width":1920,"height":1080,"mirrorOf":"0"}
+		]`
+	})
+
+	monitors, err := readMonitors()
+	if err != nil {
+		t.Fatalf("readMonitors() error = %v", err)
+	}
+	if monitors[1].MirrorSource != "eDP-1" || !monitors[1].IsMirrored {
+		t.Errorf("HDMI-A-1 mirror = %q, want eDP-1", monitors[1].MirrorSource)
+	}
+	if len(monitors[0].MirrorTargets) != 1 || monitors[0].MirrorTargets[0] != "HDMI-A-1" {
+		t.Errorf("eDP-1 mirror targets = %v, want [HDMI-A-1]", monitors[0].MirrorTargets)
+	}
+}
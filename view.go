@@ -42,6 +42,11 @@ var (
 )
 
 func (m model) View() string {
+	// Show keep-or-revert prompt if active
+	if m.ShowConfirmRevert {
+		return m.renderConfirmRevert()
+	}
+
 	// Show help if active
 	if m.ShowHelp {
 		return m.renderHelp()
@@ -72,6 +77,16 @@ func (m model) View() string {
 		return m.AdvancedSettings.View()
 	}
 
+	// Show global settings dialog if active
+	if m.ShowSettings {
+		return m.SettingsDialog.View()
+	}
+
+	// Show workspace rule editor if active
+	if m.ShowWorkspaceEditor {
+		return m.WorkspaceEditor.View()
+	}
+
 	// Allow rendering even with default sizes
 	if m.World.TermW <= 0 {
 		m.World.TermW = 80
@@ -153,10 +168,14 @@ func (m model) renderHelp() string {
 		{"F", "Open mode selection dialog"},
 		{"M", "Open mirror configuration dialog"},
 		{"C/D", "Open advanced display settings"},
-		{"A", "Apply the changes right now (doesn't persist)"},
+		{",", "Open hyprmon settings (where rules are saved)"},
+		{"A", "Apply the changes right now (doesn't persist, reverts unless confirmed)"},
 		{"S", "Save current configuration to Hyprland. Will persist restarts"},
 		{"O", "Open profiles page"},
 		{"P", "Save as profile"},
+		{"W", "Edit workspace rules (which monitor each workspace goes on)"},
+		{"U / Ctrl+Z", "Undo the last layout edit"},
+		{"Ctrl+Y", "Redo the last undone edit"},
 		{"Z", "Revert to previous configuration"},
 		{"Ctrl+R", "Reload monitors from Hyprland"},
 		{"?", "Show this help"},
@@ -635,7 +654,10 @@ func (m model) renderFooter() string {
 		{"S save", "S save", "S", 2},
 		{"O profiles", "O prof", "O", 3},
 		{"P save profile", "P save prof", "P", 3},
-		{"Z revert", "Z undo", "Z", 2},
+		{"W workspaces", "W wksp", "W", 3},
+		{"U undo", "U undo", "U", 2},
+		{"Z revert", "Z revert", "Z", 2},
+		{", settings", ", settings", ",", 3},
 		{"? help", "? help", "? Help", 1},
 		{"Q quit", "Q quit", "Q", 1},
 	}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// workspaceEditorModel edits a list of workspace rules against a set of
+// monitors. It works on a copy and reports the result with a
+// workspaceEditorSaveMsg, or a workspaceEditorCancelMsg when dismissed.
+type workspaceEditorModel struct {
+	title    string
+	rules    []WorkspaceRule
+	monitors []Monitor
+	selected int
+	column   int
+	editing  bool   // typing a workspace ID or name
+	input    string // workspace being typed
+	added    bool   // the rule being typed was just added
+	error    string
+	width    int
+	height   int
+}
+
+const (
+	workspaceColumnWorkspace = iota
+	workspaceColumnMonitor
+	workspaceColumnDefault
+	workspaceColumnPersistent
+	workspaceColumnCount
+)
+
+type workspaceEditorSaveMsg struct {
+	rules []WorkspaceRule
+}
+
+type workspaceEditorCancelMsg struct{}
+
+func newWorkspaceEditor(title string, rules []WorkspaceRule, monitors []Monitor, width, height int) workspaceEditorModel {
+	return workspaceEditorModel{
+		title:    title,
+		rules:    append([]WorkspaceRule(nil), rules...),
+		monitors: monitors,
+		width:    width,
+		height:   height,
+	}
+}
+
+func (m workspaceEditorModel) Update(msg tea.Msg) (workspaceEditorModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+
+	case tea.KeyMsg:
+		if m.editing {
+			m.updateInput(msg)
+			return m, nil
+		}
+
+		switch msg.String() {
+		case "esc":
+			return m, func() tea.Msg { return workspaceEditorCancelMsg{} }
+
+		case "enter":
+			if err := validateWorkspaceRules(m.rules, m.monitors); err != nil {
+				m.error = err.Error()
+				return m, nil
+			}
+			rules := m.rules
+			return m, func() tea.Msg { return workspaceEditorSaveMsg{rules: rules} }
+
+		case "up", "k":
+			if m.selected > 0 {
+				m.selected--
+			}
+
+		case "down", "j":
+			if m.selected < len(m.rules)-1 {
+				m.selected++
+			}
+
+		case "left", "h", "shift+tab":
+			m.column = (m.column - 1 + workspaceColumnCount) % workspaceColumnCount
+
+		case "right", "l", "tab":
+			m.column = (m.column + 1) % workspaceColumnCount
+
+		case "a", "A":
+			if len(m.monitors) == 0 {
+				m.error = "No monitors to assign workspaces to"
+				return m, nil
+			}
+			m.rules = append(m.rules, WorkspaceRule{HardwareID: workspaceRuleKey(m.monitors[0])})
+			m.selected = len(m.rules) - 1
+			m.column = workspaceColumnWorkspace
+			m.editing, m.added, m.input = true, true, ""
+			m.error = ""
+
+		case "x", "X", "delete":
+			if m.selected < len(m.rules) {
+				m.rules = append(m.rules[:m.selected], m.rules[m.selected+1:]...)
+				if m.selected > 0 && m.selected >= len(m.rules) {
+					m.selected--
+				}
+				m.error = ""
+			}
+
+		case " ", "space", "e", "E":
+			if m.selected < len(m.rules) {
+				m.changeValue()
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// changeValue edits the focused cell of the selected rule: the workspace
+// is typed, the monitor cycles through the monitors and flags toggle.
+func (m *workspaceEditorModel) changeValue() {
+	rule := &m.rules[m.selected]
+	switch m.column {
+	case workspaceColumnWorkspace:
+		m.editing, m.added, m.input = true, false, rule.Workspace
+
+	case workspaceColumnMonitor:
+		if len(m.monitors) == 0 {
+			return
+		}
+		next := 0
+		for i, mon := range m.monitors {
+			if workspaceRuleKey(mon) == rule.HardwareID {
+				next = (i + 1) % len(m.monitors)
+				break
+			}
+		}
+		rule.HardwareID = workspaceRuleKey(m.monitors[next])
+
+	case workspaceColumnDefault:
+		rule.Default = !rule.Default
+
+	case workspaceColumnPersistent:
+		rule.Persistent = !rule.Persistent
+	}
+	m.error = ""
+}
+
+// updateInput handles a key while a workspace is being typed.
+func (m *workspaceEditorModel) updateInput(msg tea.KeyMsg) {
+	switch msg.String() {
+	case "enter":
+		ws := strings.TrimSpace(m.input)
+		if !isValidWorkspace(ws) {
+			m.error = "Enter a workspace ID or a name without spaces, commas or quotes"
+			return
+		}
+		m.rules[m.selected].Workspace = ws
+		m.editing, m.added, m.error = false, false, ""
+
+	case "esc":
+		if m.added {
+			m.rules = m.rules[:m.selected]
+			if m.selected > 0 {
+				m.selected--
+			}
+		}
+		m.editing, m.added, m.error = false, false, ""
+
+	case "backspace", "ctrl+h":
+		if m.input != "" {
+			m.input = m.input[:len(m.input)-1]
+		}
+
+	case "ctrl+u":
+		m.input = ""
+
+	default:
+		if len(msg.String()) == 1 && msg.String()[0] >= 32 && msg.String()[0] < 127 {
+			m.input += msg.String()
+			m.error = ""
+		}
+	}
+}
+
+// validateWorkspaceRules checks rules before they are saved: every
+// workspace is named once, and no monitor has two default workspaces.
+func validateWorkspaceRules(rules []WorkspaceRule, monitors []Monitor) error {
+	seen := make(map[string]bool)
+	defaults := make(map[string]string)
+	for _, rule := range rules {
+		if !isValidWorkspace(rule.Workspace) {
+			return fmt.Errorf("invalid workspace %q", rule.Workspace)
+		}
+		selector := workspaceSelector(rule.Workspace)
+		if seen[selector] {
+			return fmt.Errorf("workspace %s has more than one rule", rule.Workspace)
+		}
+		seen[selector] = true
+		if rule.Default {
+			if other, ok := defaults[rule.HardwareID]; ok {
+				return fmt.Errorf("%s has two default workspaces (%s and %s)", workspaceMonitorLabel(rule.HardwareID, monitors), other, rule.Workspace)
+			}
+			defaults[rule.HardwareID] = rule.Workspace
+		}
+	}
+	return nil
+}
+
+// workspaceMonitorLabel names the monitor a rule points at for display.
+func workspaceMonitorLabel(key string, monitors []Monitor) string {
+	for _, mon := range monitors {
+		if workspaceRuleKey(mon) == key {
+			if label := mon.DisplayLabel(); label != mon.Name {
+				return fmt.Sprintf("%s (%s)", label, mon.Name)
+			}
+			return mon.Name
+		}
+	}
+	return key + " (not connected)"
+}
+
+func (m workspaceEditorModel) View() string {
+	if m.width == 0 || m.height == 0 {
+		m.width = 80
+		m.height = 24
+	}
+
+	dialogStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("42")).
+		Padding(1, 2).
+		Width(72)
+
+	titleStyle := lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color("214")).
+		MarginBottom(1)
+
+	headerStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("244"))
+
+	cellStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("252"))
+
+	focusedStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("214")).
+		Bold(true)
+
+	hintStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241"))
+
+	var content strings.Builder
+
+	content.WriteString(titleStyle.Render("Workspace Rules - " + m.title))
+	content.WriteString("\n\n")
+
+	widths := []int{14, 30, 9, 10}
+	header := []string{"Workspace", "Monitor", "Default", "Persistent"}
+	for i, h := range header {
+		content.WriteString(headerStyle.Width(widths[i]).Render(h))
+	}
+	content.WriteString("\n")
+
+	if len(m.rules) == 0 {
+		content.WriteString(hintStyle.Render("No rules yet; workspaces go wherever Hyprland puts them"))
+		content.WriteString("\n")
+	}
+
+	check := map[bool]string{true: "[x]", false: "[ ]"}
+	for i, rule := range m.rules {
+		workspace := rule.Workspace
+		if m.editing && i == m.selected {
+			workspace = m.input + "│"
+		}
+		cells := []string{
+			workspace,
+			workspaceMonitorLabel(rule.HardwareID, m.monitors),
+			check[rule.Default],
+			check[rule.Persistent],
+		}
+		for col, cell := range cells {
+			style := cellStyle
+			if i == m.selected && col == m.column {
+				style = focusedStyle
+			}
+			content.WriteString(style.Width(widths[col]).MaxWidth(widths[col]).Render(cell))
+		}
+		content.WriteString("\n")
+	}
+
+	if m.error != "" {
+		errorStyle := lipgloss.NewStyle().
+			Foreground(lipgloss.Color("9"))
+		content.WriteString("\n")
+		content.WriteString(errorStyle.Render("⚠ " + m.error))
+		content.WriteString("\n")
+	}
+
+	controlsStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("241")).
+		MarginTop(1)
+
+	controls := "[↑↓] Rule  [←→/Tab] Column  [Space] Edit/cycle/toggle\n[A] Add  [X] Remove  [Enter] Save  [Esc] Cancel"
+	if m.editing {
+		controls = "Type a workspace ID (3) or name (web)\n[Enter] Done  [Esc] Cancel"
+	}
+	content.WriteString("\n")
+	content.WriteString(controlsStyle.Render(controls))
+
+	dialog := dialogStyle.Render(content.String())
+
+	// Center the dialog
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, dialog)
+}
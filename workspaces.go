@@ -0,0 +1,400 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// WorkspaceRule pins a workspace to a monitor. Profiles carry them so
+// switching layouts also puts workspaces back on the screens they belong on.
+type WorkspaceRule struct {
+	// Workspace is a workspace ID such as "3" or a workspace name
+	Workspace string `json:"workspace"`
+	// HardwareID identifies the monitor, or holds its connector name for
+	// monitors without one, like monitorKey
+	HardwareID string `json:"hardware_id"`
+	// Default makes it the workspace the monitor opens on
+	Default bool `json:"default,omitempty"`
+	// Persistent keeps the workspace around even when it is empty
+	Persistent bool `json:"persistent,omitempty"`
+}
+
+// isValidWorkspace checks that a workspace ID or name is safe to put in a
+// dispatch request or a config rule.
+func isValidWorkspace(ws string) bool {
+	if ws == "" || len(ws) > 64 {
+		return false
+	}
+	for _, c := range ws {
+		if c <= ' ' || c == 127 || strings.ContainsRune(",;\"'\\#", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// workspaceSelector returns how Hyprland refers to a workspace: its ID,
+// or name:<name> for named workspaces.
+func workspaceSelector(ws string) string {
+	if _, err := strconv.Atoi(ws); err == nil || strings.HasPrefix(ws, "name:") {
+		return ws
+	}
+	return "name:" + ws
+}
+
+// workspaceRuleKey returns the value a WorkspaceRule stores for a monitor.
+func workspaceRuleKey(m Monitor) string {
+	if m.HardwareID != "" {
+		return m.HardwareID
+	}
+	return m.Name
+}
+
+// workspaceRuleMonitor returns the enabled monitor among monitors that a
+// rule points at.
+func workspaceRuleMonitor(rule WorkspaceRule, monitors []Monitor) (Monitor, bool) {
+	for _, m := range monitors {
+		if m.Active && workspaceRuleKey(m) == rule.HardwareID {
+			return m, true
+		}
+	}
+	return Monitor{}, false
+}
+
+// generateWorkspaceRule returns the hyprlang workspace= line for a rule
+// placed on monitor m.
+func generateWorkspaceRule(rule WorkspaceRule, m Monitor) string {
+	if !isValidWorkspace(rule.Workspace) {
+		return fmt.Sprintf("# Invalid workspace: %s", rule.Workspace)
+	}
+	parts := []string{workspaceSelector(rule.Workspace), "monitor:" + resolveMonitorIdentifier(m)}
+	if rule.Default {
+		parts = append(parts, "default:true")
+	}
+	if rule.Persistent {
+		parts = append(parts, "persistent:true")
+	}
+	return "workspace=" + strings.Join(parts, ",")
+}
+
+// generateLuaWorkspaceRule is generateWorkspaceRule for Lua configs.
+func generateLuaWorkspaceRule(rule WorkspaceRule, m Monitor) string {
+	if !isValidWorkspace(rule.Workspace) {
+		return fmt.Sprintf("-- Invalid workspace: %s", rule.Workspace)
+	}
+	fields := []string{
+		fmt.Sprintf("workspace = %s", luaString(workspaceSelector(rule.Workspace))),
+		fmt.Sprintf("monitor = %s", luaString(resolveMonitorIdentifier(m))),
+	}
+	if rule.Default {
+		fields = append(fields, "default = true")
+	}
+	if rule.Persistent {
+		fields = append(fields, "persistent = true")
+	}
+	return fmt.Sprintf("hl.workspace_rule({ %s })", strings.Join(fields, ", "))
+}
+
+// workspaceRuleLines returns the config lines for the rules whose monitor
+// is among monitors and enabled, using generate to format each one.
+func workspaceRuleLines(rules []WorkspaceRule, monitors []Monitor, generate func(WorkspaceRule, Monitor) string) []string {
+	var lines []string
+	for _, rule := range rules {
+		if m, ok := workspaceRuleMonitor(rule, monitors); ok {
+			lines = append(lines, generate(rule, m))
+		}
+	}
+	return lines
+}
+
+// workspaceMoveCommands returns the dispatches that move the existing
+// workspaces in live onto the monitors their rules name. Workspaces that
+// don't exist yet are left to the config rules, which Hyprland applies when
+// it creates them.
+func workspaceMoveCommands(rules []WorkspaceRule, monitors []Monitor, live []hyprWorkspace) []string {
+	var moves []string
+	for _, rule := range rules {
+		if !isValidWorkspace(rule.Workspace) {
+			continue
+		}
+		m, ok := workspaceRuleMonitor(rule, monitors)
+		if !ok || !isValidMonitorName(m.Name) {
+			continue
+		}
+		selector := workspaceSelector(rule.Workspace)
+		for _, ws := range live {
+			if (strconv.Itoa(ws.ID) == selector || "name:"+ws.Name == selector) && ws.Monitor != m.Name {
+				moves = append(moves, fmt.Sprintf("dispatch moveworkspacetomonitor %s %s", selector, m.Name))
+				break
+			}
+		}
+	}
+	return moves
+}
+
+// applyWorkspaceRules moves workspaces onto the monitors their rules name,
+// in one batch.
+func applyWorkspaceRules(rules []WorkspaceRule, monitors []Monitor) error {
+	if len(rules) == 0 {
+		return nil
+	}
+	live, err := readWorkspaces()
+	if err != nil {
+		return fmt.Errorf("failed to read workspaces: %w", err)
+	}
+	if err := hyprBatch(workspaceMoveCommands(rules, monitors, live)); err != nil {
+		return fmt.Errorf("failed to move workspaces: %w", err)
+	}
+	return nil
+}
+
+// activeProfileWorkspaces returns the workspace rules of the saved profile
+// that matches monitors, so the TUI starts with the rules of the layout
+// it shows. Unreadable profiles are skipped.
+func activeProfileWorkspaces(monitors []Monitor) []WorkspaceRule {
+	names, err := listProfiles()
+	if err != nil {
+		return nil
+	}
+	for _, name := range names {
+		profile, err := loadProfile(name)
+		if err != nil {
+			continue
+		}
+		if compareMonitorConfigurations(monitors, profile.Monitors) {
+			return profile.Workspaces
+		}
+	}
+	return nil
+}
+
+// liveWorkspaceSelector returns the selector a live workspace is
+// remembered by: its ID, or name:<name> for named and special workspaces,
+// whose negative IDs change when they are recreated.
+func liveWorkspaceSelector(ws hyprWorkspace) string {
+	if ws.ID > 0 {
+		return strconv.Itoa(ws.ID)
+	}
+	return "name:" + ws.Name
+}
+
+// isMigratableWorkspace reports whether a live workspace can be moved
+// between monitors by hyprmon. Special workspaces (scratchpads) belong to
+// every monitor, and names that aren't safe to dispatch are left alone.
+func isMigratableWorkspace(ws hyprWorkspace) bool {
+	if ws.ID > 0 {
+		return true
+	}
+	return !strings.HasPrefix(ws.Name, "special") && isValidWorkspace(ws.Name)
+}
+
+// monitorBounds returns a monitor's position and logical size, accounting
+// for scale and rotation.
+func monitorBounds(m Monitor) (x, y, w, h int64) {
+	scale := m.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	w = int64(float32(m.PxW) / scale)
+	h = int64(float32(m.PxH) / scale)
+	if m.Transform%2 == 1 {
+		w, h = h, w
+	}
+	return int64(m.X), int64(m.Y), w, h
+}
+
+// monitorDistance returns the squared length of the gap between two
+// monitors in the layout; 0 when they touch or overlap.
+func monitorDistance(a, b Monitor) int64 {
+	ax, ay, aw, ah := monitorBounds(a)
+	bx, by, bw, bh := monitorBounds(b)
+	dx := max(0, max(ax-(bx+bw), bx-(ax+aw)))
+	dy := max(0, max(ay-(by+bh), by-(ay+ah)))
+	return dx*dx + dy*dy
+}
+
+// primaryMonitor returns the primary one of the enabled monitors: the one
+// primary_monitor in settings names, otherwise the one closest to the
+// layout's origin.
+func primaryMonitor(monitors []Monitor, s *Settings) (Monitor, bool) {
+	var best Monitor
+	found := false
+	for _, m := range monitors {
+		if !m.Active {
+			continue
+		}
+		if s != nil && s.PrimaryMonitor != "" && (s.PrimaryMonitor == workspaceRuleKey(m) || s.PrimaryMonitor == m.Name) {
+			return m, true
+		}
+		if !found || int64(m.X)*int64(m.X)+int64(m.Y)*int64(m.Y) < int64(best.X)*int64(best.X)+int64(best.Y)*int64(best.Y) {
+			best, found = m, true
+		}
+	}
+	return best, found
+}
+
+// migrationTarget returns the monitor workspaces left on from go to under
+// strategy: a connector name, or "current" for the focused monitor. known
+// is false when hyprmon never saw from, so its position is unknown.
+func migrationTarget(strategy string, from Monitor, known bool, active []Monitor, s *Settings) string {
+	if len(active) == 1 {
+		return active[0].Name
+	}
+	switch strategy {
+	case workspaceMigrationNearest:
+		if known {
+			nearest := active[0]
+			for _, m := range active[1:] {
+				if monitorDistance(from, m) < monitorDistance(from, nearest) {
+					nearest = m
+				}
+			}
+			return nearest.Name
+		}
+		fallthrough
+	case workspaceMigrationPrimary:
+		if primary, ok := primaryMonitor(active, s); ok {
+			return primary.Name
+		}
+	}
+	return "current"
+}
+
+// migrateOrphanedWorkspaces moves workspaces off monitors that are no
+// longer enabled, to the monitor picked by the workspace_migration
+// setting, and remembers which monitor each came from. Workspaces whose
+// remembered monitor is enabled again are moved back to it, so undocking
+// and redocking ends with every workspace where it was. previous and before
+// are the layout and workspaces before the change, as Hyprland may already
+// have moved workspaces off a disabled monitor; before may be nil.
+func migrateOrphanedWorkspaces(previous, current []Monitor, before []hyprWorkspace) error {
+	var active []Monitor
+	activeByName := make(map[string]bool)
+	activeByKey := make(map[string]Monitor)
+	for _, m := range current {
+		if m.Active && isValidMonitorName(m.Name) {
+			active = append(active, m)
+			activeByName[m.Name] = true
+			activeByKey[workspaceRuleKey(m)] = m
+		}
+	}
+	if len(active) == 0 {
+		return nil
+	}
+
+	s, err := loadSettings()
+	if err != nil {
+		return err
+	}
+	workspaces, err := readWorkspaces()
+	if err != nil {
+		return fmt.Errorf("failed to read workspaces: %w", err)
+	}
+	homes, err := loadWorkspaceHomes()
+	if err != nil {
+		// A corrupt file only loses the way back; start over
+		homes = make(map[string]string)
+	}
+
+	previousByName := make(map[string]Monitor)
+	for _, m := range previous {
+		previousByName[m.Name] = m
+	}
+	wasOn := make(map[string]string)
+	for _, ws := range before {
+		wasOn[liveWorkspaceSelector(ws)] = ws.Monitor
+	}
+
+	strategy := getWorkspaceMigration(s)
+	changed := false
+	var moves []string
+	for _, ws := range workspaces {
+		if !isMigratableWorkspace(ws) {
+			continue
+		}
+		selector := liveWorkspaceSelector(ws)
+
+		// Back home if the monitor it was moved off has returned
+		if key, ok := homes[selector]; ok {
+			if home, ok := activeByKey[key]; ok {
+				if ws.Monitor != home.Name {
+					moves = append(moves, fmt.Sprintf("dispatch moveworkspacetomonitor %s %s", selector, home.Name))
+				}
+				delete(homes, selector)
+				changed = true
+				continue
+			}
+		}
+		was := ws.Monitor
+		if activeByName[ws.Monitor] {
+			if on, ok := wasOn[selector]; ok && !activeByName[on] {
+				was = on
+			} else {
+				continue
+			}
+		}
+
+		// Orphaned: remember where it was, unless it was already displaced
+		from, known := previousByName[was]
+		if _, ok := homes[selector]; !ok && known {
+			homes[selector] = workspaceRuleKey(from)
+			changed = true
+		}
+		if target := migrationTarget(strategy, from, known, active, s); target != ws.Monitor {
+			moves = append(moves, fmt.Sprintf("dispatch moveworkspacetomonitor %s %s", selector, target))
+		}
+	}
+
+	if changed {
+		if err := saveWorkspaceHomes(homes); err != nil {
+			return err
+		}
+	}
+	if err := hyprBatch(moves); err != nil {
+		return fmt.Errorf("failed to migrate workspaces: %w", err)
+	}
+	return nil
+}
+
+// rememberWorkspaceHomes records where the workspaces in before were when
+// their monitor has since been unplugged or disabled. Hyprland moves such
+// workspaces itself when a monitor disappears, so the daemon compares
+// snapshots to know where to send them back.
+func rememberWorkspaceHomes(before []hyprWorkspace, previous, current []Monitor) error {
+	gone := make(map[string]string) // connector name -> monitor key
+	still := make(map[string]bool)
+	for _, m := range current {
+		if m.Active {
+			still[workspaceRuleKey(m)] = true
+		}
+	}
+	for _, m := range previous {
+		if key := workspaceRuleKey(m); m.Active && !still[key] {
+			gone[m.Name] = key
+		}
+	}
+	if len(gone) == 0 {
+		return nil
+	}
+
+	homes, err := loadWorkspaceHomes()
+	if err != nil {
+		homes = make(map[string]string)
+	}
+	changed := false
+	for _, ws := range before {
+		key, ok := gone[ws.Monitor]
+		if !ok || !isMigratableWorkspace(ws) {
+			continue
+		}
+		if selector := liveWorkspaceSelector(ws); homes[selector] == "" {
+			homes[selector] = key
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return saveWorkspaceHomes(homes)
+}
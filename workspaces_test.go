@@ -0,0 +1,316 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestWorkspaceRuleLines(t *testing.T) {
+	monitors := []Monitor{
+		{Name: "DP-1", HardwareID: "Dell/U2720Q/ABC", EDIDName: "Dell U2720Q", UseDescFormat: true, PxW: 2560, PxH: 1440, Hz: 60, Scale: 1, Active: true},
+		{Name: "eDP-1", PxW: 1920, PxH: 1080, Hz: 60, Scale: 1, Active: true},
+		{Name: "HDMI-A-1", HardwareID: "LG/27GL850/XYZ"},
+	}
+	rules := []WorkspaceRule{
+		{Workspace: "1", HardwareID: "Dell/U2720Q/ABC", Default: true, Persistent: true},
+		{Workspace: "web", HardwareID: "eDP-1"},
+		{Workspace: "5", HardwareID: "LG/27GL850/XYZ"}, // disabled monitor
+		{Workspace: "6", HardwareID: "Samsung/Odyssey/999"},
+	}
+
+	block := hyprmonBlockLines(monitors, rules, monitorSyntaxV1)
+	want := []string{
+		"workspace=1,monitor:desc:Dell U2720Q,default:true,persistent:true",
+		"workspace=name:web,monitor:eDP-1",
+	}
+	if got := block[len(block)-3 : len(block)-1]; !reflect.DeepEqual(got, want) {
+		t.Errorf("hyprlang block ends with %q, want %q", got, want)
+	}
+
+	lua := luaBlockLines(monitors, rules)
+	wantLua := []string{
+		`hl.workspace_rule({ workspace = "1", monitor = "desc:Dell U2720Q", default = true, persistent = true })`,
+		`hl.workspace_rule({ workspace = "name:web", monitor = "eDP-1" })`,
+	}
+	if got := lua[len(lua)-3 : len(lua)-1]; !reflect.DeepEqual(got, wantLua) {
+		t.Errorf("lua block ends with %q, want %q", got, wantLua)
+	}
+
+	if line := generateWorkspaceRule(WorkspaceRule{Workspace: "1;reload"}, monitors[1]); !strings.HasPrefix(line, "#") {
+		t.Errorf("invalid workspace written as %q", line)
+	}
+}
+
+func TestWorkspaceMoveCommands(t *testing.T) {
+	monitors := []Monitor{
+		{Name: "DP-1", HardwareID: "Dell/U2720Q/ABC", Active: true},
+		{Name: "eDP-1", Active: true},
+	}
+	rules := []WorkspaceRule{
+		{Workspace: "1", HardwareID: "Dell/U2720Q/ABC"},
+		{Workspace: "2", HardwareID: "Dell/U2720Q/ABC"}, // already there
+		{Workspace: "web", HardwareID: "eDP-1"},
+		{Workspace: "9", HardwareID: "eDP-1"}, // doesn't exist yet
+	}
+	live := []hyprWorkspace{
+		{ID: 1, Name: "1", Monitor: "eDP-1"},
+		{ID: 2, Name: "2", Monitor: "DP-1"},
+		{ID: -98, Name: "web", Monitor: "DP-1"},
+	}
+
+	got := workspaceMoveCommands(rules, monitors, live)
+	want := []string{
+		"dispatch moveworkspacetomonitor 1 DP-1",
+		"dispatch moveworkspacetomonitor name:web eDP-1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("workspaceMoveCommands() = %q, want %q", got, want)
+	}
+}
+
+func TestSaveProfileKeepsWorkspaceRules(t *testing.T) {
+	useTempConfigDir(t)
+
+	monitors := []Monitor{{Name: "DP-1", HardwareID: "Dell/U2720Q/ABC", Active: true}}
+	rules := []WorkspaceRule{{Workspace: "1", HardwareID: "Dell/U2720Q/ABC", Default: true}}
+	if err := saveProfile("desk", monitors); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveProfileWorkspaces("desk", rules); err != nil {
+		t.Fatal(err)
+	}
+
+	// Saving the layout again and renaming keep the rules
+	if err := saveProfile("desk", monitors); err != nil {
+		t.Fatal(err)
+	}
+	if err := renameProfile("desk", "office"); err != nil {
+		t.Fatal(err)
+	}
+	profile, err := loadProfile("office")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(profile.Workspaces, rules) {
+		t.Errorf("workspaces = %+v, want %+v", profile.Workspaces, rules)
+	}
+}
+
+func TestApplyProfileAppliesWorkspaceRules(t *testing.T) {
+	shortVerifySettle(t)
+	monitorsJSON := `[{"name":"DP-1","make":"Dell Inc.","model":"U2720Q","serial":"ABC","width":2560,"height":1440,"refreshRate":60,"scale":1,"x":0,"y":0},` +
+		`{"name":"eDP-1","width":1920,"height":1080,"refreshRate":60,"scale":1,"x":2560,"y":0}]`
+	fake := startFakeHyprland(t, func(request string) string {
+		switch request {
+		case "j/monitors all", "j/monitors":
+			return monitorsJSON
+		case "j/workspaces":
+			return `[{"id":1,"name":"1","monitor":"eDP-1"},{"id":2,"name":"2","monitor":"eDP-1"}]`
+		}
+		return "ok"
+	})
+	useTempConfigDir(t)
+	configPath := filepath.Join(t.TempDir(), "hyprland.conf")
+	writeTestFile(t, configPath, "$mod = SUPER\n")
+	t.Setenv("HYPRLAND_CONFIG", configPath)
+
+	if err := saveProfile("docked", []Monitor{
+		{Name: "DP-1", HardwareID: "Dell Inc./U2720Q/ABC", PxW: 2560, PxH: 1440, Hz: 60, Scale: 1, Active: true},
+		{Name: "eDP-1", PxW: 1920, PxH: 1080, Hz: 60, Scale: 1, X: 2560, Active: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveProfileWorkspaces("docked", []WorkspaceRule{
+		{Workspace: "1", HardwareID: "Dell Inc./U2720Q/ABC", Default: true},
+		{Workspace: "2", HardwareID: "eDP-1"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := applyProfile("docked"); err != nil {
+		t.Fatalf("applyProfile() error = %v", err)
+	}
+
+	var moves []string
+	for _, request := range fake.Requests() {
+		if strings.Contains(request, "moveworkspacetomonitor") {
+			moves = append(moves, request)
+		}
+	}
+	if len(moves) != 1 || moves[0] != hyprBatchPrefix+"dispatch moveworkspacetomonitor 1 DP-1" {
+		t.Errorf("workspace moves = %q, want workspace 1 moved to DP-1", moves)
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"workspace=1,monitor:DP-1,default:true", "workspace=2,monitor:eDP-1"} {
+		if !strings.Contains(string(data), line+"\n") {
+			t.Errorf("config is missing %q:\n%s", line, data)
+		}
+	}
+}
+
+func TestApplyReportsWorkspaceRuleFailure(t *testing.T) {
+	m := model{}
+	next, _ := m.Update(applyMsg{success: true, workspaceErr: errors.New("failed to move workspaces: no such monitor")})
+	want := "Changes applied, waiting for confirmation, but failed to move workspaces: no such monitor"
+	if got := next.(model).Status; got != want {
+		t.Errorf("Status = %q, want %q", got, want)
+	}
+}
+
+func TestWorkspaceEditor(t *testing.T) {
+	monitors := []Monitor{
+		{Name: "DP-1", HardwareID: "Dell/U2720Q/ABC", Active: true},
+		{Name: "eDP-1", Active: true},
+	}
+	e := newWorkspaceEditor("docked", nil, monitors, 80, 24)
+
+	keys := []tea.KeyMsg{
+		{Type: tea.KeyRunes, Runes: []rune("a")},
+		{Type: tea.KeyRunes, Runes: []rune("w")},
+		{Type: tea.KeyRunes, Runes: []rune("e")},
+		{Type: tea.KeyRunes, Runes: []rune("b")},
+		{Type: tea.KeyEnter},
+		{Type: tea.KeyRight},
+		{Type: tea.KeySpace, Runes: []rune(" ")}, // monitor: DP-1 -> eDP-1
+		{Type: tea.KeyRight},
+		{Type: tea.KeySpace, Runes: []rune(" ")}, // default
+	}
+	for _, key := range keys {
+		e, _ = e.Update(key)
+	}
+	_, cmd := e.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil {
+		t.Fatalf("enter did not save: %s", e.error)
+	}
+	saved, ok := cmd().(workspaceEditorSaveMsg)
+	want := []WorkspaceRule{{Workspace: "web", HardwareID: "eDP-1", Default: true}}
+	if !ok || !reflect.DeepEqual(saved.rules, want) {
+		t.Fatalf("saved %+v, want %+v", saved.rules, want)
+	}
+
+	err := validateWorkspaceRules([]WorkspaceRule{
+		{Workspace: "1", HardwareID: "eDP-1", Default: true},
+		{Workspace: "2", HardwareID: "eDP-1", Default: true},
+	}, monitors)
+	if err == nil || !strings.Contains(err.Error(), "two default workspaces") {
+		t.Errorf("two defaults on one monitor: error = %v", err)
+	}
+	if err := validateWorkspaceRules([]WorkspaceRule{{Workspace: "web"}, {Workspace: "name:web"}}, monitors); err == nil {
+		t.Error("the same workspace twice was accepted")
+	}
+}
+
+func TestMigrationTarget(t *testing.T) {
+	laptop := Monitor{Name: "eDP-1", PxW: 1920, PxH: 1080, Scale: 1, X: 0, Y: 0, Active: true}
+	left := Monitor{Name: "DP-1", HardwareID: "Dell/U2720Q/ABC", PxW: 2560, PxH: 1440, Scale: 1, X: 1920, Y: 0, Active: true}
+	right := Monitor{Name: "DP-2", PxW: 3840, PxH: 2160, Scale: 2, X: 4480, Y: 0, Active: true}
+	gone := Monitor{Name: "HDMI-A-1", PxW: 1920, PxH: 1080, Scale: 1, X: 6400, Y: 0}
+	active := []Monitor{laptop, left, right}
+
+	tests := []struct {
+		name     string
+		strategy string
+		settings Settings
+		known    bool
+		want     string
+	}{
+		{"focused", workspaceMigrationFocused, Settings{}, true, "current"},
+		{"primary at the origin", workspaceMigrationPrimary, Settings{}, true, "eDP-1"},
+		{"primary from settings", workspaceMigrationPrimary, Settings{PrimaryMonitor: "Dell/U2720Q/ABC"}, true, "DP-1"},
+		{"nearest", workspaceMigrationNearest, Settings{}, true, "DP-2"},
+		{"nearest of an unknown monitor", workspaceMigrationNearest, Settings{PrimaryMonitor: "DP-1"}, false, "DP-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := migrationTarget(tt.strategy, gone, tt.known, active, &tt.settings); got != tt.want {
+				t.Errorf("migrationTarget() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := migrationTarget(workspaceMigrationFocused, gone, true, active[:1], &Settings{}); got != "eDP-1" {
+		t.Errorf("single monitor: migrationTarget() = %q, want eDP-1", got)
+	}
+}
+
+func TestWorkspacesReturnOnReconnect(t *testing.T) {
+	useTempConfigDir(t)
+
+	dell := Monitor{Name: "DP-1", HardwareID: "Dell/U2720Q/ABC", PxW: 2560, PxH: 1440, Scale: 1, Active: true}
+	laptop := Monitor{Name: "eDP-1", PxW: 1920, PxH: 1080, Scale: 1, X: 2560, Active: true}
+
+	// Undock: Hyprland already moved workspace 2 to eDP-1 by the time the
+	// daemon looks, so the home comes from the previous snapshot
+	before := []hyprWorkspace{{ID: 1, Monitor: "eDP-1"}, {ID: 2, Monitor: "DP-1"}, {ID: -98, Name: "web", Monitor: "DP-1"}, {ID: -99, Name: "special:term", Monitor: "DP-1"}}
+	if err := rememberWorkspaceHomes(before, []Monitor{dell, laptop}, []Monitor{laptop}); err != nil {
+		t.Fatal(err)
+	}
+	homes, err := loadWorkspaceHomes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"2": "Dell/U2720Q/ABC", "name:web": "Dell/U2720Q/ABC"}
+	if !reflect.DeepEqual(homes, want) {
+		t.Fatalf("homes = %v, want %v", homes, want)
+	}
+
+	// Redock, on a different connector
+	fake := startFakeHyprland(t, func(request string) string {
+		if request == "j/workspaces" {
+			return `[{"id":1,"name":"1","monitor":"eDP-1"},{"id":2,"name":"2","monitor":"eDP-1"},{"id":-98,"name":"web","monitor":"eDP-1"},{"id":-99,"name":"special:term","monitor":"eDP-1"}]`
+		}
+		return "ok\n\nok"
+	})
+	dell.Name = "DP-3"
+	if err := migrateOrphanedWorkspaces([]Monitor{laptop}, []Monitor{dell, laptop}, nil); err != nil {
+		t.Fatal(err)
+	}
+	requests := fake.Requests()
+	wantBatch := hyprBatchPrefix + "dispatch moveworkspacetomonitor 2 DP-3;dispatch moveworkspacetomonitor name:web DP-3"
+	if len(requests) != 2 || requests[1] != wantBatch {
+		t.Errorf("requests = %q, want %q", requests, wantBatch)
+	}
+	if homes, _ := loadWorkspaceHomes(); len(homes) != 0 {
+		t.Errorf("homes after reconnect = %v, want none", homes)
+	}
+}
+
+func TestMigrateWorkspacesHyprlandAlreadyMoved(t *testing.T) {
+	useTempConfigDir(t)
+	if err := saveSettings(&Settings{WorkspaceMigration: workspaceMigrationPrimary, PrimaryMonitor: "DP-2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// HDMI-A-1 was turned off and Hyprland put its workspace on DP-1
+	fake := startFakeHyprland(t, func(request string) string {
+		if request == "j/workspaces" {
+			return `[{"id":1,"name":"1","monitor":"DP-1"},{"id":4,"name":"4","monitor":"DP-1"}]`
+		}
+		return "ok"
+	})
+	previous := []Monitor{{Name: "DP-1", Active: true}, {Name: "DP-2", Active: true}, {Name: "HDMI-A-1", Active: true}}
+	current := []Monitor{{Name: "DP-1", Active: true}, {Name: "DP-2", Active: true}, {Name: "HDMI-A-1"}}
+	before := []hyprWorkspace{{ID: 1, Monitor: "DP-1"}, {ID: 4, Monitor: "HDMI-A-1"}}
+
+	if err := migrateOrphanedWorkspaces(previous, current, before); err != nil {
+		t.Fatal(err)
+	}
+	requests := fake.Requests()
+	want := hyprBatchPrefix + "dispatch moveworkspacetomonitor 4 DP-2"
+	if len(requests) != 2 || requests[1] != want {
+		t.Errorf("requests = %q, want %q", requests, want)
+	}
+	if homes, _ := loadWorkspaceHomes(); homes["4"] != "HDMI-A-1" {
+		t.Errorf("homes = %v, want workspace 4 remembered on HDMI-A-1", homes)
+	}
+}